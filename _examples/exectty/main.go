@@ -1,5 +1,5 @@
 // Command exectty is a dummy command that starts '/bin/bash' on a new terminal.
-// If there is no terminal, it doesn't do anything.
+// If standard output is not a terminal, it runs '/bin/bash' using plain pipes instead.
 package main
 
 import (
@@ -15,11 +15,14 @@ func main() {
 }
 
 func run() int {
-	fd, TERM, resize, cleanup, err := term.GetStdTerminal()
-	if err != nil || fd == nil {
-		panic("Std: Not a terminal")
+	defer term.RestoreOnPanic()
+	defer term.InstallSignalGuard()()
+
+	std, err := term.OpenStdTerminal()
+	if err != nil {
+		panic(err)
 	}
-	defer cleanup()
+	defer std.Restore()
 
 	cmd := procutil.Command{
 		Process: &procutil.ExecProcess{
@@ -27,11 +30,11 @@ func run() int {
 		},
 	}
 
-	if err := cmd.Init(context.Background(), true); err != nil {
+	if err := cmd.Init(context.Background(), std.IsTerminal()); err != nil {
 		panic(err)
 	}
 
-	if err := cmd.StartPty(fd.File(), TERM, resize); err != nil {
+	if err := cmd.StartStd(std); err != nil {
 		panic(err)
 	}
 	defer cmd.Cleanup()
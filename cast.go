@@ -0,0 +1,147 @@
+package procutil
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// This file implements reading and writing of asciinema v2 cast files.
+// See https://docs.asciinema.org/manual/asciicast/v2/ for a description of the format.
+
+// CastHeader is the header of an asciinema v2 cast file.
+type CastHeader struct {
+	Version       int               `json:"version"`
+	Width         int               `json:"width"`
+	Height        int               `json:"height"`
+	Timestamp     int64             `json:"timestamp,omitempty"`
+	Duration      float64           `json:"duration,omitempty"`
+	IdleTimeLimit float64           `json:"idle_time_limit,omitempty"`
+	Command       string            `json:"command,omitempty"`
+	Title         string            `json:"title,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+}
+
+// CastEventType is the type of an event in a cast file.
+type CastEventType string
+
+const (
+	CastOutput CastEventType = "o" // data written by the process
+	CastInput  CastEventType = "i" // data read by the process
+	CastResize CastEventType = "r" // terminal resized, data is of the form "WIDTHxHEIGHT"
+	CastMarker CastEventType = "m" // marker, data is a label
+
+	// CastExit records the exit code of the process as a decimal string.
+	// It is not part of asciinema v2, but borrowed from asciicast v3; players ignore it.
+	CastExit CastEventType = "x"
+)
+
+// CastEvent is a single event within a cast file.
+type CastEvent struct {
+	Time float64 // seconds since the start of the recording
+	Type CastEventType
+	Data string
+}
+
+// MarshalJSON encodes this event as a JSON array.
+func (ce CastEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{ce.Time, ce.Type, ce.Data})
+}
+
+var errCastInvalidEvent = errors.New("Cast: Invalid event")
+
+// UnmarshalJSON decodes this event from a JSON array.
+func (ce *CastEvent) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 3 {
+		return errCastInvalidEvent
+	}
+	if err := json.Unmarshal(raw[0], &ce.Time); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw[1], &ce.Type); err != nil {
+		return err
+	}
+	return json.Unmarshal(raw[2], &ce.Data)
+}
+
+// Cast represents a cast file read into memory.
+type Cast struct {
+	Header CastHeader
+	Events []CastEvent
+}
+
+var errCastUnsupportedVersion = errors.New("Cast: Unsupported version")
+
+// ReadCast reads a cast file from reader.
+func ReadCast(reader io.Reader) (*Cast, error) {
+	var cast Cast
+
+	check := func() error {
+		if cast.Header.Version != 2 {
+			return errCastUnsupportedVersion
+		}
+		return nil
+	}
+	next := func() interface{} {
+		cast.Events = append(cast.Events, CastEvent{})
+		return &cast.Events[len(cast.Events)-1]
+	}
+
+	if err := readJSONLines(reader, "Cast", "event", &cast.Header, check, next); err != nil {
+		return nil, err
+	}
+	return &cast, nil
+}
+
+// CastWriter writes a cast file to an underlying writer.
+// It is safe to be called by multiple goroutines.
+type CastWriter struct {
+	lines *jsonLinesWriter
+}
+
+// NewCastWriter writes header to w and returns a new CastWriter writing to w.
+// Event times are measured relative to the time this function was called.
+//
+// When header.Version is zero, it is set to 2.
+func NewCastWriter(w io.Writer, header CastHeader) (*CastWriter, error) {
+	if header.Version == 0 {
+		header.Version = 2
+	}
+
+	lines, err := newJSONLinesWriter(w, header, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &CastWriter{lines: lines}, nil
+}
+
+// WriteEvent writes an event with the given type and data, timestamped with the current time.
+func (cw *CastWriter) WriteEvent(typ CastEventType, data string) error {
+	return cw.lines.Write(func(elapsed float64) interface{} {
+		return CastEvent{Time: elapsed, Type: typ, Data: data}
+	})
+}
+
+// castUTF8 splits p into a prefix of complete utf8 sequences, and an incomplete trailing sequence.
+// This prevents runes spanning multiple writes from being mangled.
+func castUTF8(p []byte) (complete, rest []byte) {
+	// an incomplete sequence is at most utf8.UTFMax - 1 bytes long
+	for i := 1; i < utf8.UTFMax && i <= len(p); i++ {
+		start := len(p) - i
+		if !utf8.RuneStart(p[start]) {
+			continue
+		}
+		if !utf8.FullRune(p[start:]) {
+			return p[:start], p[start:]
+		}
+		break
+	}
+	return p, nil
+}
@@ -0,0 +1,69 @@
+package procutil
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestCastRoundTrip(t *testing.T) {
+	var buffer bytes.Buffer
+
+	writer, err := NewCastWriter(&buffer, CastHeader{Width: 100, Height: 30, Title: "test"})
+	if err != nil {
+		t.Fatalf("NewCastWriter() returned %s", err)
+	}
+
+	events := []CastEvent{
+		{Type: CastOutput, Data: "hello\r\n"},
+		{Type: CastResize, Data: "80x24"},
+		{Type: CastInput, Data: "ä\x1b[A"},
+		{Type: CastExit, Data: "0"},
+	}
+	for _, event := range events {
+		if err := writer.WriteEvent(event.Type, event.Data); err != nil {
+			t.Fatalf("CastWriter.WriteEvent() returned %s", err)
+		}
+	}
+
+	cast, err := ReadCast(&buffer)
+	if err != nil {
+		t.Fatalf("ReadCast() returned %s", err)
+	}
+
+	wantHeader := CastHeader{Version: 2, Width: 100, Height: 30, Title: "test"}
+	cast.Header.Timestamp = 0
+	if !reflect.DeepEqual(cast.Header, wantHeader) {
+		t.Errorf("ReadCast() got header %v, want %v", cast.Header, wantHeader)
+	}
+
+	for i := range cast.Events {
+		cast.Events[i].Time = 0
+	}
+	if !reflect.DeepEqual(cast.Events, events) {
+		t.Errorf("ReadCast() got events %v, want %v", cast.Events, events)
+	}
+}
+
+func Test_castUTF8(t *testing.T) {
+	tests := []struct {
+		name           string
+		p              string
+		complete, rest string
+	}{
+		{"empty", "", "", ""},
+		{"ascii", "hello", "hello", ""},
+		{"complete rune", "hä", "hä", ""},
+		{"incomplete two-byte rune", "h\xc3", "h", "\xc3"},
+		{"incomplete three-byte rune", "h\xe2\x82", "h", "\xe2\x82"},
+		{"invalid byte", "h\xff", "h\xff", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			complete, rest := castUTF8([]byte(tt.p))
+			if string(complete) != tt.complete || string(rest) != tt.rest {
+				t.Errorf("castUTF8() = (%q, %q), want (%q, %q)", complete, rest, tt.complete, tt.rest)
+			}
+		})
+	}
+}
@@ -61,3 +61,32 @@ func (w *DualCloserWrapper) CloseWrite() (err error) {
 	})
 	return
 }
+
+// Duplex combines a Reader and a Writer into a single io.ReadWriteCloser, for instance for use with Command.StartPty().
+// It implements DualCloser: Close() closes the Reader and CloseWrite() closes the Writer, if they implement io.Closer.
+type Duplex struct {
+	io.Reader
+	io.Writer
+}
+
+// Duplex implements io.ReadWriteCloser and DualCloser
+func init() {
+	var _ io.ReadWriteCloser = (*Duplex)(nil)
+	var _ DualCloser = (*Duplex)(nil)
+}
+
+// Close closes the Reader, if it implements io.Closer
+func (d *Duplex) Close() error {
+	if closer, ok := d.Reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// CloseWrite closes the Writer, if it implements io.Closer
+func (d *Duplex) CloseWrite() error {
+	if closer, ok := d.Writer.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -2,9 +2,9 @@ package procutil
 
 import (
 	"context"
-	"errors"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/tkw1536/procutil/term"
 )
@@ -15,9 +15,25 @@ import (
 type Command struct {
 	Process Process
 
+	// Tracer, when not nil, is used to trace the lifecycle of the command.
+	// A single span is started by Init(), as a child of any span in the context passed to it.
+	// The span records the events of the command, and ends once the command has been cleaned up.
+	// The span is passed to the process using ContextWithSpan(), allowing processes to propagate it.
+	Tracer Tracer
+
+	// OutputLimit is the maximum number of bytes of output returned by Output() and CombinedOutput().
+	// When not positive, all output is returned.
+	OutputLimit int
+
+	// WaitDelay bounds the time Run(), Output(), CombinedOutput() and a Pipeline wait for the output of the process to be copied once it has exited.
+	// Children of the process may keep its output open after it has exited.
+	// Once WaitDelay has passed, the output streams are closed, and any output not yet copied is lost.
+	// When zero, DefaultWaitDelay is used; when negative, output is waited for indefinitely.
+	WaitDelay time.Duration
+
 	m sync.Mutex // m protects all fields below
 
-	state commandState // the current state of the underlying process.
+	state CommandState // the current state of the underlying process.
 
 	isPty bool // did the call to init() set up a tty?
 	pty   term.Terminal
@@ -28,19 +44,33 @@ type Command struct {
 
 	cleanupOnce sync.Once // used to cleanup once
 	cleanupErr  error     // error from cleanup
+
+	observers     []*observerEntry // registered observers, see AddObserver()
+	observerCount int32            // len(observers), accessed atomically without holding e.m
+	pid           int              // pid of the process once started, see PidProcess
+	started       time.Time        // time the process was started
+	running       bool             // the process was started successfully, but EventDone has not been sent
+
+	firstOutput sync.Once      // used to send EventFirstOutput once
+	outputs     sync.WaitGroup // done once all output has been copied
+	outputPipes []io.Closer    // read ends of the output streams of the process, see waitOutputs()
 }
 
-type commandState int
+// String returns the String() of the underlying process.
+func (e *Command) String() string {
+	if e.Process == nil {
+		return ""
+	}
+	return e.Process.String()
+}
 
-const (
-	commandStateDefault commandState = iota
-	commandStateInit
-	commandStateStart
-	commandStateWait
-	commandStateDone
-)
+// State returns the current state of this command.
+func (e *Command) State() CommandState {
+	e.m.Lock()
+	defer e.m.Unlock()
 
-var errCommandAlreadyInitialized = errors.New("Command: Already initialized")
+	return e.state
+}
 
 // Init initializes the underlying process by providing it with an appropriate context.
 // When context is nil, a new background context is initialized.
@@ -48,31 +78,47 @@ var errCommandAlreadyInitialized = errors.New("Command: Already initialized")
 // Once the context is closed, the command will be killed.
 //
 // Init must be called once.
-func (e *Command) Init(ctx context.Context, isTty bool) error {
+func (e *Command) Init(ctx context.Context, isTty bool) (err error) {
+	defer func() { e.notify(EventInit, err) }()
+
 	e.m.Lock()
 	defer e.m.Unlock()
 
-	if e.state != commandStateDefault { // command already initialized
-		return errCommandAlreadyInitialized
+	if e.state != CommandStateDefault { // command already initialized
+		return e.wrapError("Init", e.state, ErrCommandAlreadyInitialized)
 	}
 
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
+	var so *spanObserver
+	if e.Tracer != nil {
+		var span Span
+		ctx, span = e.Tracer.Start(ctx, commandSpanName)
+		ctx = ContextWithSpan(ctx, span)
+		so = &spanObserver{span: span}
+	}
+
 	if err := e.Process.Init(ctx, isTty); err != nil {
+		err = e.wrapError("Init", e.state, err)
+
+		// the span is not registered, so that a failed Init() does not leave it behind; end it right away.
+		if so != nil {
+			so.Observe(Event{Type: EventInit, Process: e.String(), Kind: ProcessKind(e.Process), Err: err})
+		}
 		return err
 	}
+	if so != nil {
+		e.addObserver(&observerEntry{so})
+	}
 
-	e.state = commandStateInit
+	e.state = CommandStateInit
 	e.isPty = isTty
 
 	return nil
 }
 
-var errCommandNotInitialized = errors.New("Command: Not initialized")
-var errCommandIsATerminal = errors.New("Command: Is a Terminal")
-
 // Start starts the process and sends output to the provided streams.
 // Calls Close() on the provided streams when they are closed.
 //
@@ -81,55 +127,74 @@ var errCommandIsATerminal = errors.New("Command: Is a Terminal")
 //
 // Start() and StartPty() may only be called once.
 // Subsequently calls will produce an error.
-func (e *Command) Start(Out, Err io.Writer, In io.Reader) error {
+func (e *Command) Start(Out, Err io.Writer, In io.Reader) (err error) {
+	defer func() { e.notify(EventStart, err) }()
+
 	e.m.Lock()
 	defer e.m.Unlock()
 
-	if e.state != commandStateInit {
-		return errCommandIsATerminal
+	if err := e.checkStart(); err != nil {
+		return e.wrapError("Start", e.state, err)
 	}
 	if e.isPty {
-		return errCommandIsATerminal
+		return e.wrapError("Start", e.state, ErrCommandIsATerminal)
 	}
 
-	e.state = commandStateStart
+	e.state = CommandStateStart
 
 	// fetch all the streams
 	stdin, err := e.Process.Stdin()
 	if err != nil {
-		return err
+		return e.wrapError("Start", CommandStateInit, err)
 	}
 	stdout, err := e.Process.Stdout()
 	if err != nil {
-		return err
+		return e.wrapError("Start", CommandStateInit, err)
 	}
 	stderr, err := e.Process.Stderr()
 	if err != nil {
-		return err
+		return e.wrapError("Start", CommandStateInit, err)
 	}
 
 	// copy over input and output
 	go func() {
 		defer stdin.Close()
-		io.Copy(stdin, In)
+		e.copyStream("stdin", stdin, In)
 	}()
 
+	e.outputs.Add(2)
+	e.outputPipes = []io.Closer{stdout, stderr}
 	go func() {
+		defer e.outputs.Done()
 		defer stdout.Close()
-		io.Copy(Out, stdout)
+		e.copyStream("stdout", Out, stdout)
 	}()
 
 	go func() {
+		defer e.outputs.Done()
 		defer stderr.Close()
-		io.Copy(Err, stderr)
+		e.copyStream("stderr", Err, stderr)
 	}()
 
 	// Start the process
 	_, err = e.Process.Start("", nil, false)
-	return err
+	e.pid, e.started = processPid(e.Process), time.Now()
+	e.running = err == nil
+	return e.wrapError("Start", CommandStateInit, err)
 }
 
-var errCommandNotATerminal = errors.New("Command: Not a Terminal")
+// checkStart checks that the command can be started.
+// e.m must be held by the caller.
+func (e *Command) checkStart() error {
+	switch e.state {
+	case CommandStateDefault:
+		return ErrCommandNotInitialized
+	case CommandStateInit:
+		return nil
+	default:
+		return ErrCommandAlreadyStarted
+	}
+}
 
 // StartPty runs this process on the given terminal.
 //
@@ -140,18 +205,20 @@ var errCommandNotATerminal = errors.New("Command: Not a Terminal")
 //
 // Start() and StartPty() may only be called once.
 // Subsequently calls will produce an error.
-func (e *Command) StartPty(tm io.ReadWriteCloser, TERM string, resizeChan <-chan term.WindowSize) error {
+func (e *Command) StartPty(tm io.ReadWriteCloser, TERM string, resizeChan <-chan term.WindowSize) (err error) {
+	defer func() { e.notify(EventStart, err) }()
+
 	e.m.Lock()
 	defer e.m.Unlock()
 
-	if e.state != commandStateInit {
-		return errCommandNotInitialized
+	if err := e.checkStart(); err != nil {
+		return e.wrapError("StartPty", e.state, err)
 	}
 	if !e.isPty {
-		return errCommandNotATerminal
+		return e.wrapError("StartPty", e.state, ErrCommandNotATerminal)
 	}
 
-	e.state = commandStateStart
+	e.state = CommandStateStart
 
 	// protect against callers that pass resizeChan === nil.
 	if resizeChan == nil {
@@ -161,29 +228,54 @@ func (e *Command) StartPty(tm io.ReadWriteCloser, TERM string, resizeChan <-chan
 	}
 
 	// Start process on the terminal
-	f, err := e.Process.Start(TERM, resizeChan, true)
+	f, err := e.Process.Start(TERM, e.observeResizes(resizeChan), true)
+	e.pid, e.started = processPid(e.Process), time.Now()
 	if err != nil {
-		return err
+		return e.wrapError("StartPty", CommandStateInit, err)
 	}
 	e.pty = f
+	e.running = true
 
 	// start copying both ways and close when done.
 	tc := NewDualCloser(tm)
+	e.outputs.Add(1)
+	e.outputPipes = []io.Closer{f.ReadWriteCloser()}
 	go func() {
+		defer e.outputs.Done()
 		defer tc.CloseWrite()
-		io.Copy(tm, f.ReadWriteCloser())
+		e.copyStream("stdout", tm, f.ReadWriteCloser())
 	}()
 	go func() {
 		defer tc.Close()
-		io.Copy(f.ReadWriteCloser(), tm)
+		e.copyStream("stdin", f.ReadWriteCloser(), tm)
 	}()
 
 	return nil
 }
 
+// StartStd runs this process on the standard streams of the current process represented by std.
+//
+// When the command was initialized to run on a pty, calls StartPty() with the combined Stdin and Stdout of std.
+// Otherwise calls Start() with the Stdout, Stderr and Stdin of std.
+// Usually Init() should be called with std.IsTerminal() as the isTty argument.
+//
+// Init() must be called before a call to StartStd().
+// If this is not the case, an error is returned.
+func (e *Command) StartStd(std *term.StdTerminal) error {
+	e.m.Lock()
+	isPty := e.isPty
+	e.m.Unlock()
+
+	if isPty {
+		return e.StartPty(std.ReadWriteCloser(), std.TERM, std.Resize)
+	}
+	return e.Start(std.Stdout.ReadWriteCloser(), std.Stderr.ReadWriteCloser(), std.Stdin.ReadWriteCloser())
+}
+
 // Wait waits for this process.
+// Errors returned by the underlying process are wrapped in a *CommandError.
 func (e *Command) Wait() (int, error) {
-	if err := e.wait(); err != nil {
+	if err := e.wait("Wait"); err != nil {
 		return 0, err
 	}
 
@@ -192,21 +284,26 @@ func (e *Command) Wait() (int, error) {
 	return e.waitExitCode, e.waitErr
 }
 
-var errCommandNotRunning = errors.New("Command: Process is not running")
-
-func (e *Command) wait() error {
+// wait starts waiting for the process in the background, unless this has already happened.
+// op is the method that called wait, used in the returned error.
+func (e *Command) wait(op string) error {
 	e.m.Lock()
-	defer e.m.Unlock()
 
-	if e.state == commandStateWait || e.state == commandStateDone { // already waiting or done
+	if e.state == CommandStateWait || e.state == CommandStateDone { // already waiting or done
+		e.m.Unlock()
 		return nil
 	}
-	if e.state != commandStateStart {
-		return errCommandNotRunning
+	if e.state != CommandStateStart {
+		defer e.m.Unlock()
+		return e.wrapError(op, e.state, ErrCommandNotRunning)
 	}
 
-	e.state = commandStateWait
+	e.state = CommandStateWait
 	e.waitChan = make(chan struct{})
+	e.m.Unlock()
+
+	// only start the waiter once the event has been sent, so that observers receive EventWait before EventDone.
+	e.notify(EventWait, nil)
 	go e.waiter()
 
 	return nil
@@ -217,33 +314,44 @@ func (e *Command) waiter() {
 	code, err := e.Process.Wait()
 
 	e.m.Lock()
-	defer e.m.Unlock()
+	e.state = CommandStateDone
+	started := e.running
+	e.running = false
+	e.waitExitCode, e.waitErr = code, e.wrapError("Wait", CommandStateWait, err)
+	e.m.Unlock()
+
+	// notify observers before Wait() returns
+	e.notifyEvent(Event{Type: EventDone, ExitCode: e.waitExitCode, Started: started, Err: e.waitErr})
 
-	e.state = commandStateDone
-	e.waitExitCode, e.waitErr = code, err
-	go e.Cleanup()
+	// closing the pty discards output not yet copied, so only clean up once it has been.
+	go func() {
+		e.waitOutputs()
+		e.Cleanup()
+	}()
 	close(e.waitChan)
 }
 
 // Stop stops the underlying process.
 // When an underlying process is not running, returns an error.
 // When the process has already finished running, returns nil.
-func (e *Command) Stop() error {
+func (e *Command) Stop() (err error) {
+	defer func() { e.notify(EventStop, err) }()
+
 	e.m.Lock()
 	defer e.m.Unlock()
 
-	// ensure that the process is not running
-	if e.state != commandStateStart && e.state != commandStateWait {
-		return errCommandNotRunning
-	}
-
 	// if the process has finished, returns nil.
-	if e.state == commandStateDone {
+	if e.state == CommandStateDone {
 		return nil
 	}
 
+	// ensure that the process is running
+	if e.state != CommandStateStart && e.state != CommandStateWait {
+		return e.wrapError("Stop", e.state, ErrCommandNotRunning)
+	}
+
 	// kill the process
-	return e.Process.Stop()
+	return e.wrapError("Stop", e.state, e.Process.Stop())
 }
 
 // Cleanup cleans up this process.
@@ -257,19 +365,24 @@ func (e *Command) Cleanup() error {
 		if e.pty != nil {
 			e.pty.Close()
 		}
-		e.cleanupErr = e.Process.Cleanup()
+		e.cleanupErr = e.wrapError("Cleanup", CommandStateDone, e.Process.Cleanup())
+
+		e.m.Lock()
+		unwaited := e.running
+		e.running = false
+		e.m.Unlock()
+
+		e.notifyEvent(Event{Type: EventCleanup, Err: e.cleanupErr, Unwaited: unwaited})
 	})
 	return e.cleanupErr
 }
 
-var errCommandRunning = errors.New("Command: Process is running")
-
 func (e *Command) cleanup() error {
 	e.m.Lock()
 	defer e.m.Unlock()
 
-	if e.state != commandStateDone {
-		return errCommandRunning
+	if e.state != CommandStateDone {
+		return e.wrapError("Cleanup", e.state, ErrCommandRunning)
 	}
 
 	return nil
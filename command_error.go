@@ -0,0 +1,89 @@
+package procutil
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CommandState is the state of a Command
+type CommandState int
+
+const (
+	// CommandStateDefault is the state of a Command before Init() has been called
+	CommandStateDefault CommandState = iota
+	// CommandStateInit is the state of a Command after Init(), but before Start()
+	CommandStateInit
+	// CommandStateStart is the state of a Command after Start(), but before Wait()
+	CommandStateStart
+	// CommandStateWait is the state of a Command while it is being waited for
+	CommandStateWait
+	// CommandStateDone is the state of a Command once the process has exited
+	CommandStateDone
+)
+
+func (s CommandState) String() string {
+	switch s {
+	case CommandStateDefault:
+		return "default"
+	case CommandStateInit:
+		return "initialized"
+	case CommandStateStart:
+		return "started"
+	case CommandStateWait:
+		return "waiting"
+	case CommandStateDone:
+		return "done"
+	default:
+		return fmt.Sprintf("CommandState(%d)", int(s))
+	}
+}
+
+// Errors returned by methods of Command.
+// They are always wrapped inside a *CommandError, and should be checked using errors.Is().
+var (
+	ErrCommandAlreadyInitialized = errors.New("Command: Already initialized")
+	ErrCommandNotInitialized     = errors.New("Command: Not initialized")
+	ErrCommandAlreadyStarted     = errors.New("Command: Already started")
+	ErrCommandIsATerminal        = errors.New("Command: Is a Terminal")
+	ErrCommandNotATerminal       = errors.New("Command: Not a Terminal")
+	ErrCommandNotRunning         = errors.New("Command: Process is not running")
+	ErrCommandRunning            = errors.New("Command: Process is running")
+	ErrCommandNoJobControl       = errors.New("Command: Process does not support job control")
+)
+
+// CommandError is the type of all errors returned by methods of Command.
+//
+// Err is either one of the ErrCommand values, or an error returned by the underlying process.
+// Use errors.Is() and errors.As() to inspect it.
+type CommandError struct {
+	Op      string       // the method of Command that failed, e.g. "Start"
+	Command string       // the String() of the command
+	State   CommandState // the state of the command when the method was called
+	Err     error        // the underlying error
+}
+
+func (ce *CommandError) Error() string {
+	if ce.Command == "" {
+		return ce.Op + ": " + ce.Err.Error()
+	}
+	return ce.Op + " " + ce.Command + ": " + ce.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (ce *CommandError) Unwrap() error {
+	return ce.Err
+}
+
+// wrapError wraps err into a *CommandError for the method op of e.
+// When err is nil, returns nil.
+func (e *Command) wrapError(op string, state CommandState, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &CommandError{
+		Op:      op,
+		Command: e.String(),
+		State:   state,
+		Err:     err,
+	}
+}
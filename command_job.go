@@ -0,0 +1,107 @@
+package procutil
+
+import (
+	"os"
+)
+
+// JobState is the state of a job, as returned by Command.WaitJob().
+type JobState int
+
+const (
+	// JobStopped indicates that the process has been stopped, for instance by Ctrl-Z or by Suspend().
+	JobStopped JobState = iota
+	// JobExited indicates that the process has exited.
+	JobExited
+)
+
+// jobControl calls f with the JobController of the underlying process, and wraps the returned error.
+// It returns an error unless the process is running and supports job control.
+// op is the method that called jobControl, used in the returned error.
+//
+// Like Stop(), e.m is held while f runs, so that the process can not exit and be cleaned up concurrently.
+func (e *Command) jobControl(op string, f func(jc JobController) error) error {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	if e.state != CommandStateStart && e.state != CommandStateWait {
+		return e.wrapError(op, e.state, ErrCommandNotRunning)
+	}
+
+	jc, ok := e.Process.(JobController)
+	if !ok {
+		return e.wrapError(op, e.state, ErrCommandNoJobControl)
+	}
+	return e.wrapError(op, e.state, f(jc))
+}
+
+// Signal sends sig to the underlying process.
+//
+// When the process runs on a pty, the signal is sent to the foreground process group of the pty.
+// This is the same process group that receives signals generated by the keyboard, such as Ctrl-C.
+//
+// When the process is not running or does not support job control, returns an error.
+func (e *Command) Signal(sig os.Signal) error {
+	return e.jobControl("Signal", func(jc JobController) error {
+		return jc.Signal(sig)
+	})
+}
+
+// Suspend stops the underlying process, see JobController.
+//
+// When the process is not running or does not support job control, returns an error.
+func (e *Command) Suspend() error {
+	return e.jobControl("Suspend", JobController.Suspend)
+}
+
+// Resume continues the underlying process after it has been stopped, see JobController.
+//
+// When the process is not running or does not support job control, returns an error.
+func (e *Command) Resume() error {
+	return e.jobControl("Resume", JobController.Resume)
+}
+
+// IsStopped checks if the underlying process is currently stopped.
+// When the process is not running or does not support job control, returns false.
+func (e *Command) IsStopped() (stopped bool) {
+	e.jobControl("IsStopped", func(jc JobController) error {
+		stopped, _ = jc.Stopped()
+		return nil
+	})
+	return
+}
+
+// WaitJob waits for the process to either stop or exit.
+//
+// When the process is stopped, returns JobStopped immediately.
+// Callers typically call Resume() before calling WaitJob() again.
+// When the process has exited, returns JobExited along with the same values as Wait().
+//
+// When the process does not support job control, WaitJob behaves like Wait().
+func (e *Command) WaitJob() (state JobState, code int, err error) {
+	if err := e.wait("WaitJob"); err != nil {
+		return JobExited, 0, err
+	}
+
+	jc, _ := e.Process.(JobController)
+	for {
+		select {
+		case <-e.waitChan:
+			return JobExited, e.waitExitCode, e.waitErr
+		default:
+		}
+
+		var changed <-chan struct{}
+		if jc != nil {
+			var stopped bool
+			if stopped, changed = jc.Stopped(); stopped {
+				return JobStopped, 0, nil
+			}
+		}
+
+		select {
+		case <-e.waitChan:
+			return JobExited, e.waitExitCode, e.waitErr
+		case <-changed:
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package procutil
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/tkw1536/procutil/term"
+)
+
+func TestCommand_WaitJob(t *testing.T) {
+	if !term.JobEventSupport {
+		t.Skip("OS not supported")
+	}
+
+	command := &Command{
+		Process: &ExecProcess{
+			Command: "/bin/sh",
+			Args:    []string{"-c", "kill -STOP $$; exit 3"},
+		},
+	}
+	if err := command.Init(context.Background(), false); err != nil {
+		t.Fatalf("Command.Init() returned %s", err)
+	}
+	if err := command.Start(ioutil.Discard, ioutil.Discard, strings.NewReader("")); err != nil {
+		t.Fatalf("Command.Start() returned %s", err)
+	}
+
+	if state, _, err := command.WaitJob(); state != JobStopped || err != nil {
+		t.Fatalf("Command.WaitJob() = (%v, %v), want JobStopped", state, err)
+	}
+	if !command.IsStopped() {
+		t.Error("Command.IsStopped() = false, want true")
+	}
+
+	if err := command.Resume(); err != nil {
+		t.Fatalf("Command.Resume() returned %s", err)
+	}
+	if state, code, err := command.WaitJob(); state != JobExited || code != 3 || err != nil {
+		t.Errorf("Command.WaitJob() = (%v, %d, %v), want (JobExited, 3, nil)", state, code, err)
+	}
+	if command.IsStopped() {
+		t.Error("Command.IsStopped() = true, want false")
+	}
+}
+
+func TestCommand_WaitJob_pty(t *testing.T) {
+	if !term.PTYSupport || !term.JobEventSupport {
+		t.Skip("OS not supported")
+	}
+
+	command := &Command{
+		Process: &ExecProcess{
+			Command: "sleep",
+			Args:    []string{"10"},
+		},
+	}
+	if err := command.Init(context.Background(), true); err != nil {
+		t.Fatalf("Command.Init() returned %s", err)
+	}
+	e, err := StartExpect(command, "dumb", nil)
+	if err != nil {
+		t.Fatalf("StartExpect() returned %s", err)
+	}
+
+	defer e.Close()
+
+	if err := command.Suspend(); err != nil {
+		t.Fatalf("Command.Suspend() returned %s", err)
+	}
+	if state, _, err := command.WaitJob(); state != JobStopped || err != nil {
+		t.Fatalf("Command.WaitJob() = (%v, %v), want JobStopped", state, err)
+	}
+
+	if err := command.Resume(); err != nil {
+		t.Fatalf("Command.Resume() returned %s", err)
+	}
+	// like pressing Ctrl-C
+	if err := command.Signal(os.Interrupt); err != nil {
+		t.Fatalf("Command.Signal() returned %s", err)
+	}
+	if state, code, err := command.WaitJob(); state != JobExited || code == 0 || err != nil {
+		t.Errorf("Command.WaitJob() = (%v, %d, %v), want JobExited with non-zero code", state, code, err)
+	}
+}
+
+func TestCommand_Suspend_notSupported(t *testing.T) {
+	command := &Command{Process: &testProcess{}}
+	if err := command.Suspend(); !errors.Is(err, ErrCommandNotRunning) {
+		t.Errorf("Command.Suspend() before Start() returned %v, want ErrCommandNotRunning", err)
+	}
+
+	command.Init(context.Background(), false)
+	command.Start(ioutil.Discard, ioutil.Discard, strings.NewReader(""))
+	if err := command.Suspend(); !errors.Is(err, ErrCommandNoJobControl) {
+		t.Errorf("Command.Suspend() returned %v, want ErrCommandNoJobControl", err)
+	}
+	command.Wait()
+}
+
+// Test that signals can be sent concurrently with the process exiting and being cleaned up.
+func TestCommand_Signal_cleanup(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		command := &Command{Process: &ExecProcess{Command: "true"}}
+
+		done := make(chan error)
+		go func() {
+			done <- command.Run(context.Background(), nil, nil, nil)
+		}()
+
+		for running := true; running; {
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("Run() returned %s", err)
+				}
+				running = false
+			default:
+				command.Signal(syscall.SIGCONT)
+			}
+		}
+	}
+}
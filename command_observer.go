@@ -0,0 +1,296 @@
+package procutil
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/tkw1536/procutil/term"
+)
+
+// EventType is the type of an Event
+type EventType int
+
+const (
+	// EventInit is emitted by every call to Command.Init()
+	EventInit EventType = iota
+	// EventStart is emitted by every call to Command.Start() or Command.StartPty()
+	EventStart
+	// EventWait is emitted when the command first starts waiting for the process
+	EventWait
+	// EventDone is emitted once the process has exited, along with the exit code
+	EventDone
+	// EventStop is emitted by every call to Command.Stop()
+	EventStop
+	// EventCleanup is emitted once the process has been cleaned up
+	EventCleanup
+	// EventResize is emitted whenever the pty of the process is resized
+	EventResize
+	// EventStreamDone is emitted once the command has finished copying one of the streams of the process
+	EventStreamDone
+	// EventFirstOutput is emitted when the process first writes to standard output or standard error
+	EventFirstOutput
+	// EventStreamData is emitted whenever the command has copied data on one of the streams of the process
+	EventStreamData
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventInit:
+		return "init"
+	case EventStart:
+		return "start"
+	case EventWait:
+		return "wait"
+	case EventDone:
+		return "done"
+	case EventStop:
+		return "stop"
+	case EventCleanup:
+		return "cleanup"
+	case EventResize:
+		return "resize"
+	case EventStreamDone:
+		return "stream done"
+	case EventFirstOutput:
+		return "first output"
+	case EventStreamData:
+		return "stream data"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Event describes a state transition of a Command, or an attempt at one.
+type Event struct {
+	Type EventType
+	Time time.Time // time the event occured
+
+	Process string // String() of the process
+	Kind    string // kind of the process, see ProcessKind()
+	Pid     int    // operating system process id, when the process implements PidProcess and has been started; 0 otherwise
+
+	ExitCode int           // exit code of the process, only set for EventDone
+	Duration time.Duration // time since the process was started, only set for EventDone
+	Started  bool          // the process was started successfully; only set for EventDone
+	Err      error         // error returned by the transition, if any
+
+	Size term.WindowSize // new size of the pty, only set for EventResize
+
+	Stream string // name of the stream, "stdin", "stdout" or "stderr"; only set for EventStreamData and EventStreamDone
+	Bytes  int64  // number of bytes copied, in total for EventStreamDone and by a single write for EventStreamData
+
+	Unwaited bool // the process was started, but was cleaned up without EventDone being sent; only set for EventCleanup
+}
+
+// Observer observes the events of a Command.
+//
+// Observe is called synchronously, in the order events occur, but never while the Command is locked.
+// This means that an Observer may call methods of the Command.
+// It should return quickly, as it delays the method that caused the event.
+// Wait() only returns once EventDone has been observed, so observers must not call Wait() when observing it.
+type Observer interface {
+	Observe(event Event)
+}
+
+// ObserverFunc is a function that implements Observer
+type ObserverFunc func(event Event)
+
+// Observe calls f(event)
+func (f ObserverFunc) Observe(event Event) {
+	f(event)
+}
+
+// PidProcess is implemented by processes that correspond to an operating system process.
+type PidProcess interface {
+	// Pid returns the process id, or 0 if the process has not been started.
+	Pid() int
+}
+
+// ProcessKind returns a short name describing the kind of process, for use in logs and metrics.
+//
+// It returns "exec" for an ExecProcess, "docker_exec" for a StreamingProcess using a DockerExecStreamer,
+// "stream" for any other StreamingProcess and "replay" for a ReplayProcess.
+// For a RecordingProcess, it returns the kind of the underlying process.
+// For any other process, it returns "other".
+func ProcessKind(process Process) string {
+	switch p := process.(type) {
+	case *ExecProcess:
+		return "exec"
+	case *StreamingProcess:
+		if _, ok := p.Streamer.(*DockerExecStreamer); ok {
+			return "docker_exec"
+		}
+		return "stream"
+	case *ReplayProcess:
+		return "replay"
+	case *RecordingProcess:
+		return ProcessKind(p.Process)
+	default:
+		return "other"
+	}
+}
+
+// processPid returns the pid of process, or 0 if it does not implement PidProcess.
+func processPid(process Process) int {
+	if pp, ok := process.(PidProcess); ok {
+		return pp.Pid()
+	}
+	return 0
+}
+
+// observerEntry is a registered observer.
+// It is a pointer, so that the same Observer can be registered several times and removed independently.
+type observerEntry struct {
+	Observer
+}
+
+// AddObserver registers o to receive the events of this command.
+// Observers receive events in the order they were added.
+//
+// The returned function removes o again; calling it more than once has no effect.
+func (e *Command) AddObserver(o Observer) (remove func()) {
+	entry := &observerEntry{o}
+
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	e.addObserver(entry)
+	return func() {
+		e.m.Lock()
+		defer e.m.Unlock()
+
+		for i, other := range e.observers {
+			if other == entry {
+				// copy, as notify() may be iterating over the old slice
+				e.observers = append(append([]*observerEntry(nil), e.observers[:i]...), e.observers[i+1:]...)
+				atomic.StoreInt32(&e.observerCount, int32(len(e.observers)))
+				return
+			}
+		}
+	}
+}
+
+// addObserver registers entry.
+// e.m must be held by the caller.
+func (e *Command) addObserver(entry *observerEntry) {
+	e.observers = append(e.observers, entry)
+	atomic.StoreInt32(&e.observerCount, int32(len(e.observers)))
+}
+
+// notify sends an event of type typ to all observers.
+// err is the error of the transition, if any.
+//
+// e.m must not be held by the caller.
+func (e *Command) notify(typ EventType, err error) {
+	e.notifyEvent(Event{Type: typ, Err: err})
+}
+
+// hasObservers checks if any observers are registered, without locking the command.
+// It is used to avoid the cost of events on every write to a stream when nobody observes them.
+func (e *Command) hasObservers() bool {
+	return atomic.LoadInt32(&e.observerCount) > 0
+}
+
+// notifyEvent fills in the common fields of event, and sends it to all observers.
+//
+// e.m must not be held by the caller.
+func (e *Command) notifyEvent(event Event) {
+	if !e.hasObservers() {
+		return
+	}
+
+	e.m.Lock()
+	observers, pid, started := e.observers, e.pid, e.started
+	e.m.Unlock()
+
+	if len(observers) == 0 {
+		return
+	}
+
+	event.Time = time.Now()
+	event.Process = e.String()
+	event.Kind = ProcessKind(e.Process)
+	event.Pid = pid
+	if event.Type == EventDone && !started.IsZero() {
+		event.Duration = event.Time.Sub(started)
+	}
+
+	for _, o := range observers {
+		o.Observe(event)
+	}
+}
+
+// copyStream copies src to dst, and notifies observers with an EventStreamDone for stream once done.
+// Observers are notified with an EventStreamData for every write to dst.
+// For output streams, observers are notified with EventFirstOutput when the first output of the command is written.
+func (e *Command) copyStream(stream string, dst io.Writer, src io.Reader) {
+	dst = streamDataWriter{e: e, stream: stream, Writer: dst}
+	if stream != "stdin" {
+		dst = firstOutputWriter{e: e, Writer: dst}
+	}
+	n, err := io.Copy(dst, src)
+	e.notifyEvent(Event{Type: EventStreamDone, Stream: stream, Bytes: n, Err: err})
+}
+
+// firstOutputWriter notifies observers of a command with EventFirstOutput before the first non-empty write to an output stream
+type firstOutputWriter struct {
+	e *Command
+	io.Writer
+}
+
+func (fw firstOutputWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		fw.e.firstOutput.Do(func() { fw.e.notify(EventFirstOutput, nil) })
+	}
+	return fw.Writer.Write(p)
+}
+
+// streamDataWriter notifies observers of a command with an EventStreamData after every non-empty write to a stream
+type streamDataWriter struct {
+	e      *Command
+	stream string
+	io.Writer
+}
+
+func (sw streamDataWriter) Write(p []byte) (int, error) {
+	n, err := sw.Writer.Write(p)
+	if n > 0 && sw.e.hasObservers() {
+		sw.e.notifyEvent(Event{Type: EventStreamData, Stream: sw.stream, Bytes: int64(n)})
+	}
+	return n, err
+}
+
+// observeResizes forwards sizes received from resizeChan, and notifies observers with an EventResize for each of them.
+// It may be called while e.m is held.
+// When the receiver is slow, only the latest size is kept.
+// The returned channel is closed once resizeChan is closed.
+func (e *Command) observeResizes(resizeChan <-chan term.WindowSize) <-chan term.WindowSize {
+	out := make(chan term.WindowSize, 1)
+
+	// forward a size that is already available immediately, as processes may check for an initial size without blocking.
+	var initial *term.WindowSize
+	select {
+	case size, ok := <-resizeChan:
+		if !ok {
+			close(out)
+			return out
+		}
+		out <- size
+		initial = &size
+	default:
+	}
+
+	go func() {
+		defer close(out)
+		if initial != nil {
+			e.notifyEvent(Event{Type: EventResize, Size: *initial})
+		}
+		for size := range resizeChan {
+			e.notifyEvent(Event{Type: EventResize, Size: size})
+			term.SendLatest(out, size)
+		}
+	}()
+	return out
+}
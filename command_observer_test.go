@@ -0,0 +1,162 @@
+package procutil
+
+import (
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventRecorder records all events it observes
+type eventRecorder struct {
+	m      sync.Mutex
+	events []Event
+}
+
+func (er *eventRecorder) Observe(event Event) {
+	er.m.Lock()
+	defer er.m.Unlock()
+
+	er.events = append(er.events, event)
+}
+
+// Events returns all events observed so far, for which filter returns true
+func (er *eventRecorder) Events(filter func(event Event) bool) (events []Event) {
+	er.m.Lock()
+	defer er.m.Unlock()
+
+	for _, event := range er.events {
+		if filter(event) {
+			events = append(events, event)
+		}
+	}
+	return
+}
+
+// isLifecycle checks if event is a state transition
+func isLifecycle(event Event) bool {
+	return event.Type != EventStreamData && event.Type != EventStreamDone && event.Type != EventResize
+}
+
+// Types returns the types of all state transitions observed, waiting up to a second for there to be at least n of them.
+func (er *eventRecorder) Types(n int) (types []EventType) {
+	deadline := time.Now().Add(time.Second)
+	for len(er.Events(isLifecycle)) < n && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, event := range er.Events(isLifecycle) {
+		types = append(types, event.Type)
+	}
+	return
+}
+
+func TestCommand_AddObserver(t *testing.T) {
+	command := &Command{Process: &testProcess{ExitCode: 3}}
+
+	var recorder eventRecorder
+	command.AddObserver(&recorder)
+
+	// an observer that is removed immediately
+	var removed eventRecorder
+	command.AddObserver(&removed)()
+
+	command.Stop() // not running
+	command.Init(nil, false)
+	command.Start(ioutil.Discard, ioutil.Discard, strings.NewReader(""))
+	command.Wait()
+
+	wantTypes := []EventType{EventStop, EventInit, EventStart, EventWait, EventDone, EventCleanup}
+	if got := recorder.Types(len(wantTypes)); !reflect.DeepEqual(got, wantTypes) {
+		t.Errorf("observed events %v, want %v", got, wantTypes)
+	}
+
+	events := recorder.Events(isLifecycle)
+	if !errors.Is(events[0].Err, ErrCommandNotRunning) {
+		t.Errorf("EventStop has error %v, want ErrCommandNotRunning", events[0].Err)
+	}
+	if done := events[4]; done.ExitCode != 3 || done.Err != nil || done.Process != "TestProcess" || done.Kind != "other" || done.Time.IsZero() {
+		t.Errorf("EventDone = %+v, want exit code 3 of TestProcess", done)
+	}
+
+	if got := removed.Events(func(Event) bool { return true }); len(got) != 0 {
+		t.Errorf("removed observer observed %d events, want none", len(got))
+	}
+}
+
+func TestCommand_AddObserver_pid(t *testing.T) {
+	command := &Command{Process: &ExecProcess{Command: "true"}}
+
+	pids := make(chan int, 10)
+	command.AddObserver(ObserverFunc(func(event Event) {
+		if event.Type == EventDone {
+			pids <- event.Pid
+		}
+	}))
+
+	command.Init(nil, false)
+	command.Start(ioutil.Discard, ioutil.Discard, strings.NewReader(""))
+	command.Wait()
+
+	if pid := <-pids; pid <= 0 {
+		t.Errorf("EventDone has pid %d, want pid of process", pid)
+	}
+}
+
+func TestCommand_AddObserver_streams(t *testing.T) {
+	command := &Command{Process: &testProcess{Out: "output", Err: "error"}}
+
+	var recorder eventRecorder
+	command.AddObserver(&recorder)
+
+	command.Init(nil, false)
+	command.Start(ioutil.Discard, ioutil.Discard, strings.NewReader("input!"))
+	command.Wait()
+
+	isStream := func(event Event) bool { return event.Type == EventStreamDone }
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.Events(isStream)) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	got := make(map[string]int64)
+	for _, event := range recorder.Events(isStream) {
+		got[event.Stream] = event.Bytes
+	}
+	want := map[string]int64{"stdin": 6, "stdout": 6, "stderr": 5}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("observed stream bytes %v, want %v", got, want)
+	}
+
+	// all data events are sent before the corresponding EventStreamDone
+	data := make(map[string]int64)
+	for _, event := range recorder.Events(func(event Event) bool { return event.Type == EventStreamData }) {
+		data[event.Stream] += event.Bytes
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("observed stream data %v, want %v", data, want)
+	}
+}
+
+func TestCommand_hasObservers(t *testing.T) {
+	var command Command
+	if command.hasObservers() {
+		t.Error("hasObservers() = true without observers")
+	}
+
+	removeFirst := command.AddObserver(&eventRecorder{})
+	removeSecond := command.AddObserver(&eventRecorder{})
+	removeFirst()
+	removeFirst()
+	if !command.hasObservers() {
+		t.Error("hasObservers() = false with a remaining observer")
+	}
+
+	removeSecond()
+	if command.hasObservers() {
+		t.Error("hasObservers() = true after removing all observers")
+	}
+}
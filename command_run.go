@@ -0,0 +1,231 @@
+package procutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExitError is returned by Run(), Output() and CombinedOutput() when the process exits with a non-zero exit code.
+// It is wrapped inside a *CommandError, and should be retrieved using errors.As().
+type ExitError struct {
+	ExitCode int    // exit code of the process
+	Stderr   []byte // the last output the process wrote to standard error, at most ExitErrorStderrSize bytes
+}
+
+// ExitErrorStderrSize is the maximum number of bytes of standard error kept in an ExitError
+const ExitErrorStderrSize = 4096
+
+func (ee *ExitError) Error() string {
+	return "exit code " + strconv.Itoa(ee.ExitCode)
+}
+
+// ErrCommandOutputLimit is returned by Output() and CombinedOutput() when the output of the process exceeded Command.OutputLimit.
+// Like other errors of Command, it is wrapped inside a *CommandError.
+var ErrCommandOutputLimit = errors.New("Command: Output limit exceeded")
+
+// Run initializes and starts the process, waits for it to exit and cleans it up.
+// Output of the process is copied to Out and Err, and In is copied to its input.
+// When any of the streams are nil, input is empty and output is discarded.
+//
+// Once the context is closed, the process will be killed.
+// Cleanup is always called before Run returns, unless the process could not be initialized.
+//
+// When the process exits with a non-zero exit code, the returned error wraps an *ExitError.
+// When the process was killed because ctx was closed, the returned error wraps ctx.Err() instead.
+//
+// Run can not be used on a command that has already been initialized.
+func (e *Command) Run(ctx context.Context, Out, Err io.Writer, In io.Reader) error {
+	return e.run("Run", ctx, Out, Err, In)
+}
+
+// Output is like Run(), but returns the standard output of the process.
+//
+// When OutputLimit is positive, at most OutputLimit bytes are returned and the rest is discarded.
+// When this happens, and the process exited successfully, the returned error wraps ErrCommandOutputLimit.
+func (e *Command) Output(ctx context.Context, In io.Reader) ([]byte, error) {
+	stdout := &limitBuffer{limit: e.OutputLimit}
+	err := e.run("Output", ctx, stdout, nil, In)
+	return stdout.Bytes(), e.checkLimit("Output", err, stdout)
+}
+
+// CombinedOutput is like Output(), but returns both standard output and standard error of the process.
+func (e *Command) CombinedOutput(ctx context.Context, In io.Reader) ([]byte, error) {
+	output := &limitBuffer{limit: e.OutputLimit}
+	err := e.run("CombinedOutput", ctx, output, output, In)
+	return output.Bytes(), e.checkLimit("CombinedOutput", err, output)
+}
+
+// run implements Run, Output and CombinedOutput.
+// op is the method that called run, used in the returned error.
+func (e *Command) run(op string, ctx context.Context, Out, Err io.Writer, In io.Reader) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if Out == nil {
+		Out = ioutil.Discard
+	}
+	if Err == nil {
+		Err = ioutil.Discard
+	}
+	if In == nil {
+		In = strings.NewReader("")
+	}
+
+	if err := e.Init(ctx, false); err != nil {
+		return err
+	}
+	defer e.Cleanup()
+
+	stderr := NewRingBuffer(ExitErrorStderrSize)
+	if err := e.Start(Out, io.MultiWriter(Err, stderr), In); err != nil {
+		e.abort()
+		return err
+	}
+
+	code, err := e.Wait()
+	if err != nil {
+		return err
+	}
+
+	// wait for all output to be copied, so that it is available to the caller
+	e.waitOutputs()
+
+	if code == 0 {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return e.wrapError(op, CommandStateDone, ctx.Err())
+	}
+	return e.wrapError(op, CommandStateDone, &ExitError{ExitCode: code, Stderr: stderr.Bytes()})
+}
+
+// DefaultWaitDelay is the default value of Command.WaitDelay
+const DefaultWaitDelay = time.Second
+
+// waitOutputs waits for all output of a started process to be copied.
+//
+// Output streams only reach EOF once every process holding them open has exited, including children of the process.
+// Once the process has exited, output is therefore only waited for up to WaitDelay.
+// The output streams are then closed, and any output not yet copied is lost.
+func (e *Command) waitOutputs() {
+	done := make(chan struct{})
+	go func() {
+		e.outputs.Wait()
+		close(done)
+	}()
+
+	delay := e.WaitDelay
+	if delay == 0 {
+		delay = DefaultWaitDelay
+	}
+	if delay < 0 {
+		<-done
+		return
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return
+	case <-timer.C:
+	}
+
+	e.m.Lock()
+	pipes := e.outputPipes
+	e.m.Unlock()
+
+	for _, pipe := range pipes {
+		pipe.Close()
+	}
+	<-done
+}
+
+// checkLimit returns an error wrapping ErrCommandOutputLimit when buffer was truncated and err is nil.
+// Otherwise returns err.
+func (e *Command) checkLimit(op string, err error, buffer *limitBuffer) error {
+	if err == nil && buffer.Truncated() {
+		return e.wrapError(op, CommandStateDone, ErrCommandOutputLimit)
+	}
+	return err
+}
+
+// abort marks a command whose process has been initialized, but was not started successfully, as done.
+// It then cleans up the command.
+func (e *Command) abort() {
+	e.m.Lock()
+	if e.state == CommandStateInit || e.state == CommandStateStart {
+		e.state = CommandStateDone
+	}
+	e.m.Unlock()
+
+	e.Cleanup()
+}
+
+// abortCommands aborts a group of commands that failed to start.
+// The commands before index started are stopped, waited for and cleaned up.
+// The remaining commands, which have not been started successfully, are aborted.
+func abortCommands(commands []*Command, started int) {
+	for _, command := range commands[:started] {
+		command.Stop()
+	}
+	for _, command := range commands[:started] {
+		command.Wait()
+		command.Cleanup()
+	}
+	for _, command := range commands[started:] {
+		command.abort()
+	}
+}
+
+// limitBuffer is a buffer that keeps at most limit bytes, and discards the rest.
+// When limit is not positive, all bytes are kept.
+// It is safe for concurrent use.
+type limitBuffer struct {
+	limit int
+
+	m         sync.Mutex
+	buffer    bytes.Buffer
+	truncated bool
+}
+
+// Write writes p to the buffer.
+// It never fails, so that the process is not blocked once the limit is reached.
+func (lb *limitBuffer) Write(p []byte) (int, error) {
+	lb.m.Lock()
+	defer lb.m.Unlock()
+
+	data := p
+	if lb.limit > 0 {
+		if space := lb.limit - lb.buffer.Len(); len(data) > space {
+			data = data[:space]
+			lb.truncated = true
+		}
+	}
+	lb.buffer.Write(data)
+	return len(p), nil
+}
+
+// Bytes returns the content of this buffer
+func (lb *limitBuffer) Bytes() []byte {
+	lb.m.Lock()
+	defer lb.m.Unlock()
+
+	return lb.buffer.Bytes()
+}
+
+// Truncated returns if any bytes have been discarded
+func (lb *limitBuffer) Truncated() bool {
+	lb.m.Lock()
+	defer lb.m.Unlock()
+
+	return lb.truncated
+}
@@ -0,0 +1,221 @@
+package procutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommand_Run(t *testing.T) {
+	tests := []struct {
+		name       string
+		process    *testProcess
+		wantOut    string
+		wantErr    string
+		wantExit   *ExitError
+		wantString string
+	}{
+		{"successful process", &testProcess{Out: "out", Err: "err"}, "out", "err", nil, ""},
+		{"failing process", &testProcess{Out: "out", Err: "err", ExitCode: 3}, "out", "err", &ExitError{ExitCode: 3, Stderr: []byte("err")}, "Run TestProcess: exit code 3"},
+		{"long stderr", &testProcess{Err: strings.Repeat("a", ExitErrorStderrSize) + "tail", ExitCode: 1}, "", strings.Repeat("a", ExitErrorStderrSize) + "tail", &ExitError{ExitCode: 1, Stderr: []byte(strings.Repeat("a", ExitErrorStderrSize-4) + "tail")}, "Run TestProcess: exit code 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := &Command{Process: tt.process}
+
+			var stdout, stderr bytes.Buffer
+			err := command.Run(context.Background(), &stdout, &stderr, strings.NewReader("input"))
+
+			if got := stdout.String(); got != tt.wantOut {
+				t.Errorf("Run() wrote stdout %q, want %q", got, tt.wantOut)
+			}
+			if got := stderr.String(); got != tt.wantErr {
+				t.Errorf("Run() wrote stderr %q, want %q", got, tt.wantErr)
+			}
+			if got := tt.process.in.String(); got != "input" {
+				t.Errorf("Run() wrote stdin %q, want \"input\"", got)
+			}
+			if !tt.process.CleanupCalled {
+				t.Error("Run() did not call Cleanup()")
+			}
+
+			if tt.wantExit == nil {
+				if err != nil {
+					t.Errorf("Run() returned %v, want nil", err)
+				}
+				return
+			}
+
+			var exitErr *ExitError
+			if !errors.As(err, &exitErr) {
+				t.Fatalf("Run() returned %v, want an *ExitError", err)
+			}
+			if !reflect.DeepEqual(exitErr, tt.wantExit) {
+				t.Errorf("Run() returned %#v, want %#v", exitErr, tt.wantExit)
+			}
+			if err.Error() != tt.wantString {
+				t.Errorf("Run() returned %q, want %q", err.Error(), tt.wantString)
+			}
+		})
+	}
+}
+
+func TestCommand_Output(t *testing.T) {
+	tests := []struct {
+		name     string
+		combined bool
+		limit    int
+		want     string
+		wantErr  error
+	}{
+		{"output", false, 0, "out\n", nil},
+		{"output within limit", false, 4, "out\n", nil},
+		{"output exceeding limit", false, 2, "ou", ErrCommandOutputLimit},
+		{"combined output", true, 0, "out\nerr\n", nil},
+		{"combined output exceeding limit", true, 5, "out\ne", ErrCommandOutputLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := &Command{
+				Process: &ExecProcess{
+					Command: "/bin/sh",
+					Args:    []string{"-c", "echo out; sleep 0.1; echo err >&2"},
+				},
+				OutputLimit: tt.limit,
+			}
+
+			var got []byte
+			var err error
+			if tt.combined {
+				got, err = command.CombinedOutput(context.Background(), nil)
+			} else {
+				got, err = command.Output(context.Background(), nil)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("Output() = %q, want %q", got, tt.want)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Output() returned error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCommand_Output_exitError(t *testing.T) {
+	command := &Command{
+		Process: &ExecProcess{
+			Command: "/bin/sh",
+			Args:    []string{"-c", "echo out; echo err >&2; exit 2"},
+		},
+	}
+
+	got, err := command.Output(context.Background(), nil)
+	if string(got) != "out\n" {
+		t.Errorf("Output() = %q, want \"out\\n\"", got)
+	}
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode != 2 || string(exitErr.Stderr) != "err\n" {
+		t.Errorf("Output() returned error %#v, want an *ExitError with code 2", err)
+	}
+}
+
+func TestCommand_Run_context(t *testing.T) {
+	command := &Command{
+		Process: &ExecProcess{
+			Command: "sleep",
+			Args:    []string{"10"},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err := command.Run(ctx, nil, nil, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run() returned %v, want context.DeadlineExceeded", err)
+	}
+}
+
+// Test that Run returns once the context is closed, even when a child of the process keeps its output open.
+func TestCommand_Run_contextGrandchild(t *testing.T) {
+	command := &Command{
+		Process: &ExecProcess{
+			Command: "/bin/sh",
+			Args:    []string{"-c", "sleep 5 & echo hi; sleep 10"},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	var out bytes.Buffer
+	start := time.Now()
+	err := command.Run(ctx, &out, nil, nil)
+	if took := time.Since(start); took > 3*time.Second {
+		t.Errorf("Run() took %s, want it to return after the default WaitDelay", took)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run() returned %v, want context.DeadlineExceeded", err)
+	}
+	if out.String() != "hi\n" {
+		t.Errorf("Run() wrote %q, want \"hi\\n\"", out.String())
+	}
+}
+
+// Test that Run returns once the process has exited, even when a child of the process keeps its output open.
+func TestCommand_Run_grandchild(t *testing.T) {
+	command := &Command{
+		Process: &ExecProcess{
+			Command: "/bin/sh",
+			Args:    []string{"-c", "sleep 5 & echo hi"},
+		},
+		WaitDelay: 100 * time.Millisecond,
+	}
+
+	var out bytes.Buffer
+	start := time.Now()
+	err := command.Run(context.Background(), &out, nil, nil)
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("Run() took %s, want it to return after WaitDelay", took)
+	}
+	if err != nil {
+		t.Errorf("Run() returned %v, want nil", err)
+	}
+	if out.String() != "hi\n" {
+		t.Errorf("Run() wrote %q, want \"hi\\n\"", out.String())
+	}
+}
+
+func TestCommand_Run_startFailure(t *testing.T) {
+	command := &Command{
+		Process: &ExecProcess{
+			Command: "true",
+			Workdir: "/this/directory/does/not/exist",
+		},
+	}
+
+	if err := command.Run(context.Background(), nil, nil, nil); err == nil {
+		t.Error("Run() returned nil, want an error")
+	}
+	if state := command.State(); state != CommandStateDone {
+		t.Errorf("command has state %v, want %v", state, CommandStateDone)
+	}
+	if err := command.Cleanup(); err != nil {
+		t.Errorf("Cleanup() returned %v, want nil", err)
+	}
+}
+
+func TestCommand_Run_initialized(t *testing.T) {
+	command := &Command{Process: &testProcess{}}
+	command.Init(nil, false)
+
+	if err := command.Run(context.Background(), nil, nil, nil); !errors.Is(err, ErrCommandAlreadyInitialized) {
+		t.Errorf("Run() returned %v, want ErrCommandAlreadyInitialized", err)
+	}
+}
@@ -3,7 +3,9 @@ package procutil
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
+	"io/ioutil"
 	"strings"
 	"testing"
 
@@ -239,3 +241,118 @@ func TestCommandNoTty(t *testing.T) {
 		})
 	}
 }
+
+func TestCommand_errors(t *testing.T) {
+	start := func(command *Command) error {
+		return command.Start(ioutil.Discard, ioutil.Discard, strings.NewReader(""))
+	}
+
+	tests := []struct {
+		name      string
+		isPty     bool
+		setup     func(command *Command) // called after Init(), unless nil
+		call      func(command *Command) error
+		wantOp    string
+		wantState CommandState
+		wantErr   error
+	}{
+		{
+			name:      "Init twice",
+			setup:     func(command *Command) {},
+			call:      func(command *Command) error { return command.Init(nil, false) },
+			wantOp:    "Init",
+			wantState: CommandStateInit,
+			wantErr:   ErrCommandAlreadyInitialized,
+		},
+		{
+			name:      "Start before Init",
+			call:      start,
+			wantOp:    "Start",
+			wantState: CommandStateDefault,
+			wantErr:   ErrCommandNotInitialized,
+		},
+		{
+			name:      "Start twice",
+			setup:     func(command *Command) { start(command) },
+			call:      start,
+			wantOp:    "Start",
+			wantState: CommandStateStart,
+			wantErr:   ErrCommandAlreadyStarted,
+		},
+		{
+			name:      "Start on a pty",
+			isPty:     true,
+			setup:     func(command *Command) {},
+			call:      start,
+			wantOp:    "Start",
+			wantState: CommandStateInit,
+			wantErr:   ErrCommandIsATerminal,
+		},
+		{
+			name:      "StartPty without a pty",
+			setup:     func(command *Command) {},
+			call:      func(command *Command) error { return command.StartPty(nil, "", nil) },
+			wantOp:    "StartPty",
+			wantState: CommandStateInit,
+			wantErr:   ErrCommandNotATerminal,
+		},
+		{
+			name:      "Wait before Start",
+			setup:     func(command *Command) {},
+			call:      func(command *Command) error { _, err := command.Wait(); return err },
+			wantOp:    "Wait",
+			wantState: CommandStateInit,
+			wantErr:   ErrCommandNotRunning,
+		},
+		{
+			name:      "Stop before Start",
+			setup:     func(command *Command) {},
+			call:      func(command *Command) error { return command.Stop() },
+			wantOp:    "Stop",
+			wantState: CommandStateInit,
+			wantErr:   ErrCommandNotRunning,
+		},
+		{
+			name:      "Cleanup while running",
+			setup:     func(command *Command) { start(command) },
+			call:      func(command *Command) error { return command.Cleanup() },
+			wantOp:    "Cleanup",
+			wantState: CommandStateStart,
+			wantErr:   ErrCommandRunning,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := &Command{Process: &testProcess{}}
+			if tt.setup != nil {
+				command.Init(nil, tt.isPty)
+				tt.setup(command)
+			}
+
+			err := tt.call(command)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			var ce *CommandError
+			if !errors.As(err, &ce) {
+				t.Fatalf("got error of type %T, want *CommandError", err)
+			}
+			if ce.Op != tt.wantOp || ce.State != tt.wantState || ce.Command != "TestProcess" {
+				t.Errorf("got CommandError{Op: %q, State: %s, Command: %q}, want {Op: %q, State: %s, Command: \"TestProcess\"}", ce.Op, ce.State, ce.Command, tt.wantOp, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestCommand_Stop_done(t *testing.T) {
+	command := &Command{Process: &testProcess{}}
+	command.Init(nil, false)
+	command.Start(ioutil.Discard, ioutil.Discard, strings.NewReader(""))
+	command.Wait()
+
+	if err := command.Stop(); err != nil {
+		t.Errorf("Command.Stop() after Wait() returned %v, want nil", err)
+	}
+}
@@ -0,0 +1,316 @@
+package procutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tkw1536/procutil/term"
+)
+
+// Expect scripts an interactive command running on a pty.
+// It allows waiting for the command to produce specific output and sending input in response.
+//
+// At most ExpectBufferSize bytes of output that has not yet been consumed by a match are kept.
+// When the command produces more output, the oldest output is discarded and can no longer be matched.
+// Similarly, only the last ExpectTranscriptSize bytes of output are kept in the transcript.
+//
+// An Expect is safe to be used by multiple goroutines, but only one goroutine should call Expect() at a time.
+type Expect struct {
+	command *Command
+
+	inR *io.PipeReader // read by the process
+	inW *io.PipeWriter // written to by Send()
+
+	m          sync.Mutex
+	buffer     []byte        // output that has not yet been consumed by a match
+	transcript *RingBuffer   // the last output produced by the process
+	eof        bool          // has the process closed its output?
+	changed    chan struct{} // closed and replaced whenever the output changes
+}
+
+// StartExpect starts command on a pty and returns an Expect to interact with it.
+// The command must have been initialized to run on a pty.
+// TERM and resizeChan are passed to Command.StartPty().
+func StartExpect(command *Command, TERM string, resizeChan <-chan term.WindowSize) (*Expect, error) {
+	e := &Expect{
+		command:    command,
+		changed:    make(chan struct{}),
+		transcript: NewRingBuffer(ExpectTranscriptSize),
+	}
+	e.inR, e.inW = io.Pipe()
+
+	if err := command.StartPty(expectPty{e}, TERM, resizeChan); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Limits on the output kept by an Expect
+const (
+	ExpectBufferSize     = 64 * 1024 // maximum number of bytes of output that has not yet been matched
+	ExpectTranscriptSize = 64 * 1024 // maximum number of bytes of output kept in the transcript
+)
+
+// ExpectPattern is a pattern that can be waited for using Expect.
+type ExpectPattern interface {
+	fmt.Stringer
+
+	// FindSubmatchIndex returns the position of the leftmost match of this pattern in data,
+	// along with the positions of any groups, using the same semantics as regexp.FindSubmatchIndex.
+	// Returns nil when there is no match.
+	FindSubmatchIndex(data []byte) []int
+}
+
+// ExpectLiteral returns a pattern that matches the string s literally.
+func ExpectLiteral(s string) ExpectPattern {
+	return expectLiteral(s)
+}
+
+type expectLiteral string
+
+func (el expectLiteral) String() string {
+	return fmt.Sprintf("%q", string(el))
+}
+
+func (el expectLiteral) FindSubmatchIndex(data []byte) []int {
+	index := bytes.Index(data, []byte(el))
+	if index < 0 {
+		return nil
+	}
+	return []int{index, index + len(el)}
+}
+
+// ExpectRegexp returns a pattern that matches the regular expression re.
+// Groups of re are returned in ExpectMatch.Groups.
+func ExpectRegexp(re *regexp.Regexp) ExpectPattern {
+	return expectRegexp{re}
+}
+
+type expectRegexp struct {
+	*regexp.Regexp
+}
+
+func (er expectRegexp) String() string {
+	return "/" + er.Regexp.String() + "/"
+}
+
+// ExpectEOF is a pattern that matches once the command has closed its output.
+// It matches all remaining output.
+var ExpectEOF ExpectPattern = expectEOF{}
+
+type expectEOF struct{}
+
+func (expectEOF) String() string                      { return "EOF" }
+func (expectEOF) FindSubmatchIndex(data []byte) []int { return nil }
+
+// ExpectMatch describes a successful call to Expect.
+type ExpectMatch struct {
+	Index  int      // index of the pattern that matched
+	Before string   // output between the previous match and this match
+	Groups []string // text of the match (Groups[0]) followed by any groups
+}
+
+// ErrExpectTimeout is returned (wrapped in an ExpectError) when Expect times out.
+var ErrExpectTimeout = errors.New("Expect: Timeout")
+
+// ExpectError is returned when Expect fails.
+type ExpectError struct {
+	Patterns   []string // the patterns that were being waited for
+	Err        error    // either ErrExpectTimeout or io.EOF
+	Transcript string   // the last output of the command, see Expect.Transcript()
+}
+
+func (ee *ExpectError) Error() string {
+	return fmt.Sprintf("Expect: %s waiting for %s; transcript:\n%s", ee.Err, strings.Join(ee.Patterns, ", "), ee.Transcript)
+}
+
+// Unwrap returns the underlying error.
+func (ee *ExpectError) Unwrap() error {
+	return ee.Err
+}
+
+// Expect waits for the command to produce output matching any of the given patterns.
+//
+// When several patterns match, the one matching the earliest output wins, and ties are broken by the order of patterns.
+// Output up to and including the match is consumed, and will not be matched by subsequent calls.
+//
+// When timeout is positive and no pattern matched within timeout, returns an *ExpectError wrapping ErrExpectTimeout.
+// When the command closes its output before a match, returns an *ExpectError wrapping io.EOF,
+// unless ExpectEOF is one of the patterns.
+func (e *Expect) Expect(timeout time.Duration, patterns ...ExpectPattern) (*ExpectMatch, error) {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		e.m.Lock()
+		match := e.match(patterns)
+		eof, changed := e.eof, e.changed
+		e.m.Unlock()
+
+		if match != nil {
+			return match, nil
+		}
+		if eof {
+			return nil, e.fail(patterns, io.EOF)
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return nil, e.fail(patterns, ErrExpectTimeout)
+		}
+	}
+}
+
+// match attempts to match patterns against the buffer, and consumes the match.
+// e.m must be held by the caller.
+func (e *Expect) match(patterns []ExpectPattern) *ExpectMatch {
+	var best []int
+	var index int
+	for i, pattern := range patterns {
+		if pattern == ExpectEOF {
+			continue
+		}
+
+		loc := pattern.FindSubmatchIndex(e.buffer)
+		if loc != nil && (best == nil || loc[0] < best[0]) {
+			best, index = loc, i
+		}
+	}
+
+	// only match EOF when nothing else matched
+	if best == nil && e.eof {
+		for i, pattern := range patterns {
+			if pattern == ExpectEOF {
+				best, index = []int{0, len(e.buffer)}, i
+				break
+			}
+		}
+	}
+
+	if best == nil {
+		return nil
+	}
+
+	match := &ExpectMatch{
+		Index:  index,
+		Before: string(e.buffer[:best[0]]),
+		Groups: make([]string, len(best)/2),
+	}
+	for i := range match.Groups {
+		if best[2*i] >= 0 {
+			match.Groups[i] = string(e.buffer[best[2*i]:best[2*i+1]])
+		}
+	}
+
+	e.buffer = e.buffer[best[1]:]
+	return match
+}
+
+func (e *Expect) fail(patterns []ExpectPattern, err error) error {
+	names := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		names[i] = pattern.String()
+	}
+
+	return &ExpectError{
+		Patterns:   names,
+		Err:        err,
+		Transcript: e.Transcript(),
+	}
+}
+
+// Send sends s as input to the command.
+func (e *Expect) Send(s string) error {
+	_, err := io.WriteString(e.inW, s)
+	return err
+}
+
+// SendLine sends s followed by a carriage return, as if the user had typed s and pressed enter.
+func (e *Expect) SendLine(s string) error {
+	return e.Send(s + "\r")
+}
+
+// Transcript returns the output produced by the command so far.
+// Only the last ExpectTranscriptSize bytes are kept.
+// Input is not part of the transcript, unless the terminal echoed it.
+func (e *Expect) Transcript() string {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	return e.transcript.String()
+}
+
+// Close closes the input of the command.
+func (e *Expect) Close() error {
+	return e.inW.Close()
+}
+
+// Wait closes the input of the command and waits for it to exit.
+func (e *Expect) Wait() (int, error) {
+	e.Close()
+	return e.command.Wait()
+}
+
+// output is called when the command produces output
+func (e *Expect) output(p []byte) {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	e.buffer = append(e.buffer, p...)
+	if over := len(e.buffer) - ExpectBufferSize; over > 0 {
+		e.buffer = append(e.buffer[:0], e.buffer[over:]...)
+	}
+	e.transcript.Write(p)
+	e.notify()
+}
+
+// finish is called when the command closes its output
+func (e *Expect) finish() {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	e.eof = true
+	e.notify()
+}
+
+// notify notifies waiting calls to Expect of changes.
+// e.m must be held by the caller.
+func (e *Expect) notify() {
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+// expectPty is the terminal side of the pty of an Expect.
+// It implements io.ReadWriteCloser and DualCloser.
+type expectPty struct {
+	e *Expect
+}
+
+func (ep expectPty) Read(p []byte) (int, error) {
+	return ep.e.inR.Read(p)
+}
+
+func (ep expectPty) Write(p []byte) (int, error) {
+	ep.e.output(p)
+	return len(p), nil
+}
+
+func (ep expectPty) Close() error {
+	return ep.e.inR.Close()
+}
+
+func (ep expectPty) CloseWrite() error {
+	ep.e.finish()
+	return nil
+}
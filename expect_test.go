@@ -0,0 +1,102 @@
+package procutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tkw1536/procutil/term"
+)
+
+func TestExpect(t *testing.T) {
+	if !term.PTYSupport {
+		t.Skip("OS not supported")
+	}
+
+	command := &Command{
+		Process: &ExecProcess{
+			Command: "/bin/sh",
+			Args:    []string{"-c", `printf 'Name? '; read name; echo "Hello, $name!"`},
+		},
+	}
+	if err := command.Init(context.Background(), true); err != nil {
+		t.Fatalf("Command.Init() returned %s", err)
+	}
+
+	e, err := StartExpect(command, "dumb", nil)
+	if err != nil {
+		t.Fatalf("StartExpect() returned %s", err)
+	}
+
+	// waiting for something that never appears should time out
+	_, err = e.Expect(100*time.Millisecond, ExpectLiteral("Password: "))
+	var expectErr *ExpectError
+	if !errors.As(err, &expectErr) || !errors.Is(err, ErrExpectTimeout) {
+		t.Fatalf("Expect() returned %v, want ErrExpectTimeout", err)
+	}
+	if !strings.Contains(expectErr.Transcript, "Name? ") {
+		t.Errorf("ExpectError.Transcript = %q, want to contain prompt", expectErr.Transcript)
+	}
+
+	// wait for the prompt
+	match, err := e.Expect(5*time.Second, ExpectLiteral("Password: "), ExpectLiteral("Name? "))
+	if err != nil {
+		t.Fatalf("Expect() returned %s", err)
+	}
+	if match.Index != 1 || match.Groups[0] != "Name? " {
+		t.Errorf("Expect() matched %v, want pattern 1", match)
+	}
+
+	if err := e.SendLine("World"); err != nil {
+		t.Fatalf("SendLine() returned %s", err)
+	}
+
+	match, err = e.Expect(5*time.Second, ExpectRegexp(regexp.MustCompile(`Hello, (\w+)!`)))
+	if err != nil {
+		t.Fatalf("Expect() returned %s", err)
+	}
+	if len(match.Groups) != 2 || match.Groups[1] != "World" {
+		t.Errorf("Expect() returned groups %q, want name", match.Groups)
+	}
+
+	if _, err := e.Expect(5*time.Second, ExpectEOF); err != nil {
+		t.Errorf("Expect(ExpectEOF) returned %s", err)
+	}
+
+	if _, err := e.Expect(5*time.Second, ExpectLiteral("more")); !errors.Is(err, io.EOF) {
+		t.Errorf("Expect() after EOF returned %v, want io.EOF", err)
+	}
+
+	if code, err := e.Wait(); code != 0 || err != nil {
+		t.Errorf("Wait() = (%d, %v), want (0, nil)", code, err)
+	}
+}
+
+func TestExpect_limits(t *testing.T) {
+	e := &Expect{
+		changed:    make(chan struct{}),
+		transcript: NewRingBuffer(ExpectTranscriptSize),
+	}
+	pty := expectPty{e}
+
+	pty.Write(bytes.Repeat([]byte("a"), 2*ExpectBufferSize))
+	pty.Write([]byte("end"))
+
+	match, err := e.Expect(time.Second, ExpectLiteral("end"))
+	if err != nil {
+		t.Fatalf("Expect() returned %s", err)
+	}
+	if len(match.Before) != ExpectBufferSize-len("end") {
+		t.Errorf("Expect() matched after %d bytes, want %d", len(match.Before), ExpectBufferSize-len("end"))
+	}
+
+	transcript := e.Transcript()
+	if len(transcript) != ExpectTranscriptSize || !strings.HasSuffix(transcript, "end") {
+		t.Errorf("Transcript() has length %d, want %d ending in \"end\"", len(transcript), ExpectTranscriptSize)
+	}
+}
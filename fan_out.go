@@ -0,0 +1,228 @@
+package procutil
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// FanOut is an io.Writer that copies everything written to it to several subscribers.
+// It can be used as the Out or Err writer of Command.Start(), or, combined with an input using Duplex, as the terminal of Command.StartPty().
+//
+// Every subscriber receives data in its own goroutine, using a buffer of pending writes.
+// When the buffer of a subscriber is full, its FanOutPolicy determines if writes wait for it or drop data.
+// This allows slow subscribers, such as a network client, to not stall the process.
+//
+// FanOut is safe for concurrent use.
+type FanOut struct {
+	m           sync.Mutex
+	subscribers []*Subscription
+	closed      bool
+}
+
+// FanOutPolicy determines what happens when the buffer of a subscriber of a FanOut is full
+type FanOutPolicy int
+
+const (
+	// FanOutBlock makes writes to the FanOut wait until the subscriber has space in its buffer.
+	FanOutBlock FanOutPolicy = iota
+
+	// FanOutDrop drops data that does not fit into the buffer of the subscriber.
+	// The number of bytes dropped can be retrieved using Subscription.Dropped().
+	FanOutDrop
+)
+
+// DefaultFanOutBuffer is the default number of pending writes buffered for each subscriber of a FanOut
+const DefaultFanOutBuffer = 128
+
+// Subscribe adds a new subscriber writing to w.
+// buffer is the number of pending writes buffered for the subscriber, defaulting to DefaultFanOutBuffer when not positive.
+//
+// Data written to the FanOut after Subscribe returns is written to w.
+// When w returns an error, the subscription is ended.
+// w is never closed by the FanOut.
+//
+// When the FanOut has already been closed, the returned subscription is already ended.
+func (f *FanOut) Subscribe(w io.Writer, buffer int, policy FanOutPolicy) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultFanOutBuffer
+	}
+
+	s := &Subscription{
+		fanOut: f,
+		policy: policy,
+		writer: w,
+		chunks: make(chan []byte, buffer),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.closed {
+		close(s.quit)
+		close(s.done)
+		return s
+	}
+
+	f.subscribers = append(f.subscribers, s)
+	go s.run()
+	return s
+}
+
+// Write writes p to all subscribers.
+// Apart from after the FanOut has been closed, Write never fails.
+func (f *FanOut) Write(p []byte) (int, error) {
+	f.m.Lock()
+	subscribers, closed := f.subscribers, f.closed
+	f.m.Unlock()
+
+	if closed {
+		return 0, io.ErrClosedPipe
+	}
+	if len(subscribers) == 0 || len(p) == 0 {
+		return len(p), nil
+	}
+
+	// subscribers receive data asynchronously, so p must be copied
+	chunk := make([]byte, len(p))
+	copy(chunk, p)
+
+	for _, s := range subscribers {
+		s.send(chunk)
+	}
+	return len(p), nil
+}
+
+// Close ends all subscriptions, and waits for their pending data to be written.
+// Subsequent writes to the FanOut fail.
+func (f *FanOut) Close() error {
+	f.m.Lock()
+	subscribers := f.subscribers
+	f.subscribers = nil
+	f.closed = true
+	f.m.Unlock()
+
+	for _, s := range subscribers {
+		s.end()
+	}
+	for _, s := range subscribers {
+		<-s.done
+	}
+	return nil
+}
+
+// remove removes s from the subscribers of f
+func (f *FanOut) remove(s *Subscription) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	for i, other := range f.subscribers {
+		if other == s {
+			// copy, as Write() may be iterating over the old slice
+			f.subscribers = append(append([]*Subscription(nil), f.subscribers[:i]...), f.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Subscription is a subscriber of a FanOut
+type Subscription struct {
+	fanOut *FanOut
+	policy FanOutPolicy
+	writer io.Writer
+
+	chunks chan []byte   // pending data
+	quit   chan struct{} // closed when the subscription is ended
+	done   chan struct{} // closed once all pending data has been written
+
+	quitOnce sync.Once
+	dropped  int64 // number of bytes dropped, accessed atomically
+	err      error // error returned by writer, set before done is closed
+}
+
+// send sends chunk to the subscriber, according to its policy
+func (s *Subscription) send(chunk []byte) {
+	if s.policy == FanOutDrop {
+		select {
+		case s.chunks <- chunk:
+		case <-s.quit:
+		default:
+			atomic.AddInt64(&s.dropped, int64(len(chunk)))
+		}
+		return
+	}
+
+	select {
+	case s.chunks <- chunk:
+	case <-s.quit:
+	}
+}
+
+// run writes pending data to the writer until the subscription is ended
+func (s *Subscription) run() {
+	defer close(s.done)
+
+	for {
+		select {
+		case chunk := <-s.chunks:
+			if !s.write(chunk) {
+				return
+			}
+		case <-s.quit:
+			// write data that is still pending
+			for {
+				select {
+				case chunk := <-s.chunks:
+					if !s.write(chunk) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// write writes chunk to the writer, and ends the subscription if this fails.
+func (s *Subscription) write(chunk []byte) bool {
+	if _, err := s.writer.Write(chunk); err != nil {
+		s.err = err
+		s.fanOut.remove(s)
+		s.end()
+		return false
+	}
+	return true
+}
+
+// end ends the subscription, without waiting for pending data to be written
+func (s *Subscription) end() {
+	s.quitOnce.Do(func() { close(s.quit) })
+}
+
+// Close ends the subscription, and waits for pending data to be written.
+// It returns the error returned by the writer, if any.
+func (s *Subscription) Close() error {
+	s.fanOut.remove(s)
+	s.end()
+	<-s.done
+	return s.err
+}
+
+// Done returns a channel that is closed once the subscription has ended and all pending data has been written
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the error that ended the subscription, if any.
+// It may only be called once Done() has been closed.
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// Dropped returns the number of bytes dropped because the buffer of the subscriber was full
+func (s *Subscription) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
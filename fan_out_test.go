@@ -0,0 +1,208 @@
+package procutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tkw1536/procutil/term"
+)
+
+func TestFanOut(t *testing.T) {
+	var fanOut FanOut
+
+	var a, b bytes.Buffer
+	fanOut.Subscribe(&a, 0, FanOutBlock)
+	fanOut.Subscribe(&b, 1, FanOutBlock)
+	ring := NewRingBuffer(4)
+	fanOut.Subscribe(ring, 0, FanOutDrop)
+
+	for _, write := range []string{"hello", " ", "world"} {
+		if n, err := fanOut.Write([]byte(write)); n != len(write) || err != nil {
+			t.Fatalf("Write() = (%d, %v), want (%d, nil)", n, err, len(write))
+		}
+	}
+	fanOut.Close()
+
+	if a.String() != "hello world" || b.String() != "hello world" {
+		t.Errorf("subscribers received (%q, %q), want \"hello world\"", a.String(), b.String())
+	}
+	if ring.String() != "orld" {
+		t.Errorf("ring buffer received %q, want \"orld\"", ring.String())
+	}
+
+	if _, err := fanOut.Write([]byte("x")); err == nil {
+		t.Error("Write() after Close() returned nil error")
+	}
+	if s := fanOut.Subscribe(&a, 0, FanOutBlock); s.Err() != nil {
+		t.Errorf("Subscribe() after Close() returned subscription with error %v", s.Err())
+	}
+}
+
+// blockingWriter blocks every write until release is closed
+type blockingWriter struct {
+	release chan struct{}
+	buffer  bytes.Buffer
+}
+
+func (bw *blockingWriter) Write(p []byte) (int, error) {
+	<-bw.release
+	return bw.buffer.Write(p)
+}
+
+func TestFanOut_drop(t *testing.T) {
+	var fanOut FanOut
+
+	slow := &blockingWriter{release: make(chan struct{})}
+	slowSub := fanOut.Subscribe(slow, 1, FanOutDrop)
+
+	var fast bytes.Buffer
+	fanOut.Subscribe(&fast, 100, FanOutBlock)
+
+	// the slow subscriber must not stall the writer
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			fanOut.Write([]byte("x"))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write() blocked on a slow subscriber")
+	}
+
+	close(slow.release)
+	fanOut.Close()
+
+	if fast.String() != "xxxxxxxxxx" {
+		t.Errorf("fast subscriber received %q, want \"xxxxxxxxxx\"", fast.String())
+	}
+	if got := int64(slow.buffer.Len()) + slowSub.Dropped(); got != 10 || slowSub.Dropped() == 0 {
+		t.Errorf("slow subscriber received %d bytes and dropped %d, want a total of 10", slow.buffer.Len(), slowSub.Dropped())
+	}
+}
+
+func TestFanOut_block(t *testing.T) {
+	var fanOut FanOut
+
+	slow := &blockingWriter{release: make(chan struct{})}
+	fanOut.Subscribe(slow, 1, FanOutBlock)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			fanOut.Write([]byte("x"))
+		}
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Write() did not block on a slow subscriber")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(slow.release)
+	<-done
+	fanOut.Close()
+
+	if slow.buffer.String() != "xxxxxxxxxx" {
+		t.Errorf("subscriber received %q, want \"xxxxxxxxxx\"", slow.buffer.String())
+	}
+}
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+var errWriteFailed = errors.New("write failed")
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errWriteFailed
+}
+
+func TestFanOut_writerError(t *testing.T) {
+	var fanOut FanOut
+	s := fanOut.Subscribe(failingWriter{}, 0, FanOutBlock)
+
+	fanOut.Write([]byte("hello"))
+	select {
+	case <-s.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription did not end")
+	}
+	if err := s.Err(); err != errWriteFailed {
+		t.Errorf("Err() = %v, want errWriteFailed", err)
+	}
+
+	// writes continue to succeed
+	if _, err := fanOut.Write([]byte("world")); err != nil {
+		t.Errorf("Write() returned %v", err)
+	}
+	if err := s.Close(); err != errWriteFailed {
+		t.Errorf("Close() = %v, want errWriteFailed", err)
+	}
+}
+
+func TestFanOut_Command(t *testing.T) {
+	var fanOut FanOut
+	var buffer bytes.Buffer
+	fanOut.Subscribe(&buffer, 0, FanOutBlock)
+	tail := NewLineRing(1)
+	fanOut.Subscribe(tail, 0, FanOutDrop)
+
+	command := &Command{Process: &testProcess{Out: "hello\nworld\n"}}
+	if err := command.Run(context.Background(), &fanOut, nil, strings.NewReader("")); err != nil {
+		t.Fatalf("Run() returned %s", err)
+	}
+	fanOut.Close()
+
+	if buffer.String() != "hello\nworld\n" {
+		t.Errorf("subscriber received %q, want \"hello\\nworld\\n\"", buffer.String())
+	}
+	if lines := tail.Lines(); len(lines) != 1 || lines[0] != "world" {
+		t.Errorf("line ring has lines %q, want [\"world\"]", lines)
+	}
+}
+
+func TestFanOut_StartPty(t *testing.T) {
+	if !term.PTYSupport {
+		t.Skip("OS not supported")
+	}
+
+	var fanOut FanOut
+	var buffer bytes.Buffer
+	s := fanOut.Subscribe(&buffer, 0, FanOutBlock)
+
+	command := &Command{
+		Process: &ExecProcess{
+			Command: "echo",
+			Args:    []string{"hello"},
+		},
+	}
+	if err := command.Init(context.Background(), true); err != nil {
+		t.Fatalf("Init() returned %s", err)
+	}
+
+	input, inputW := io.Pipe()
+	defer inputW.Close()
+	if err := command.StartPty(&Duplex{Reader: input, Writer: &fanOut}, "dumb", nil); err != nil {
+		t.Fatalf("StartPty() returned %s", err)
+	}
+	command.Wait()
+
+	// closing the output of the pty closes the FanOut
+	select {
+	case <-s.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription did not end")
+	}
+	if !strings.Contains(buffer.String(), "hello") {
+		t.Errorf("subscriber received %q, want it to contain \"hello\"", buffer.String())
+	}
+}
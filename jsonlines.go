@@ -0,0 +1,97 @@
+package procutil
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// This file implements reading and writing of JSON lines files, as used by cast files and transcripts.
+// Such a file consists of a header object on the first line, followed by one record on every following line.
+
+// jsonLinesMaxLine is the maximum length of a single line of a JSON lines file
+const jsonLinesMaxLine = 16 * 1024 * 1024
+
+// readJSONLines reads a JSON lines file from reader.
+//
+// Empty lines are skipped.
+// The first non-empty line is decoded into header, and then validated using check.
+// Every following non-empty line is decoded into the value returned by a call to next.
+// Errors are prefixed with format and refer to records using record, e.g. "Cast" and "event".
+func readJSONLines(reader io.Reader, format, record string, header interface{}, check func() error, next func() interface{}) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, jsonLinesMaxLine)
+
+	line := 0
+	headerSeen := false
+	for scanner.Scan() {
+		line++
+		data := scanner.Bytes()
+		if len(data) == 0 {
+			continue
+		}
+
+		if !headerSeen {
+			headerSeen = true
+			if err := json.Unmarshal(data, header); err != nil {
+				return errors.Wrapf(err, "%s: Invalid header", format)
+			}
+			if err := check(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := json.Unmarshal(data, next()); err != nil {
+			return errors.Wrapf(err, "%s: Invalid %s on line %d", format, record, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if !headerSeen {
+		return errors.Wrapf(io.ErrUnexpectedEOF, "%s: Missing header", format)
+	}
+	return nil
+}
+
+// jsonLinesWriter writes a JSON lines file to an underlying writer.
+// It is safe to be called by multiple goroutines.
+type jsonLinesWriter struct {
+	m     sync.Mutex
+	w     io.Writer
+	start time.Time
+}
+
+// newJSONLinesWriter writes header to w and returns a new jsonLinesWriter writing to w.
+// Records are timestamped relative to start.
+func newJSONLinesWriter(w io.Writer, header interface{}, start time.Time) (*jsonLinesWriter, error) {
+	if err := writeJSONLine(w, header); err != nil {
+		return nil, err
+	}
+	return &jsonLinesWriter{w: w, start: start}, nil
+}
+
+// Write writes the record returned by record, which is passed the number of seconds since start.
+// Records are written in the order they are timestamped.
+func (jw *jsonLinesWriter) Write(record func(elapsed float64) interface{}) error {
+	jw.m.Lock()
+	defer jw.m.Unlock()
+
+	return writeJSONLine(jw.w, record(time.Since(jw.start).Seconds()))
+}
+
+// writeJSONLine writes value to w as a single line of JSON
+func writeJSONLine(w io.Writer, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
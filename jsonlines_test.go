@@ -0,0 +1,95 @@
+package procutil
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadJSONLines(t *testing.T) {
+	type header struct {
+		Version int `json:"version"`
+	}
+	type record struct {
+		Value int `json:"value"`
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []record
+		wantErr string
+	}{
+		{"records", "{\"version\":1}\n{\"value\":1}\n\n{\"value\":2}\n", []record{{1}, {2}}, ""},
+		{"header only", "{\"version\":1}\n", nil, ""},
+		{"leading empty line", "\n{\"version\":1}\n{\"value\":1}\n", []record{{1}}, ""},
+		{"empty", "", nil, "Test: Missing header: unexpected EOF"},
+		{"only empty lines", "\n\n", nil, "Test: Missing header: unexpected EOF"},
+		{"invalid header", "hello\n", nil, "Test: Invalid header: invalid character 'h' looking for beginning of value"},
+		{"unsupported version", "{\"version\":2}\n", nil, "unsupported"},
+		{"invalid record", "{\"version\":1}\n{\"value\":1}\n[]\n", nil, "Test: Invalid record on line 3: json: cannot unmarshal array into Go value of type procutil.record"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h header
+			var got []record
+
+			check := func() error {
+				if h.Version != 1 {
+					return errors.New("unsupported")
+				}
+				return nil
+			}
+			next := func() interface{} {
+				got = append(got, record{})
+				return &got[len(got)-1]
+			}
+
+			err := readJSONLines(strings.NewReader(tt.input), "Test", "record", &h, check, next)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("readJSONLines() returned %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readJSONLines() returned %s", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("readJSONLines() read %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("readJSONLines() read %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONLinesWriter(t *testing.T) {
+	var buffer bytes.Buffer
+	jw, err := newJSONLinesWriter(&buffer, map[string]int{"version": 1}, time.Now())
+	if err != nil {
+		t.Fatalf("newJSONLinesWriter() returned %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err := jw.Write(func(elapsed float64) interface{} {
+			if elapsed < 0 {
+				t.Errorf("Write() passed elapsed time %v, want non-negative", elapsed)
+			}
+			return []int{i}
+		})
+		if err != nil {
+			t.Fatalf("Write() returned %s", err)
+		}
+	}
+
+	want := "{\"version\":1}\n[0]\n[1]\n"
+	if got := buffer.String(); got != want {
+		t.Errorf("newJSONLinesWriter() wrote %q, want %q", got, want)
+	}
+}
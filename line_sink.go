@@ -0,0 +1,325 @@
+package procutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// LineFormat is the format of lines parsed by a LineSink
+type LineFormat int
+
+const (
+	// FormatPlain does not parse lines
+	FormatPlain LineFormat = iota
+	// FormatJSON parses lines that consist of a single JSON object
+	FormatJSON
+	// FormatLogfmt parses lines consisting of logfmt-style 'key=value' pairs
+	FormatLogfmt
+)
+
+// Record is a single line of output received by a LineSink
+type Record struct {
+	Stream string    // name of the stream the line was written to, e.g. "stdout"
+	Time   time.Time // time the line was completed
+	Line   string    // the line, without the line ending
+
+	// Fields are the fields of the parsed line.
+	// When the sink does not parse lines, or the line could not be parsed, Fields is nil.
+	Fields map[string]interface{}
+}
+
+// DefaultMaxLineLength is the default maximum length of a line of a LineSink
+const DefaultMaxLineLength = 64 * 1024
+
+// LineSink splits output of a process into lines, and delivers each line as a Record.
+// It is typically used with Command.Start(), for example:
+//
+//	command.Start(sink.Stdout(), sink.Stderr(), in)
+//
+// Lines may end in "\n" or "\r\n".
+// A carriage return that is not followed by a newline overwrites the current line, as it would on a terminal.
+// This means that only the final state of a progress bar is delivered.
+// Lines that are longer than MaxLineLength are split.
+//
+// Records are delivered to Handler, or when it is nil, sent to Records.
+// Delivery is synchronous and serialized: Handler is never called concurrently, and writes to the sink block until the record has been delivered.
+// This means that a slow consumer slows down the process writing the output.
+//
+// Once all output has been written, Close() should be called to deliver any incomplete lines.
+type LineSink struct {
+	Format        LineFormat    // format to parse lines in
+	MaxLineLength int           // maximum length of a line in bytes, defaults to DefaultMaxLineLength
+	Handler       func(Record)  // called for every record
+	Records       chan<- Record // receives every record when Handler is nil
+
+	wm      sync.Mutex // protects writers
+	writers map[string]*lineWriter
+
+	dm sync.Mutex // serializes delivery
+}
+
+// Stdout returns the writer for the "stdout" stream, see Writer()
+func (ls *LineSink) Stdout() io.WriteCloser {
+	return ls.Writer("stdout")
+}
+
+// Stderr returns the writer for the "stderr" stream, see Writer()
+func (ls *LineSink) Stderr() io.WriteCloser {
+	return ls.Writer("stderr")
+}
+
+// Writer returns a writer that delivers lines written to it as records of the given stream.
+// Repeated calls with the same stream return the same writer.
+//
+// Partial lines are kept until the line is completed, or the writer is closed.
+// Closing the writer delivers the last incomplete line, if any.
+// Writes to a closed writer return io.ErrClosedPipe.
+func (ls *LineSink) Writer(stream string) io.WriteCloser {
+	ls.wm.Lock()
+	defer ls.wm.Unlock()
+
+	if ls.writers == nil {
+		ls.writers = make(map[string]*lineWriter)
+	}
+	if w, ok := ls.writers[stream]; ok {
+		return w
+	}
+
+	max := ls.MaxLineLength
+	if max <= 0 {
+		max = DefaultMaxLineLength
+	}
+	w := &lineWriter{sink: ls, stream: stream, max: max}
+	ls.writers[stream] = w
+	return w
+}
+
+// Close closes all writers of this sink, delivering any incomplete lines.
+func (ls *LineSink) Close() error {
+	ls.wm.Lock()
+	writers := make([]*lineWriter, 0, len(ls.writers))
+	for _, w := range ls.writers {
+		writers = append(writers, w)
+	}
+	ls.wm.Unlock()
+
+	for _, w := range writers {
+		w.Close()
+	}
+	return nil
+}
+
+// deliver parses line and delivers it as a record of stream
+func (ls *LineSink) deliver(stream string, line []byte) {
+	record := Record{
+		Stream: stream,
+		Time:   time.Now(),
+		Line:   string(line),
+	}
+	switch ls.Format {
+	case FormatJSON:
+		record.Fields = parseJSONLine(line)
+	case FormatLogfmt:
+		record.Fields = parseLogfmtLine(record.Line)
+	}
+
+	ls.dm.Lock()
+	defer ls.dm.Unlock()
+
+	switch {
+	case ls.Handler != nil:
+		ls.Handler(record)
+	case ls.Records != nil:
+		ls.Records <- record
+	}
+}
+
+// lineWriter splits output written to a single stream of a LineSink into lines
+type lineWriter struct {
+	sink   *LineSink
+	stream string
+	max    int
+
+	m      sync.Mutex
+	line   []byte // current incomplete line
+	cr     bool   // was the last byte written a carriage return?
+	closed bool
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.m.Lock()
+	defer lw.m.Unlock()
+
+	if lw.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	n := len(p)
+	for len(p) > 0 {
+		if lw.cr {
+			lw.cr = false
+			if p[0] == '\n' { // "\r\n" line ending
+				lw.flush()
+				p = p[1:]
+				continue
+			}
+			lw.line = lw.line[:0] // carriage return overwrites the line
+		}
+
+		i := bytes.IndexAny(p, "\r\n")
+		if i < 0 {
+			lw.append(p)
+			break
+		}
+
+		lw.append(p[:i])
+		if p[i] == '\n' {
+			lw.flush()
+		} else {
+			lw.cr = true
+		}
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// append appends data to the current line, delivering the line whenever it reaches the maximum length.
+// lw.m must be held by the caller.
+func (lw *lineWriter) append(data []byte) {
+	for len(lw.line)+len(data) > lw.max {
+		fill := lw.max - len(lw.line)
+		lw.line = append(lw.line, data[:fill]...)
+		data = data[fill:]
+		lw.flush()
+	}
+	lw.line = append(lw.line, data...)
+}
+
+// flush delivers the current line and resets it.
+// lw.m must be held by the caller.
+func (lw *lineWriter) flush() {
+	lw.sink.deliver(lw.stream, lw.line)
+	lw.line = lw.line[:0]
+}
+
+// Close delivers the current line, if it is not empty, and closes this writer.
+func (lw *lineWriter) Close() error {
+	lw.m.Lock()
+	defer lw.m.Unlock()
+
+	if lw.closed {
+		return nil
+	}
+	lw.closed = true
+
+	if len(lw.line) > 0 {
+		lw.flush()
+	}
+	return nil
+}
+
+// parseJSONLine parses a line consisting of a single JSON object.
+// When line is not a JSON object, returns nil.
+func parseJSONLine(line []byte) map[string]interface{} {
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(trimmed, &fields); err != nil {
+		return nil
+	}
+	return fields
+}
+
+// parseLogfmtLine parses a line of logfmt-style 'key=value' pairs.
+// Values may be quoted using double quotes, keys without a value are set to true.
+// When line contains no 'key=value' pairs, or is not valid, returns nil.
+func parseLogfmtLine(line string) map[string]interface{} {
+	fields := make(map[string]interface{})
+	hasValue := false
+
+	for {
+		line = strings.TrimLeftFunc(line, unicode.IsSpace)
+		if line == "" {
+			break
+		}
+
+		// read the key
+		end := strings.IndexFunc(line, func(r rune) bool { return r == '=' || r == '"' || unicode.IsSpace(r) })
+		if end < 0 {
+			end = len(line)
+		}
+		key := line[:end]
+		line = line[end:]
+		if key == "" {
+			return nil
+		}
+
+		// key without a value
+		if !strings.HasPrefix(line, "=") {
+			fields[key] = true
+			continue
+		}
+		line = line[1:]
+		hasValue = true
+
+		// quoted value
+		if strings.HasPrefix(line, `"`) {
+			value, rest, ok := unquoteLogfmt(line)
+			if !ok {
+				return nil
+			}
+			fields[key] = value
+			line = rest
+			continue
+		}
+
+		// plain value
+		end = strings.IndexFunc(line, unicode.IsSpace)
+		if end < 0 {
+			end = len(line)
+		}
+		fields[key] = line[:end]
+		line = line[end:]
+	}
+
+	if !hasValue {
+		return nil
+	}
+	return fields
+}
+
+// unquoteLogfmt unquotes a double-quoted value at the start of s, and returns the rest of s.
+// Backslashes escape the following character.
+func unquoteLogfmt(s string) (value, rest string, ok bool) {
+	var builder strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+			if i == len(s) {
+				return "", "", false
+			}
+			switch s[i] {
+			case 'n':
+				builder.WriteByte('\n')
+			case 't':
+				builder.WriteByte('\t')
+			default:
+				builder.WriteByte(s[i])
+			}
+		case '"':
+			return builder.String(), s[i+1:], true
+		default:
+			builder.WriteByte(s[i])
+		}
+	}
+	return "", "", false
+}
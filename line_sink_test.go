@@ -0,0 +1,136 @@
+package procutil
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLineSink_lines(t *testing.T) {
+	tests := []struct {
+		name   string
+		max    int
+		writes []string
+		want   []string
+	}{
+		{"single line", 0, []string{"hello\n"}, []string{"hello"}},
+		{"multiple lines", 0, []string{"hello\nworld\n"}, []string{"hello", "world"}},
+		{"empty lines", 0, []string{"\n\n"}, []string{"", ""}},
+		{"partial lines", 0, []string{"hel", "lo\nwor", "ld\n"}, []string{"hello", "world"}},
+		{"incomplete last line", 0, []string{"hello\nworld"}, []string{"hello", "world"}},
+		{"crlf line endings", 0, []string{"hello\r\nworld\r\n"}, []string{"hello", "world"}},
+		{"crlf split across writes", 0, []string{"hello\r", "\nworld\r", "\n"}, []string{"hello", "world"}},
+		{"carriage return overwrites", 0, []string{"10%\r50%\r100%\n"}, []string{"100%"}},
+		{"carriage return across writes", 0, []string{"10%\r", "50%\r", "100%\ndone\n"}, []string{"100%", "done"}},
+		{"trailing carriage return", 0, []string{"hello\r"}, []string{"hello"}},
+		{"long line", 4, []string{"abcdefghij\n"}, []string{"abcd", "efgh", "ij"}},
+		{"long partial lines", 4, []string{"ab", "cdef", "g\n"}, []string{"abcd", "efg"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			sink := &LineSink{MaxLineLength: tt.max, Handler: func(r Record) {
+				got = append(got, r.Line)
+			}}
+
+			w := sink.Stdout()
+			for _, write := range tt.writes {
+				if n, err := w.Write([]byte(write)); n != len(write) || err != nil {
+					t.Fatalf("Write() = (%d, %v), want (%d, nil)", n, err, len(write))
+				}
+			}
+			sink.Close()
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sink delivered %q, want %q", got, tt.want)
+			}
+
+			if _, err := w.Write([]byte("x")); err != io.ErrClosedPipe {
+				t.Errorf("Write() after Close() returned %v, want io.ErrClosedPipe", err)
+			}
+		})
+	}
+}
+
+func TestLineSink_Format(t *testing.T) {
+	tests := []struct {
+		name   string
+		format LineFormat
+		line   string
+		want   map[string]interface{}
+	}{
+		{"plain", FormatPlain, `{"level":"info"}`, nil},
+		{"json object", FormatJSON, `{"level":"info","count":3}`, map[string]interface{}{"level": "info", "count": float64(3)}},
+		{"json with whitespace", FormatJSON, `  {"level":"info"} `, map[string]interface{}{"level": "info"}},
+		{"json not an object", FormatJSON, `[1, 2]`, nil},
+		{"json invalid", FormatJSON, `{"level":`, nil},
+		{"json plain text", FormatJSON, `hello world`, nil},
+		{"logfmt", FormatLogfmt, `level=info msg=started`, map[string]interface{}{"level": "info", "msg": "started"}},
+		{"logfmt quoted", FormatLogfmt, `level=info msg="hello \"world\""`, map[string]interface{}{"level": "info", "msg": `hello "world"`}},
+		{"logfmt empty value", FormatLogfmt, `level= msg=x`, map[string]interface{}{"level": "", "msg": "x"}},
+		{"logfmt bare key", FormatLogfmt, `debug level=info`, map[string]interface{}{"debug": true, "level": "info"}},
+		{"logfmt plain text", FormatLogfmt, `hello world`, nil},
+		{"logfmt unterminated quote", FormatLogfmt, `msg="hello`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Record
+			sink := &LineSink{Format: tt.format, Handler: func(r Record) {
+				got = append(got, r)
+			}}
+
+			io.WriteString(sink.Stdout(), tt.line+"\n")
+			if len(got) != 1 {
+				t.Fatalf("sink delivered %d records, want 1", len(got))
+			}
+			if got[0].Line != tt.line {
+				t.Errorf("record has line %q, want %q", got[0].Line, tt.line)
+			}
+			if !reflect.DeepEqual(got[0].Fields, tt.want) {
+				t.Errorf("record has fields %v, want %v", got[0].Fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineSink_Records(t *testing.T) {
+	records := make(chan Record)
+	sink := &LineSink{Records: records}
+
+	command := &Command{Process: &testProcess{Out: "out 1\nout 2\n", Err: "err\n"}}
+
+	done := make(chan error)
+	go func() {
+		err := command.Run(context.Background(), sink.Stdout(), sink.Stderr(), strings.NewReader(""))
+		sink.Close()
+		done <- err
+	}()
+
+	var stdout, stderr []string
+	for i := 0; i < 3; i++ {
+		record := <-records
+		if record.Time.IsZero() {
+			t.Errorf("record %q has no time", record.Line)
+		}
+		switch record.Stream {
+		case "stdout":
+			stdout = append(stdout, record.Line)
+		case "stderr":
+			stderr = append(stderr, record.Line)
+		default:
+			t.Errorf("record %q has stream %q", record.Line, record.Stream)
+		}
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("Run() returned %s", err)
+	}
+	if want := []string{"out 1", "out 2"}; !reflect.DeepEqual(stdout, want) {
+		t.Errorf("stdout records %q, want %q", stdout, want)
+	}
+	if want := []string{"err"}; !reflect.DeepEqual(stderr, want) {
+		t.Errorf("stderr records %q, want %q", stderr, want)
+	}
+}
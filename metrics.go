@@ -0,0 +1,280 @@
+package procutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metrics collects Prometheus-style metrics about commands.
+// It is an Observer, and should be registered with every command to collect metrics about, using Instrument() or Command.AddObserver().
+//
+// All metrics are labelled by the kind of process, as returned by ProcessKind().
+// The collected metrics are:
+//
+//	procutil_commands_started_total          counter   commands that were started successfully
+//	procutil_commands_running                gauge     commands that have been started, but not yet exited
+//	procutil_commands_exited_total           counter   commands that have exited, additionally labelled by exit code, or "error" if waiting failed
+//	procutil_command_duration_seconds        histogram time between start and exit of commands
+//	procutil_command_stream_bytes_total      counter   bytes copied to and from commands, additionally labelled by stream
+//	procutil_command_resizes_total           counter   resize events of commands running on a pty
+//	procutil_command_stops_total             counter   successful calls to Stop()
+//
+// Metrics can be exposed using WriteTo(), or by using it as an http.Handler.
+// A Metrics is safe for concurrent use.
+type Metrics struct {
+	// Buckets are the upper bounds of the buckets of the duration histogram in seconds, in increasing order.
+	// When nil, DefaultDurationBuckets is used.
+	// Buckets must not be changed once the first event has been observed.
+	Buckets []float64
+
+	m          sync.Mutex
+	counters   map[string]map[string]float64 // values of counters and gauges, by metric and labels
+	histograms map[string]*histogram         // duration histograms, by labels
+}
+
+// DefaultDurationBuckets are the default buckets of the duration histogram of Metrics, in seconds.
+var DefaultDurationBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600}
+
+// Metrics implements Observer and http.Handler
+func init() {
+	var _ Observer = (*Metrics)(nil)
+	var _ http.Handler = (*Metrics)(nil)
+}
+
+// metricDesc describes a single metric
+type metricDesc struct {
+	Name, Type, Help string
+}
+
+// names of the collected metrics
+const (
+	metricStarted  = "procutil_commands_started_total"
+	metricRunning  = "procutil_commands_running"
+	metricExited   = "procutil_commands_exited_total"
+	metricDuration = "procutil_command_duration_seconds"
+	metricBytes    = "procutil_command_stream_bytes_total"
+	metricResizes  = "procutil_command_resizes_total"
+	metricStops    = "procutil_command_stops_total"
+)
+
+// metricDescs are descriptions of all metrics, in order of exposition
+var metricDescs = []metricDesc{
+	{metricStarted, "counter", "Number of commands that were started successfully."},
+	{metricRunning, "gauge", "Number of commands that have been started, but not yet exited."},
+	{metricExited, "counter", "Number of commands that have exited, by exit code."},
+	{metricDuration, "histogram", "Time between start and exit of commands in seconds."},
+	{metricBytes, "counter", "Number of bytes copied to and from commands, by stream."},
+	{metricResizes, "counter", "Number of resize events of commands running on a pty."},
+	{metricStops, "counter", "Number of successful calls to Stop()."},
+}
+
+// Instrument registers m as an observer of command.
+// It returns a function to stop observing command.
+func (m *Metrics) Instrument(command *Command) (remove func()) {
+	return command.AddObserver(m)
+}
+
+// Observe updates the metrics with event
+func (m *Metrics) Observe(event Event) {
+	kind := formatLabels("kind", event.Kind)
+
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	switch event.Type {
+	case EventStart:
+		if event.Err != nil {
+			return
+		}
+		m.add(metricStarted, kind, 1)
+		m.add(metricRunning, kind, 1)
+	case EventDone:
+		if !event.Started {
+			return
+		}
+		code := strconv.Itoa(event.ExitCode)
+		if event.Err != nil {
+			code = "error"
+		}
+		m.add(metricRunning, kind, -1)
+		m.add(metricExited, formatLabels("kind", event.Kind, "code", code), 1)
+		m.histogram(kind).observe(event.Duration.Seconds())
+	case EventCleanup:
+		if event.Unwaited {
+			m.add(metricRunning, kind, -1)
+		}
+	case EventStreamData:
+		m.add(metricBytes, formatLabels("kind", event.Kind, "stream", event.Stream), float64(event.Bytes))
+	case EventResize:
+		m.add(metricResizes, kind, 1)
+	case EventStop:
+		if event.Err == nil {
+			m.add(metricStops, kind, 1)
+		}
+	}
+}
+
+// add adds delta to the metric with the given name and labels.
+// m.m must be held by the caller.
+func (m *Metrics) add(name, labels string, delta float64) {
+	if m.counters == nil {
+		m.counters = make(map[string]map[string]float64)
+	}
+	if m.counters[name] == nil {
+		m.counters[name] = make(map[string]float64)
+	}
+	m.counters[name][labels] += delta
+}
+
+// histogram returns the duration histogram with the given labels, creating it if needed.
+// m.m must be held by the caller.
+func (m *Metrics) histogram(labels string) *histogram {
+	if m.histograms == nil {
+		m.histograms = make(map[string]*histogram)
+	}
+	h := m.histograms[labels]
+	if h == nil {
+		buckets := m.Buckets
+		if buckets == nil {
+			buckets = DefaultDurationBuckets
+		}
+		h = &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+		m.histograms[labels] = h
+	}
+	return h
+}
+
+// Value returns the current value of a counter or gauge metric with the given labels.
+// labels are given as alternating names and values, e.g. Value("procutil_commands_started_total", "kind", "exec").
+// When no such value exists, returns 0.
+func (m *Metrics) Value(name string, labelPairs ...string) float64 {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	return m.counters[name][formatLabels(labelPairs...)]
+}
+
+// WriteTo writes all metrics to w using the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	cw := &countWriter{Writer: w}
+	bw := bufio.NewWriter(cw)
+	for _, desc := range metricDescs {
+		fmt.Fprintf(bw, "# HELP %s %s\n", desc.Name, desc.Help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", desc.Name, desc.Type)
+
+		if desc.Name == metricDuration {
+			keys := make([]string, 0, len(m.histograms))
+			for l := range m.histograms {
+				keys = append(keys, l)
+			}
+			sort.Strings(keys)
+
+			for _, l := range keys {
+				m.histograms[l].write(bw, desc.Name, l)
+			}
+			continue
+		}
+
+		values := m.counters[desc.Name]
+		keys := make([]string, 0, len(values))
+		for l := range values {
+			keys = append(keys, l)
+		}
+		sort.Strings(keys)
+
+		for _, l := range keys {
+			fmt.Fprintf(bw, "%s{%s} %s\n", desc.Name, l, formatFloat(values[l]))
+		}
+	}
+
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ServeHTTP writes all metrics using the Prometheus text exposition format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// histogram is a histogram of observed values
+type histogram struct {
+	buckets []float64 // upper bounds
+	counts  []uint64  // non-cumulative count of values in each bucket
+	count   uint64    // total number of values
+	sum     float64   // sum of all values
+}
+
+func (h *histogram) observe(value float64) {
+	h.count++
+	h.sum += value
+
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		h.counts[i]++
+	}
+}
+
+// write writes the histogram with the given name and labels to w
+func (h *histogram) write(w io.Writer, name, labels string) {
+	var cumulative uint64
+	for i, le := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", name, labels, formatFloat(le), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// formatLabels formats alternating label names and values in exposition format
+func formatLabels(pairs ...string) string {
+	var builder strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			builder.WriteRune(',')
+		}
+		builder.WriteString(pairs[i])
+		builder.WriteRune('=')
+		builder.WriteRune('"')
+		builder.WriteString(labelEscaper.Replace(pairs[i+1]))
+		builder.WriteRune('"')
+	}
+	return builder.String()
+}
+
+// labelEscaper escapes label values in exposition format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatFloat formats a value in exposition format
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+// countWriter counts the bytes written to an underlying writer
+type countWriter struct {
+	io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.Writer.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
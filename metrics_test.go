@@ -0,0 +1,149 @@
+package procutil
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := &Metrics{Buckets: []float64{1, 10}}
+
+	for _, code := range []int{0, 0, 3} {
+		command := &Command{Process: &testProcess{Out: "output", ExitCode: code}}
+		metrics.Instrument(command)
+
+		command.Init(nil, false)
+		command.Start(ioutil.Discard, ioutil.Discard, strings.NewReader(""))
+		command.Wait()
+	}
+
+	// stream events are sent asynchronously
+	deadline := time.Now().Add(time.Second)
+	for metrics.Value(metricBytes, "kind", "other", "stream", "stdout") < 18 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	tests := []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{metricStarted, []string{"kind", "other"}, 3},
+		{metricRunning, []string{"kind", "other"}, 0},
+		{metricExited, []string{"kind", "other", "code", "0"}, 2},
+		{metricExited, []string{"kind", "other", "code", "3"}, 1},
+		{metricBytes, []string{"kind", "other", "stream", "stdout"}, 18},
+		{metricStops, []string{"kind", "other"}, 0},
+	}
+	for _, tt := range tests {
+		if got := metrics.Value(tt.name, tt.labels...); got != tt.want {
+			t.Errorf("Value(%q, %q) = %v, want %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+
+	for _, want := range []string{
+		"# TYPE procutil_commands_started_total counter\nprocutil_commands_started_total{kind=\"other\"} 3\n",
+		"procutil_commands_exited_total{kind=\"other\",code=\"3\"} 1\n",
+		"procutil_command_duration_seconds_bucket{kind=\"other\",le=\"1\"} 3\n",
+		"procutil_command_duration_seconds_bucket{kind=\"other\",le=\"+Inf\"} 3\n",
+		"procutil_command_duration_seconds_count{kind=\"other\"} 3\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("exposition does not contain %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestProcessKind(t *testing.T) {
+	tests := []struct {
+		process Process
+		want    string
+	}{
+		{&ExecProcess{}, "exec"},
+		{NewDockerExecProcess(nil, "container", nil), "docker_exec"},
+		{&StreamingProcess{}, "stream"},
+		{&RecordingProcess{Process: &ExecProcess{}}, "exec"},
+		{&testProcess{}, "other"},
+	}
+	for _, tt := range tests {
+		if got := ProcessKind(tt.process); got != tt.want {
+			t.Errorf("ProcessKind(%T) = %q, want %q", tt.process, got, tt.want)
+		}
+	}
+}
+
+func TestMetrics_streaming(t *testing.T) {
+	metrics := &Metrics{}
+
+	command := &Command{Process: &ExecProcess{Command: "/bin/sh", Args: []string{"-c", "echo hello; sleep 10"}}}
+	metrics.Instrument(command)
+
+	command.Init(nil, false)
+	if err := command.Start(ioutil.Discard, ioutil.Discard, strings.NewReader("")); err != nil {
+		t.Fatal(err)
+	}
+	defer command.Cleanup()
+	defer command.Wait()
+	defer command.Stop()
+
+	// bytes are counted while the command is still running
+	deadline := time.Now().Add(5 * time.Second)
+	for metrics.Value(metricBytes, "kind", "exec", "stream", "stdout") < 6 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := metrics.Value(metricBytes, "kind", "exec", "stream", "stdout"); got != 6 {
+		t.Errorf("stdout bytes of running command = %v, want 6", got)
+	}
+	if got := metrics.Value(metricRunning, "kind", "exec"); got != 1 {
+		t.Errorf("running commands = %v, want 1", got)
+	}
+}
+
+func TestMetrics_unwaited(t *testing.T) {
+	metrics := &Metrics{}
+
+	command := &Command{Process: &testProcess{}}
+	metrics.Instrument(command)
+
+	command.Init(nil, false)
+	command.Start(ioutil.Discard, ioutil.Discard, strings.NewReader(""))
+	if got := metrics.Value(metricRunning, "kind", "other"); got != 1 {
+		t.Errorf("running commands after Start() = %v, want 1", got)
+	}
+
+	// clean up without ever waiting for the process
+	command.abort()
+	if got := metrics.Value(metricRunning, "kind", "other"); got != 0 {
+		t.Errorf("running commands after Cleanup() = %v, want 0", got)
+	}
+}
+
+func TestMetrics_startFailed(t *testing.T) {
+	metrics := &Metrics{}
+
+	command := &Command{Process: &ExecProcess{Command: "true", Workdir: filepath.Join(t.TempDir(), "missing")}}
+	metrics.Instrument(command)
+
+	command.Init(nil, false)
+	if err := command.Start(ioutil.Discard, ioutil.Discard, strings.NewReader("")); err == nil {
+		t.Fatal("Start() did not return an error")
+	}
+	command.Wait()
+
+	for _, name := range []string{metricStarted, metricRunning} {
+		if got := metrics.Value(name, "kind", "exec"); got != 0 {
+			t.Errorf("Value(%q) = %v, want 0", name, got)
+		}
+	}
+	if got := metrics.Value(metricExited, "kind", "exec", "code", "error"); got != 0 {
+		t.Errorf("exited commands = %v, want 0", got)
+	}
+}
@@ -0,0 +1,271 @@
+package procutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Pipeline runs several commands, connecting the standard output of each stage to the standard input of the next.
+// This corresponds to a shell pipeline such as 'a | b | c'.
+//
+// Each stage may run an arbitrary Process.
+// When two adjacent stages both run an *ExecProcess, they are connected by an operating system pipe directly.
+// Otherwise, data is copied between the stages by the current process.
+//
+// The exit code of a pipeline follows 'pipefail' semantics.
+// It is the exit code of the last stage that exited with a non-zero exit code, or 0 if all stages exited successfully.
+//
+// A Pipeline may only be started once.
+type Pipeline struct {
+	Stages []*Command // the stages of the pipeline, in order
+
+	m       sync.Mutex
+	started bool
+	done    chan struct{} // closed once all stages have exited
+	results []StageResult // results of all stages, set once done is closed
+}
+
+// StageResult is the result of a single stage of a Pipeline
+type StageResult struct {
+	Process  string // String() of the process of the stage
+	ExitCode int    // exit code of the process
+	Err      error  // error returned when waiting for the stage, if any
+}
+
+// Errors returned by methods of Pipeline
+var (
+	ErrPipelineNoStages       = errors.New("Pipeline: No stages")
+	ErrPipelineAlreadyStarted = errors.New("Pipeline: Already started")
+	ErrPipelineNotStarted     = errors.New("Pipeline: Not started")
+)
+
+// NewPipeline creates a new pipeline with a stage for each of the given processes
+func NewPipeline(processes ...Process) *Pipeline {
+	stages := make([]*Command, len(processes))
+	for i, process := range processes {
+		stages[i] = &Command{Process: process}
+	}
+	return &Pipeline{Stages: stages}
+}
+
+// String returns the String() of all stages, separated by " | "
+func (p *Pipeline) String() string {
+	names := make([]string, len(p.Stages))
+	for i, stage := range p.Stages {
+		names[i] = stage.String()
+	}
+	return strings.Join(names, " | ")
+}
+
+// Start initializes and starts all stages of the pipeline.
+//
+// In is copied to the input of the first stage, and the output of the last stage is copied to Out.
+// The standard error of all stages is copied to Err.
+// When any of the streams are nil, input is empty and output is discarded.
+//
+// Once the context is closed, all stages will be killed.
+// When a stage fails to initialize or start, all stages that have already been started are stopped, and an error is returned.
+func (p *Pipeline) Start(ctx context.Context, Out, Err io.Writer, In io.Reader) (err error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if len(p.Stages) == 0 {
+		return ErrPipelineNoStages
+	}
+	if p.started {
+		return ErrPipelineAlreadyStarted
+	}
+	p.started = true
+
+	if Out == nil {
+		Out = ioutil.Discard
+	}
+	if Err == nil {
+		Err = ioutil.Discard
+	}
+	if In == nil {
+		In = strings.NewReader("")
+	}
+	Err = &lockedWriter{Writer: Err} // written to by all stages concurrently
+
+	n := len(p.Stages)
+	outs := make([]io.Writer, n)
+	ins := make([]io.Reader, n)
+	closeOnExit := make([][]io.Closer, n) // closed once the stage has exited
+	var files []*os.File                  // closed once all stages have started
+
+	ins[0], outs[n-1] = In, Out
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+		if err != nil {
+			for _, closers := range closeOnExit {
+				for _, c := range closers {
+					c.Close()
+				}
+			}
+		}
+	}()
+
+	// initialize all stages
+	for _, stage := range p.Stages {
+		if err := stage.Init(ctx, false); err != nil {
+			abortCommands(p.Stages, 0)
+			return err
+		}
+	}
+
+	// connect adjacent stages
+	for i := 0; i < n-1; i++ {
+		left, leftExec := p.Stages[i].Process.(*ExecProcess)
+		right, rightExec := p.Stages[i+1].Process.(*ExecProcess)
+
+		if leftExec && rightExec {
+			r, w, err := os.Pipe()
+			if err != nil {
+				abortCommands(p.Stages, 0)
+				return err
+			}
+			files = append(files, r, w)
+			left.stdoutFile, right.stdinFile = w, r
+			outs[i], ins[i+1] = ioutil.Discard, strings.NewReader("")
+			continue
+		}
+
+		r, w := io.Pipe()
+		outs[i], ins[i+1] = w, r
+		closeOnExit[i] = append(closeOnExit[i], w)     // the next stage receives EOF
+		closeOnExit[i+1] = append(closeOnExit[i+1], r) // the previous stage can no longer write
+	}
+
+	// start all the stages
+	for i, stage := range p.Stages {
+		if err := stage.Start(outs[i], Err, ins[i]); err != nil {
+			abortCommands(p.Stages, i)
+			return err
+		}
+	}
+
+	// wait for all the stages in the background
+	p.done = make(chan struct{})
+	p.results = make([]StageResult, n)
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i, stage := range p.Stages {
+		go func(i int, stage *Command) {
+			defer wg.Done()
+
+			code, err := stage.Wait()
+			stage.waitOutputs()
+			p.results[i] = StageResult{Process: stage.String(), ExitCode: code, Err: err}
+
+			for _, c := range closeOnExit[i] {
+				c.Close()
+			}
+		}(i, stage)
+	}
+	go func() {
+		wg.Wait()
+		close(p.done)
+	}()
+
+	return nil
+}
+
+// Wait waits for all stages of the pipeline to exit.
+// It returns the exit code of the pipeline, and the first error returned by any stage.
+func (p *Pipeline) Wait() (int, error) {
+	p.m.Lock()
+	done := p.done
+	p.m.Unlock()
+
+	if done == nil {
+		return 0, ErrPipelineNotStarted
+	}
+	<-done
+
+	var code int
+	var err error
+	for _, result := range p.results {
+		if result.ExitCode != 0 {
+			code = result.ExitCode
+		}
+		if err == nil {
+			err = result.Err
+		}
+	}
+	return code, err
+}
+
+// Results returns the results of all stages, in order.
+// When not all stages have exited, returns nil.
+func (p *Pipeline) Results() []StageResult {
+	p.m.Lock()
+	done := p.done
+	p.m.Unlock()
+
+	if done == nil {
+		return nil
+	}
+	select {
+	case <-done:
+	default:
+		return nil
+	}
+
+	results := make([]StageResult, len(p.results))
+	copy(results, p.results)
+	return results
+}
+
+// Stop stops all stages of the pipeline.
+// It returns the first error returned by any stage.
+func (p *Pipeline) Stop() error {
+	p.m.Lock()
+	done := p.done
+	p.m.Unlock()
+
+	if done == nil {
+		return ErrPipelineNotStarted
+	}
+
+	var err error
+	for _, stage := range p.Stages {
+		if e := stage.Stop(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Cleanup cleans up all stages of the pipeline.
+// It returns the first error returned by any stage.
+func (p *Pipeline) Cleanup() error {
+	var err error
+	for _, stage := range p.Stages {
+		if e := stage.Cleanup(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// lockedWriter is an io.Writer that is safe for concurrent use
+type lockedWriter struct {
+	m sync.Mutex
+	io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.m.Lock()
+	defer lw.m.Unlock()
+
+	return lw.Writer.Write(p)
+}
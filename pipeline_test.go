@@ -0,0 +1,183 @@
+package procutil_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tkw1536/procutil"
+	"github.com/tkw1536/procutil/procutiltest"
+)
+
+// sh returns a process running script using /bin/sh
+func sh(script string) *procutil.ExecProcess {
+	return &procutil.ExecProcess{Command: "/bin/sh", Args: []string{"-c", script}}
+}
+
+func TestPipeline(t *testing.T) {
+	tests := []struct {
+		name      string
+		processes []procutil.Process
+		wantOut   string
+		wantCode  int
+		wantCodes []int
+	}{
+		{
+			"single stage",
+			[]procutil.Process{sh("echo hello")},
+			"hello\n", 0, []int{0},
+		},
+		{
+			"exec stages",
+			[]procutil.Process{sh("printf 'b\\na\\n'"), sh("sort")},
+			"a\nb\n", 0, []int{0, 0},
+		},
+		{
+			"mixed stages",
+			[]procutil.Process{sh("printf hello"), &procutiltest.Process{Echo: true}, sh("tr a-z A-Z")},
+			"HELLO", 0, []int{0, 0, 0},
+		},
+		{
+			"failing first stage",
+			[]procutil.Process{sh("echo hello; exit 3"), sh("cat")},
+			"hello\n", 3, []int{3, 0},
+		},
+		{
+			"several failing stages",
+			[]procutil.Process{sh("exit 3"), sh("cat; exit 4"), sh("cat")},
+			"", 4, []int{3, 4, 0},
+		},
+		{
+			"failing last stage",
+			[]procutil.Process{&procutiltest.Process{ExitCode: 5}, sh("cat; exit 2")},
+			"", 2, []int{5, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := procutil.NewPipeline(tt.processes...)
+
+			var stdout procutiltest.Buffer
+			if err := pipeline.Start(context.Background(), &stdout, nil, nil); err != nil {
+				t.Fatalf("Start() returned %s", err)
+			}
+			defer pipeline.Cleanup()
+
+			code, err := pipeline.Wait()
+			if code != tt.wantCode || err != nil {
+				t.Errorf("Wait() = (%d, %v), want (%d, nil)", code, err, tt.wantCode)
+			}
+			if got := stdout.String(); got != tt.wantOut {
+				t.Errorf("pipeline wrote %q, want %q", got, tt.wantOut)
+			}
+
+			var codes []int
+			for _, result := range pipeline.Results() {
+				codes = append(codes, result.ExitCode)
+			}
+			if !reflect.DeepEqual(codes, tt.wantCodes) {
+				t.Errorf("Results() has exit codes %v, want %v", codes, tt.wantCodes)
+			}
+		})
+	}
+}
+
+func TestPipeline_String(t *testing.T) {
+	pipeline := procutil.NewPipeline(&procutiltest.Process{Name: "a"}, &procutiltest.Process{Name: "b"})
+	if got := pipeline.String(); got != "a | b" {
+		t.Errorf("String() = %q, want \"a | b\"", got)
+	}
+}
+
+func TestPipeline_Stop(t *testing.T) {
+	pipeline := procutil.NewPipeline(sh("exec sleep 10"), &procutiltest.Process{Echo: true}, sh("exec sleep 10"))
+	if err := pipeline.Start(context.Background(), nil, nil, nil); err != nil {
+		t.Fatalf("Start() returned %s", err)
+	}
+	defer pipeline.Cleanup()
+
+	if err := pipeline.Stop(); err != nil {
+		t.Errorf("Stop() returned %s", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pipeline.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait() did not return after Stop()")
+	}
+
+	for _, result := range pipeline.Results() {
+		if result.ExitCode == 0 {
+			t.Errorf("stage %q exited with code 0 after Stop()", result.Process)
+		}
+	}
+}
+
+// Test that a pipeline finishes once its stages have exited, even when a child of a stage keeps its output open.
+func TestPipeline_grandchild(t *testing.T) {
+	pipeline := procutil.NewPipeline(sh("echo hi"), sh("sleep 5 & cat"))
+
+	var out bytes.Buffer
+	if err := pipeline.Start(context.Background(), &out, nil, nil); err != nil {
+		t.Fatalf("Start() returned %s", err)
+	}
+	defer pipeline.Cleanup()
+
+	start := time.Now()
+	if code, err := pipeline.Wait(); code != 0 || err != nil {
+		t.Errorf("Wait() returned (%d, %v), want (0, nil)", code, err)
+	}
+	if took := time.Since(start); took > 3*time.Second {
+		t.Errorf("Wait() took %s, want it to return after the default WaitDelay", took)
+	}
+	if out.String() != "hi\n" {
+		t.Errorf("pipeline wrote %q, want \"hi\\n\"", out.String())
+	}
+}
+
+func TestPipeline_errors(t *testing.T) {
+	if err := new(procutil.Pipeline).Start(context.Background(), nil, nil, nil); err != procutil.ErrPipelineNoStages {
+		t.Errorf("Start() on an empty pipeline returned %v, want ErrPipelineNoStages", err)
+	}
+
+	pipeline := procutil.NewPipeline(sh("true"))
+	if _, err := pipeline.Wait(); err != procutil.ErrPipelineNotStarted {
+		t.Errorf("Wait() before Start() returned %v, want ErrPipelineNotStarted", err)
+	}
+	if err := pipeline.Stop(); err != procutil.ErrPipelineNotStarted {
+		t.Errorf("Stop() before Start() returned %v, want ErrPipelineNotStarted", err)
+	}
+	if results := pipeline.Results(); results != nil {
+		t.Errorf("Results() before Start() returned %v, want nil", results)
+	}
+
+	pipeline.Start(context.Background(), nil, nil, nil)
+	pipeline.Wait()
+	if err := pipeline.Start(context.Background(), nil, nil, nil); err != procutil.ErrPipelineAlreadyStarted {
+		t.Errorf("second Start() returned %v, want ErrPipelineAlreadyStarted", err)
+	}
+}
+
+func TestPipeline_startFailure(t *testing.T) {
+	errStart := errors.New("start failed")
+	pipeline := procutil.NewPipeline(sh("exec sleep 10"), &procutiltest.Process{StartErr: errStart}, sh("cat"))
+
+	if err := pipeline.Start(context.Background(), nil, nil, nil); !errors.Is(err, errStart) {
+		t.Fatalf("Start() returned %v, want errStart", err)
+	}
+
+	for i, stage := range pipeline.Stages {
+		if state := stage.State(); state != procutil.CommandStateDone {
+			t.Errorf("stage %d has state %v, want %v", i, state, procutil.CommandStateDone)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package procutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ProcGroup runs several named commands side by side, as is common during local development.
+// The commands may run arbitrary processes, for example an *ExecProcess or a docker exec process.
+//
+// The output of all commands is multiplexed into Out line by line.
+// Every line is prefixed by the name of its command, padded to the length of the longest name.
+// When Color is set, prefixes are colored using ANSI escape sequences.
+//
+// By default, once any command exits, all other commands are stopped gracefully.
+// They are first sent StopSignal, when they support job control, and waited for for up to StopTimeout.
+// Commands that have not exited by then are stopped using Command.Stop().
+// When KeepRunning is set, the remaining commands instead continue to run.
+// Commands created by NewProcGroup run in their own process group, so that this also stops any processes they started.
+//
+// A ProcGroup may only be started once.
+type ProcGroup struct {
+	Procs []Proc // the commands to run
+
+	Out   io.Writer // receives the prefixed output of all commands, may be nil
+	Color bool      // color the prefixes using ANSI escape sequences
+
+	KeepRunning bool // when set, do not stop the remaining commands once a command exits
+
+	StopSignal  os.Signal     // signal used for graceful shutdown, defaults to SIGTERM
+	StopTimeout time.Duration // time to wait for commands to exit after StopSignal, defaults to DefaultStopTimeout
+
+	m        sync.Mutex
+	started  bool
+	stopping bool            // set once the group is being stopped
+	exited   []chan struct{} // closed once the corresponding command has exited
+	done     chan struct{}   // closed once all commands have exited
+	results  []ProcResult    // results of all commands, set once done is closed
+	failed   int             // index of the first command that failed on its own, or -1
+}
+
+// Proc is a single named command of a ProcGroup
+type Proc struct {
+	Name    string   // name of the command, used to prefix its output
+	Command *Command // the command to run
+}
+
+// ProcResult is the result of a single command of a ProcGroup
+type ProcResult struct {
+	Name     string // name of the command
+	ExitCode int    // exit code of the process
+	Err      error  // error returned when waiting for the command, if any
+	Stopped  bool   // true when the command exited after the group was stopped
+}
+
+// Errors returned by methods of ProcGroup
+var (
+	ErrProcGroupNoProcs        = errors.New("ProcGroup: No commands")
+	ErrProcGroupAlreadyStarted = errors.New("ProcGroup: Already started")
+	ErrProcGroupNotStarted     = errors.New("ProcGroup: Not started")
+	ErrProcGroupDuplicateName  = errors.New("ProcGroup: Duplicate command name")
+)
+
+// procGroupColors are the ANSI colors used for prefixes, in order
+var procGroupColors = []int{36, 33, 32, 35, 34, 31}
+
+// NewProcGroup creates a new ProcGroup with a command for each of the given Procfile entries
+func NewProcGroup(entries ...ProcfileEntry) *ProcGroup {
+	procs := make([]Proc, len(entries))
+	for i, entry := range entries {
+		procs[i] = Proc{Name: entry.Name, Command: &Command{Process: entry.Process()}}
+	}
+	return &ProcGroup{Procs: procs}
+}
+
+// String returns the names of all commands, separated by ", "
+func (g *ProcGroup) String() string {
+	names := make([]string, len(g.Procs))
+	for i, proc := range g.Procs {
+		names[i] = proc.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// Start initializes and starts all commands of the group.
+// The commands receive empty input.
+//
+// Once the context is closed, all commands are stopped gracefully.
+// When a command fails to initialize or start, all commands that have already been started are stopped, and an error is returned.
+func (g *ProcGroup) Start(ctx context.Context) error {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	if len(g.Procs) == 0 {
+		return ErrProcGroupNoProcs
+	}
+	if g.started {
+		return ErrProcGroupAlreadyStarted
+	}
+
+	width := 0
+	names := make(map[string]struct{}, len(g.Procs))
+	for _, proc := range g.Procs {
+		if _, ok := names[proc.Name]; ok {
+			return errors.Wrapf(ErrProcGroupDuplicateName, "%q", proc.Name)
+		}
+		names[proc.Name] = struct{}{}
+
+		if len(proc.Name) > width {
+			width = len(proc.Name)
+		}
+	}
+	g.started = true
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var out io.Writer = ioutil.Discard
+	if g.Out != nil {
+		out = g.Out
+	}
+	out = &lockedWriter{Writer: out} // written to by all commands concurrently
+
+	// the commands are only killed directly once graceful shutdown has failed
+	runCtx, cancel := context.WithCancel(valueContext{ctx})
+
+	// initialize all commands
+	for _, proc := range g.Procs {
+		if err := proc.Command.Init(runCtx, false); err != nil {
+			abortCommands(g.commands(), 0)
+			cancel()
+			return err
+		}
+	}
+
+	// start all commands
+	n := len(g.Procs)
+	prefixes := make([]string, n)
+	sinks := make([]*LineSink, n)
+	for i, proc := range g.Procs {
+		prefix := g.prefix(i, width)
+		prefixes[i] = prefix
+		sinks[i] = &LineSink{Handler: func(record Record) {
+			io.WriteString(out, prefix+record.Line+"\n")
+		}}
+
+		if err := proc.Command.Start(sinks[i].Stdout(), sinks[i].Stderr(), strings.NewReader("")); err != nil {
+			abortCommands(g.commands(), i)
+			cancel()
+			return err
+		}
+	}
+
+	// wait for all commands in the background
+	g.done = make(chan struct{})
+	g.results = make([]ProcResult, n)
+	g.failed = -1
+	g.exited = make([]chan struct{}, n)
+	for i := range g.exited {
+		g.exited[i] = make(chan struct{})
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := range g.Procs {
+		go func(i int) {
+			defer wg.Done()
+			g.watch(i, sinks[i], out, prefixes[i])
+		}(i)
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		close(g.done)
+	}()
+
+	// stop all commands once the context is closed
+	go func() {
+		select {
+		case <-ctx.Done():
+			g.Stop()
+		case <-g.done:
+		}
+	}()
+
+	return nil
+}
+
+// prefix returns the prefix for output of the i-th command, with the name padded to width
+func (g *ProcGroup) prefix(i, width int) string {
+	prefix := fmt.Sprintf("%-*s | ", width, g.Procs[i].Name)
+	if !g.Color {
+		return prefix
+	}
+	return fmt.Sprintf("\x1b[%dm%s\x1b[0m", procGroupColors[i%len(procGroupColors)], prefix)
+}
+
+// watch waits for the i-th command to exit, and records its result.
+// Unless KeepRunning is set, it then stops all other commands.
+func (g *ProcGroup) watch(i int, sink *LineSink, out io.Writer, prefix string) {
+	command := g.Procs[i].Command
+
+	code, err := command.Wait()
+	command.waitOutputs()
+	sink.Close()
+	fmt.Fprintf(out, "%sexited with code %d\n", prefix, code)
+
+	g.m.Lock()
+	stopped := g.stopping
+	g.results[i] = ProcResult{Name: g.Procs[i].Name, ExitCode: code, Err: err, Stopped: stopped}
+	if g.failed < 0 && !stopped && (code != 0 || err != nil) {
+		g.failed = i
+	}
+	close(g.exited[i])
+	g.m.Unlock()
+
+	if !g.KeepRunning && !stopped {
+		g.Stop()
+	}
+}
+
+// commands returns the commands of all procs, in order
+func (g *ProcGroup) commands() []*Command {
+	commands := make([]*Command, len(g.Procs))
+	for i, proc := range g.Procs {
+		commands[i] = proc.Command
+	}
+	return commands
+}
+
+// Wait waits for all commands of the group to exit.
+//
+// It returns the exit code and error of the first command that exited unsuccessfully on its own.
+// Commands that exited because the group was stopped are not taken into account.
+// When there is no such command, returns 0 and nil.
+func (g *ProcGroup) Wait() (int, error) {
+	g.m.Lock()
+	done := g.done
+	g.m.Unlock()
+
+	if done == nil {
+		return 0, ErrProcGroupNotStarted
+	}
+	<-done
+
+	if g.failed < 0 {
+		return 0, nil
+	}
+	result := g.results[g.failed]
+	return result.ExitCode, result.Err
+}
+
+// Results returns the results of all commands, in the order of Procs.
+// When not all commands have exited, returns nil.
+func (g *ProcGroup) Results() []ProcResult {
+	g.m.Lock()
+	done := g.done
+	g.m.Unlock()
+
+	if done == nil {
+		return nil
+	}
+	select {
+	case <-done:
+	default:
+		return nil
+	}
+
+	results := make([]ProcResult, len(g.results))
+	copy(results, g.results)
+	return results
+}
+
+// Stop gracefully stops all commands of the group, and returns once they have exited.
+func (g *ProcGroup) Stop() error {
+	g.m.Lock()
+	if g.done == nil {
+		g.m.Unlock()
+		return ErrProcGroupNotStarted
+	}
+	g.stopping = true
+	exited := g.exited
+	g.m.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(len(g.Procs))
+	for i, proc := range g.Procs {
+		go func(command *Command, exited <-chan struct{}) {
+			defer wg.Done()
+			stopGracefully(command, g.StopSignal, g.StopTimeout, exited)
+			<-exited
+		}(proc.Command, exited[i])
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// Signal sends sig to all commands of the group.
+// Commands that have already exited are skipped.
+// It returns the first error returned by any command, but always attempts to signal all commands.
+func (g *ProcGroup) Signal(sig os.Signal) error {
+	g.m.Lock()
+	done := g.done
+	g.m.Unlock()
+
+	if done == nil {
+		return ErrProcGroupNotStarted
+	}
+
+	var err error
+	for _, proc := range g.Procs {
+		if e := proc.Command.Signal(sig); e != nil && !errors.Is(e, ErrCommandNotRunning) && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// ForwardSignals forwards the given signals, when received by the current process, to all commands of the group.
+// When no signals are given, forwards os.Interrupt and SIGTERM.
+//
+// Forwarding continues until the returned function is called.
+func (g *ProcGroup) ForwardSignals(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+
+	quit := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-c:
+				g.Signal(sig)
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(c)
+			close(quit)
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package procutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// procGroupLoop is a script that runs until it is stopped
+const procGroupLoop = `echo ready; while true; do sleep 0.01; done`
+
+func TestProcGroup(t *testing.T) {
+	tests := []struct {
+		name        string
+		entries     []ProcfileEntry
+		keepRunning bool
+		wantCode    int
+		wantStopped []bool
+		wantLines   []string
+	}{
+		{
+			"stop all on success",
+			[]ProcfileEntry{{"a", "echo one"}, {"long", procGroupLoop}},
+			false,
+			0,
+			[]bool{false, true},
+			[]string{"a    | one", "a    | exited with code 0"},
+		},
+		{
+			"stop all on failure",
+			[]ProcfileEntry{{"long", procGroupLoop}, {"a", "echo one >&2; exit 3"}},
+			false,
+			3,
+			[]bool{true, false},
+			[]string{"a    | one", "a    | exited with code 3"},
+		},
+		{
+			"keep running",
+			[]ProcfileEntry{{"a", "exit 0"}, {"b", "sleep 0.1; echo two; exit 2"}},
+			true,
+			2,
+			[]bool{false, false},
+			[]string{"a | exited with code 0", "b | two", "b | exited with code 2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			group := NewProcGroup(tt.entries...)
+			group.Out = &out
+			group.KeepRunning = tt.keepRunning
+			group.StopTimeout = time.Second
+
+			if err := group.Start(context.Background()); err != nil {
+				t.Fatalf("Start() returned %s", err)
+			}
+			code, err := group.Wait()
+			if code != tt.wantCode || err != nil {
+				t.Errorf("Wait() = (%d, %v), want (%d, nil)", code, err, tt.wantCode)
+			}
+
+			results := group.Results()
+			if len(results) != len(tt.entries) {
+				t.Fatalf("Results() = %+v, want %d results", results, len(tt.entries))
+			}
+			for i, result := range results {
+				if result.Name != tt.entries[i].Name || result.Stopped != tt.wantStopped[i] {
+					t.Errorf("Results()[%d] = %+v, want name %q and stopped %t", i, result, tt.entries[i].Name, tt.wantStopped[i])
+				}
+			}
+
+			lines := strings.Split(out.String(), "\n")
+			for _, want := range tt.wantLines {
+				if !containsString(lines, want) {
+					t.Errorf("output %q does not contain line %q", out.String(), want)
+				}
+			}
+
+			if err := group.Start(context.Background()); err != ErrProcGroupAlreadyStarted {
+				t.Errorf("second Start() returned %v, want ErrProcGroupAlreadyStarted", err)
+			}
+		})
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestProcGroup_Start_invalid(t *testing.T) {
+	if err := (&ProcGroup{}).Start(context.Background()); err != ErrProcGroupNoProcs {
+		t.Errorf("Start() without procs returned %v, want ErrProcGroupNoProcs", err)
+	}
+
+	group := NewProcGroup(ProcfileEntry{"a", "true"}, ProcfileEntry{"a", "true"})
+	if err := group.Start(context.Background()); !errors.Is(err, ErrProcGroupDuplicateName) {
+		t.Errorf("Start() with duplicate names returned %v, want ErrProcGroupDuplicateName", err)
+	}
+
+	var idle ProcGroup
+	if _, err := idle.Wait(); err != ErrProcGroupNotStarted {
+		t.Errorf("Wait() returned %v, want ErrProcGroupNotStarted", err)
+	}
+	if err := idle.Stop(); err != ErrProcGroupNotStarted {
+		t.Errorf("Stop() returned %v, want ErrProcGroupNotStarted", err)
+	}
+	if err := idle.Signal(syscall.SIGTERM); err != ErrProcGroupNotStarted {
+		t.Errorf("Signal() returned %v, want ErrProcGroupNotStarted", err)
+	}
+}
+
+func TestProcGroup_prefix(t *testing.T) {
+	group := &ProcGroup{Procs: []Proc{{Name: "web"}, {Name: "worker"}}}
+	if got := group.prefix(0, 6); got != "web    | " {
+		t.Errorf("prefix() = %q, want \"web    | \"", got)
+	}
+
+	group.Color = true
+	if got := group.prefix(1, 6); got != "\x1b[33mworker | \x1b[0m" {
+		t.Errorf("prefix() with color = %q, want \"\\x1b[33mworker | \\x1b[0m\"", got)
+	}
+}
+
+// startProcGroup starts a group running the given scripts, and waits for each to print "ready"
+func startProcGroup(t *testing.T, ctx context.Context, scripts ...string) *ProcGroup {
+	ready := make(chan struct{}, len(scripts))
+	sink := &LineSink{Handler: func(record Record) {
+		if strings.HasSuffix(record.Line, "| ready") {
+			ready <- struct{}{}
+		}
+	}}
+
+	group := &ProcGroup{Out: sink.Stdout(), StopTimeout: time.Second}
+	for i, script := range scripts {
+		group.Procs = append(group.Procs, Proc{
+			Name:    string(rune('a' + i)),
+			Command: &Command{Process: ProcfileEntry{Command: script}.Process()},
+		})
+	}
+	if err := group.Start(ctx); err != nil {
+		t.Fatalf("Start() returned %s", err)
+	}
+
+	for range scripts {
+		select {
+		case <-ready:
+		case <-time.After(5 * time.Second):
+			t.Fatal("processes did not become ready")
+		}
+	}
+	return group
+}
+
+func TestProcGroup_Signal(t *testing.T) {
+	group := startProcGroup(t, context.Background(),
+		`trap "exit 5" USR1; `+procGroupLoop,
+		`trap "" USR1; `+procGroupLoop,
+	)
+	if err := group.Signal(syscall.SIGUSR1); err != nil {
+		t.Errorf("Signal() returned %s", err)
+	}
+
+	code, err := group.Wait()
+	if code != 5 || err != nil {
+		t.Errorf("Wait() = (%d, %v), want (5, nil)", code, err)
+	}
+	if err := group.Signal(syscall.SIGUSR1); err != nil {
+		t.Errorf("Signal() after exit returned %s, want nil", err)
+	}
+}
+
+func TestProcGroup_cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	group := startProcGroup(t, ctx,
+		`trap "exit 7" TERM; `+procGroupLoop,
+		`trap "" TERM; `+procGroupLoop,
+	)
+	cancel()
+
+	code, err := group.Wait()
+	if code != 0 || err != nil {
+		t.Errorf("Wait() = (%d, %v), want (0, nil)", code, err)
+	}
+
+	results := group.Results()
+	if len(results) != 2 || results[0].ExitCode != 7 || results[1].ExitCode != -1 {
+		t.Errorf("Results() = %+v, want exit codes 7 and -1", results)
+	}
+	for _, result := range results {
+		if !result.Stopped {
+			t.Errorf("Results() = %+v, want all stopped", results)
+			break
+		}
+	}
+}
+
+// Test that stopping a group also stops processes started by its commands.
+func TestProcGroup_Stop_children(t *testing.T) {
+	tests := []struct {
+		name string
+		trap string
+	}{
+		{"graceful", ""},
+		{"killed", `trap "" TERM; `},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaked := filepath.Join(t.TempDir(), "leaked")
+			group := startProcGroup(t, context.Background(), tt.trap+`(sleep 2; touch `+leaked+`) & echo ready; wait`)
+
+			start := time.Now()
+			if err := group.Stop(); err != nil {
+				t.Errorf("Stop() returned %s", err)
+			}
+			if took := time.Since(start); took > 3*time.Second {
+				t.Errorf("Stop() took %s", took)
+			}
+			group.Wait()
+
+			// a child that survived Stop() creates the file
+			time.Sleep(time.Until(start.Add(3 * time.Second)))
+			if _, err := os.Stat(leaked); err == nil {
+				t.Error("child of the command is still running after Stop()")
+			}
+		})
+	}
+}
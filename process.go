@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 
 	"github.com/tkw1536/procutil/term"
 )
@@ -47,3 +48,19 @@ type Process interface {
 	// Cleanup should be called at the end of the lifecyle of the process to clean it up.
 	Cleanup() error
 }
+
+// JobController is implemented by processes that support job control.
+// It is used by the job control methods of Command.
+type JobController interface {
+	// Signal sends sig to the process.
+	Signal(sig os.Signal) error
+
+	// Suspend stops the process.
+	Suspend() error
+
+	// Resume continues the process after it has been stopped.
+	Resume() error
+
+	// Stopped returns if the process is currently stopped, and a channel that is closed when this changes.
+	Stopped() (stopped bool, changed <-chan struct{})
+}
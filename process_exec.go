@@ -4,8 +4,12 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
 	"os/exec"
 	"strings"
+	"sync"
+	"syscall"
 
 	"github.com/pkg/errors"
 	"github.com/tkw1536/procutil/term"
@@ -19,15 +23,39 @@ type ExecProcess struct {
 	Workdir string   // workding directory of the process, defaults to ""
 	Env     []string // Environment variables of the form "KEY=VALUE"
 
-	cmd *exec.Cmd // command being run
+	// ProcessGroup starts the process in a new process group, so that children of the process receive its signals.
+	// Signal(), Suspend(), Resume() and Stop() then apply to the entire group.
+	// Once the context passed to Init() is closed, the entire group is killed.
+	// A process running on a pty always leads its own process group.
+	//
+	// Process groups are not supported on Windows, where Start() returns an error when ProcessGroup is set.
+	ProcessGroup bool
+
+	cmd *exec.Cmd     // command being run
+	pty term.Terminal // pty the command is running on, if any
+
+	ctx         context.Context // context passed to Init()
+	exited      chan struct{}   // closed by Wait() once the process has exited
+	groupKilled chan struct{}   // closed once killGroup() has returned
+
+	closeAfterStart []*os.File // write ends of output pipes
+
+	stdinFile, stdoutFile *os.File // ends of pipes connecting to other stages of a Pipeline, if any
+
+	jobM       sync.Mutex    // protects the fields below
+	stopped    bool          // is the process currently stopped?
+	jobChanged chan struct{} // closed and replaced whenever stopped changes
 }
 
-// ExecProcess implements the Process interface
+// ExecProcess implements the Process, JobController and PidProcess interfaces
 func init() {
 	var _ Process = (*ExecProcess)(nil)
+	var _ JobController = (*ExecProcess)(nil)
+	var _ PidProcess = (*ExecProcess)(nil)
 }
 
-// Init initializes this process
+// Init initializes this process.
+// Once ctx is closed, the process is killed.
 func (sp *ExecProcess) Init(ctx context.Context, isPty bool) error {
 	// exec.Command internally does use LookPath(), but doesn't return an error
 	// Instead we explicitly call LookPath() to intercept the error
@@ -38,10 +66,26 @@ func (sp *ExecProcess) Init(ctx context.Context, isPty bool) error {
 		return err
 	}
 
-	sp.cmd = exec.Command(exe, sp.Args...)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	sp.ctx = ctx
+
+	// kill the process once the context is closed, as documented by Command.Init()
+	sp.cmd = exec.CommandContext(ctx, exe, sp.Args...)
 	sp.cmd.Dir = sp.Workdir
 	sp.cmd.Env = sp.Env
 
+	// propagate the trace context, if any.
+	// A process not running on a pty inherits the environment of the current process when Env is nil, so keep doing so.
+	if tp := traceParentEnv(ctx); tp != "" {
+		env := sp.Env
+		if env == nil && !isPty {
+			env = os.Environ()
+		}
+		sp.cmd.Env = append(env[:len(env):len(env)], tp)
+	}
+
 	return nil
 }
 
@@ -54,36 +98,118 @@ func (sp *ExecProcess) String() string {
 	return strings.Join(append([]string{sp.cmd.Path}, sp.cmd.Args...), " ")
 }
 
-// Stdout returns a pipe to Stdout
+// Pid returns the process id of the process, or 0 if it has not been started
+func (sp *ExecProcess) Pid() int {
+	if sp == nil || sp.cmd == nil || sp.cmd.Process == nil {
+		return 0
+	}
+	return sp.cmd.Process.Pid
+}
+
+// Stdout returns a pipe to Stdout.
+// When the output is connected directly to the next stage of a Pipeline, the returned pipe is empty.
 func (sp *ExecProcess) Stdout() (io.ReadCloser, error) {
-	return sp.cmd.StdoutPipe()
+	if sp.stdoutFile != nil {
+		sp.cmd.Stdout = sp.stdoutFile
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	}
+
+	r, w, err := sp.outputPipe()
+	if err != nil {
+		return nil, err
+	}
+	sp.cmd.Stdout = w
+	return r, nil
 }
 
 // Stderr returns a pipe to Stderr
 func (sp *ExecProcess) Stderr() (io.ReadCloser, error) {
-	return sp.cmd.StderrPipe()
+	r, w, err := sp.outputPipe()
+	if err != nil {
+		return nil, err
+	}
+	sp.cmd.Stderr = w
+	return r, nil
+}
+
+// outputPipe creates a new pipe for output of the process.
+//
+// Unlike the pipes returned by cmd.StdoutPipe(), the read end is not closed by cmd.Wait().
+// This ensures that no output is lost when the process exits before all output has been read.
+// The write end is closed once the process has started.
+//
+// As a consequence, the read end only receives EOF once every process holding the write end has exited.
+// This includes children of the process that inherited it.
+// Command bounds the time it waits for such output once the process has exited, see Command.WaitDelay.
+func (sp *ExecProcess) outputPipe() (r, w *os.File, err error) {
+	r, w, err = os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	sp.closeAfterStart = append(sp.closeAfterStart, w)
+	return r, w, nil
 }
 
-// Stdin returns a pipe to Stdin
+// Stdin returns a pipe to Stdin.
+// When the input is connected directly to the previous stage of a Pipeline, the returned pipe discards all input.
 func (sp *ExecProcess) Stdin() (io.WriteCloser, error) {
+	if sp.stdinFile != nil {
+		sp.cmd.Stdin = sp.stdinFile
+		return nopWriteCloser{ioutil.Discard}, nil
+	}
+
 	return sp.cmd.StdinPipe()
 }
 
 // Start starts this process
 func (sp *ExecProcess) Start(Term string, resizeChan <-chan term.WindowSize, isPty bool) (term.Terminal, error) {
+	sp.jobChanged = make(chan struct{})
+
 	// not a pty => start the process and be done!
 	if !isPty {
-		return nil, sp.cmd.Start()
+		var err error
+		if sp.ProcessGroup {
+			err = term.SetNewProcessGroup(sp.cmd)
+		}
+		if err == nil {
+			err = sp.cmd.Start()
+		}
+		for _, f := range sp.closeAfterStart {
+			f.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+		go sp.monitorJob(sp.cmd.Process.Pid)
+		if sp.ProcessGroup {
+			sp.exited = make(chan struct{})
+			sp.groupKilled = make(chan struct{})
+			go sp.killGroup(sp.cmd.Process.Pid)
+		}
+		return nil, nil
 	}
 
 	// add the terminal environment variable
 	sp.cmd.Env = append(sp.cmd.Env, fmt.Sprintf("TERM=%s", Term))
 
+	// use a size that is already available as initial size
+	// so that the process can draw correctly right away.
+	var size *term.WindowSize
+	select {
+	case initial, ok := <-resizeChan:
+		if ok {
+			size = &initial
+		}
+	default:
+	}
+
 	// start the pty
-	t, err := term.ExecTerminal(sp.cmd)
+	t, err := term.ExecTerminalWithSize(sp.cmd, size)
 	if err != nil {
 		return nil, err
 	}
+	sp.pty = t
+	go sp.monitorJob(sp.cmd.Process.Pid)
 
 	// start tracking window size
 	go func() {
@@ -102,6 +228,12 @@ func (sp *ExecProcess) Wait() (code int, err error) {
 	err = sp.cmd.Wait()
 	code = 255
 
+	// wait for the rest of the process group to be killed, if needed
+	if sp.exited != nil {
+		close(sp.exited)
+		<-sp.groupKilled
+	}
+
 	// if we have a failure and it's not an exit code
 	// we need to return an error
 	_, isExitError := err.(*exec.ExitError)
@@ -115,22 +247,145 @@ func (sp *ExecProcess) Wait() (code int, err error) {
 	return code, nil
 }
 
-var errExecStopFailure = errors.New("ExecProcess: Failed to kill process")
+// ErrExecStopFailure is returned by ExecProcess.Stop() when the process could not be killed
+var ErrExecStopFailure = errors.New("ExecProcess: Failed to kill process")
 
 // Stop is used to stop a running process.
+// When ProcessGroup is set, all processes in the process group are killed.
+// When the process was killed, returns nil.
+// When it could not be killed, for instance because it has not been started, returns ErrExecStopFailure.
 func (sp *ExecProcess) Stop() (err error) {
-	// silence any panic()ing errors, but return false!
+	// silence any panic()ing errors, but return an error!
 	defer func() {
-		if err == nil {
-			recover()
-			err = errExecStopFailure
+		if recover() != nil {
+			err = ErrExecStopFailure
 		}
 	}()
 
+	// kill the entire group, falling back to the process itself
+	if sp.inGroup() {
+		if err := term.SignalGroup(sp.cmd.Process.Pid, syscall.SIGKILL); err == nil {
+			return nil
+		}
+	}
+
 	// kill the process, and prevent further attempts
 	return sp.cmd.Process.Kill()
 }
 
+// inGroup checks if the process has been started in a new process group using ProcessGroup
+func (sp *ExecProcess) inGroup() bool {
+	return sp.ProcessGroup && sp.pty == nil
+}
+
+// killGroup kills the process group led by pid once the context passed to Init() is closed.
+// exec.CommandContext() only kills the process itself, leaving other processes in the group running.
+// It returns once the process has exited.
+func (sp *ExecProcess) killGroup(pid int) {
+	defer close(sp.groupKilled)
+
+	select {
+	case <-sp.ctx.Done():
+	case <-sp.exited:
+	}
+
+	// the context may also have caused the process to exit
+	if sp.ctx.Err() != nil {
+		term.SignalGroup(pid, syscall.SIGKILL)
+	}
+}
+
+// monitorJob keeps track of the process being stopped and continued.
+// It returns once the process has exited.
+func (sp *ExecProcess) monitorJob(pid int) {
+	if !term.JobEventSupport {
+		return
+	}
+	defer sp.setStopped(false)
+
+	for {
+		event, err := term.WaitJobEvent(pid)
+		if err != nil || event == term.JobExited {
+			return
+		}
+		sp.setStopped(event == term.JobStopped)
+	}
+}
+
+func (sp *ExecProcess) setStopped(stopped bool) {
+	sp.jobM.Lock()
+	defer sp.jobM.Unlock()
+
+	if sp.stopped == stopped {
+		return
+	}
+	sp.stopped = stopped
+
+	close(sp.jobChanged)
+	sp.jobChanged = make(chan struct{})
+}
+
+// ErrExecNotRunning is returned by the job control methods of ExecProcess when the process is not running
+var ErrExecNotRunning = errors.New("ExecProcess: Process is not running")
+
+// Signal sends sig to the process.
+//
+// When the process is running on a pty, sig is sent to the foreground process group of the pty instead.
+// This matches the behaviour of signals generated by the keyboard, such as Ctrl-C.
+// Otherwise, when ProcessGroup is set, sig is sent to the process group of the process.
+func (sp *ExecProcess) Signal(sig os.Signal) error {
+	if sp.cmd == nil || sp.cmd.Process == nil {
+		return ErrExecNotRunning
+	}
+
+	if sp.pty != nil {
+		if pgid, err := term.ForegroundGroup(sp.pty); err == nil {
+			return term.SignalGroup(pgid, sig)
+		}
+	}
+	if sp.inGroup() {
+		return term.SignalGroup(sp.cmd.Process.Pid, sig)
+	}
+	return sp.cmd.Process.Signal(sig)
+}
+
+// signalJob sends sig to the process, or to its' process group when running on a pty or ProcessGroup is set.
+func (sp *ExecProcess) signalJob(sig syscall.Signal) error {
+	if sp.cmd == nil || sp.cmd.Process == nil {
+		return ErrExecNotRunning
+	}
+
+	// on a pty, the process is the leader of its' own process group.
+	if sp.pty != nil || sp.inGroup() {
+		return term.SignalGroup(sp.cmd.Process.Pid, sig)
+	}
+	return sp.cmd.Process.Signal(sig)
+}
+
+// Suspend stops the process by sending it SIGSTOP.
+// When the process is running on a pty or ProcessGroup is set, all processes in its' process group are stopped.
+func (sp *ExecProcess) Suspend() error {
+	return sp.signalJob(syscall.SIGSTOP)
+}
+
+// Resume continues a stopped process by sending it SIGCONT.
+// When the process is running on a pty or ProcessGroup is set, all processes in its' process group are continued.
+func (sp *ExecProcess) Resume() error {
+	if err := sp.signalJob(syscall.SIGCONT); err != nil {
+		return err
+	}
+	sp.setStopped(false)
+	return nil
+}
+
+// Stopped returns if the process is currently stopped, and a channel that is closed when this changes.
+func (sp *ExecProcess) Stopped() (stopped bool, changed <-chan struct{}) {
+	sp.jobM.Lock()
+	defer sp.jobM.Unlock()
+
+	return sp.stopped, sp.jobChanged
+}
+
 // Cleanup cleans up this process, typically killing it
 func (sp *ExecProcess) Cleanup() error {
 	sp.cmd.Process = nil // remove the process object
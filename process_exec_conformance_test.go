@@ -0,0 +1,15 @@
+package procutil_test
+
+import (
+	"testing"
+
+	"github.com/tkw1536/procutil"
+	"github.com/tkw1536/procutil/procutiltest"
+	"github.com/tkw1536/procutil/term"
+)
+
+func TestExecProcess_conformance(t *testing.T) {
+	procutiltest.TestProcess(t, func() procutil.Process {
+		return &procutil.ExecProcess{Command: "cat"}
+	}, procutiltest.Conformance{Echo: true, Pty: term.PTYSupport})
+}
@@ -0,0 +1,162 @@
+package procutil
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tkw1536/procutil/term"
+)
+
+// Test that the initial size is set before the process starts.
+func TestExecProcess_initialSize(t *testing.T) {
+	if !term.PTYSupport {
+		t.Skip("OS not supported")
+	}
+
+	command := &Command{
+		Process: &ExecProcess{
+			Command: "stty",
+			Args:    []string{"size"},
+		},
+	}
+	if err := command.Init(context.Background(), true); err != nil {
+		t.Fatalf("Command.Init() returned %s", err)
+	}
+
+	resizeChan := make(chan term.WindowSize, 1)
+	resizeChan <- term.WindowSize{Height: 40, Width: 120}
+	defer close(resizeChan)
+
+	e, err := StartExpect(command, "dumb", resizeChan)
+	if err != nil {
+		t.Fatalf("StartExpect() returned %s", err)
+	}
+	defer e.Wait()
+
+	if _, err := e.Expect(5*time.Second, ExpectLiteral("40 120")); err != nil {
+		t.Errorf("Expect() returned %s", err)
+	}
+}
+
+func TestExecProcess_context(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	process := &ExecProcess{Command: "sleep", Args: []string{"10"}}
+	if err := process.Init(ctx, false); err != nil {
+		t.Fatalf("Init() returned %s", err)
+	}
+	if _, err := process.Start("", nil, false); err != nil {
+		t.Fatalf("Start() returned %s", err)
+	}
+
+	cancel()
+
+	done := make(chan int)
+	go func() {
+		code, _ := process.Wait()
+		done <- code
+	}()
+	select {
+	case code := <-done:
+		if code != -1 {
+			t.Errorf("Wait() = %d, want -1", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("process was not killed once the context was closed")
+	}
+}
+
+func TestExecProcess_Stop(t *testing.T) {
+	process := &ExecProcess{Command: "sleep", Args: []string{"10"}}
+	if err := process.Init(context.Background(), false); err != nil {
+		t.Fatalf("Init() returned %s", err)
+	}
+
+	// there is no process to kill yet
+	if err := process.Stop(); err != ErrExecStopFailure {
+		t.Errorf("Stop() before Start() returned %v, want ErrExecStopFailure", err)
+	}
+
+	if _, err := process.Start("", nil, false); err != nil {
+		t.Fatalf("Start() returned %s", err)
+	}
+	if err := process.Stop(); err != nil {
+		t.Errorf("Stop() returned %s, want nil", err)
+	}
+	if code, err := process.Wait(); code != -1 || err != nil {
+		t.Errorf("Wait() = (%d, %v), want (-1, nil)", code, err)
+	}
+}
+
+// Test that no output is lost when the process exits before its output has been read.
+func TestExecProcess_output(t *testing.T) {
+	const size = 1024 * 1024
+	for i := 0; i < 5; i++ {
+		process := &ExecProcess{Command: "head", Args: []string{"-c", strconv.Itoa(size), "/dev/zero"}}
+		if err := process.Init(context.Background(), false); err != nil {
+			t.Fatalf("Init() returned %s", err)
+		}
+		stdout, _ := process.Stdout()
+		process.Stderr()
+		process.Stdin()
+
+		if _, err := process.Start("", nil, false); err != nil {
+			t.Fatalf("Start() returned %s", err)
+		}
+
+		read := make(chan int)
+		go func() {
+			data, _ := ioutil.ReadAll(stdout)
+			read <- len(data)
+		}()
+		process.Wait()
+
+		if n := <-read; n != size {
+			t.Fatalf("read %d bytes of output, want %d", n, size)
+		}
+	}
+}
+
+// Test that closing the context kills the entire process group.
+func TestExecProcess_ProcessGroup_context(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	leaked := filepath.Join(t.TempDir(), "leaked")
+	process := &ExecProcess{
+		Command:      "sh",
+		Args:         []string{"-c", `(sleep 1; touch ` + leaked + `) & echo ready; wait`},
+		ProcessGroup: true,
+	}
+	if err := process.Init(ctx, false); err != nil {
+		t.Fatalf("Init() returned %s", err)
+	}
+	stdout, _ := process.Stdout()
+	process.Stderr()
+	process.Stdin()
+	if _, err := process.Start("", nil, false); err != nil {
+		t.Fatalf("Start() returned %s", err)
+	}
+
+	// wait for the child to be forked
+	if _, err := bufio.NewReader(stdout).ReadString('\n'); err != nil {
+		t.Fatalf("reading output returned %s", err)
+	}
+
+	start := time.Now()
+	cancel()
+	process.Wait()
+
+	// a child that survived the context creates the file
+	time.Sleep(time.Until(start.Add(1500 * time.Millisecond)))
+	if _, err := os.Stat(leaked); err == nil {
+		t.Error("child of the process is still running after the context was closed")
+	}
+}
@@ -0,0 +1,355 @@
+package procutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tkw1536/procutil/term"
+)
+
+// RecordingProcess is a process that records the session of an underlying process into an asciinema v2 cast file.
+// It implements the Process interface, and otherwise behaves exactly like the underlying process.
+//
+// Output of the process is recorded as output events, and both standard output and standard error are recorded.
+// When running on a pty, resize events are recorded as well.
+// Once the process exits, the exit code is recorded using a CastExit event.
+type RecordingProcess struct {
+	Process Process   // underlying process
+	Output  io.Writer // writer to write the cast file to
+
+	RecordInput bool // also record input sent to the process
+
+	// MaxBytes is the maximum number of bytes of event data to record, or 0 for no limit.
+	// Once exceeded, a single "truncated" marker is recorded and further input and output is discarded.
+	MaxBytes int64
+
+	// Redact, when non-nil, is called with the data of every input and output event before it is recorded.
+	// It should return the data to record, or nil to omit the event entirely.
+	Redact func(typ CastEventType, data []byte) []byte
+
+	Width, Height int    // size of the terminal written to the header, defaults to 80x24
+	Title         string // title of the recording, optional
+
+	cast *CastWriter
+
+	cleanup     chan struct{} // closed once the process has been cleaned up
+	cleanupOnce sync.Once
+
+	m         sync.Mutex // protects written and truncated
+	written   int64      // number of bytes of event data written
+	truncated bool       // was the recording truncated?
+}
+
+// RecordingProcess implements the Process and PidProcess interfaces
+func init() {
+	var _ Process = (*RecordingProcess)(nil)
+	var _ PidProcess = (*RecordingProcess)(nil)
+}
+
+// String returns the string of the underlying process
+func (rp *RecordingProcess) String() string {
+	if rp == nil || rp.Process == nil {
+		return ""
+	}
+	return rp.Process.String()
+}
+
+// Pid returns the pid of the underlying process, or 0 if it does not implement PidProcess
+func (rp *RecordingProcess) Pid() int {
+	return processPid(rp.Process)
+}
+
+// Init initializes the underlying process and writes the header of the cast file
+func (rp *RecordingProcess) Init(ctx context.Context, isPty bool) error {
+	if err := rp.Process.Init(ctx, isPty); err != nil {
+		return err
+	}
+
+	header := CastHeader{
+		Width:     rp.Width,
+		Height:    rp.Height,
+		Timestamp: time.Now().Unix(),
+		Command:   rp.Process.String(),
+		Title:     rp.Title,
+	}
+	if header.Width <= 0 {
+		header.Width = 80
+	}
+	if header.Height <= 0 {
+		header.Height = 24
+	}
+
+	var err error
+	rp.cast, err = NewCastWriter(rp.Output, header)
+	return err
+}
+
+// Stdout returns the recorded standard output of the underlying process
+func (rp *RecordingProcess) Stdout() (io.ReadCloser, error) {
+	stdout, err := rp.Process.Stdout()
+	if err != nil || stdout == nil {
+		return stdout, err
+	}
+	return &recordingReader{ReadCloser: stdout, stream: rp.stream(CastOutput)}, nil
+}
+
+// Stderr returns the recorded standard error of the underlying process
+func (rp *RecordingProcess) Stderr() (io.ReadCloser, error) {
+	stderr, err := rp.Process.Stderr()
+	if err != nil || stderr == nil {
+		return stderr, err
+	}
+	return &recordingReader{ReadCloser: stderr, stream: rp.stream(CastOutput)}, nil
+}
+
+// Stdin returns the standard input of the underlying process, and records it if requested
+func (rp *RecordingProcess) Stdin() (io.WriteCloser, error) {
+	stdin, err := rp.Process.Stdin()
+	if err != nil || stdin == nil || !rp.RecordInput {
+		return stdin, err
+	}
+	return &recordingWriter{WriteCloser: stdin, stream: rp.stream(CastInput)}, nil
+}
+
+// Start starts the underlying process and records resize events and terminal data
+func (rp *RecordingProcess) Start(Term string, resizeChan <-chan term.WindowSize, isPty bool) (term.Terminal, error) {
+	if !isPty {
+		return rp.Process.Start(Term, resizeChan, isPty)
+	}
+
+	// record every resize event before passing it on.
+	// A size that is already available is passed on right away, as processes may check for an initial size without blocking.
+	rp.cleanup = make(chan struct{})
+	recordedChan := make(chan term.WindowSize, 1)
+	select {
+	case size, ok := <-resizeChan:
+		if !ok {
+			close(recordedChan)
+			break
+		}
+		rp.recordResize(size)
+		recordedChan <- size
+		go rp.forwardResizes(resizeChan, recordedChan)
+	default:
+		go rp.forwardResizes(resizeChan, recordedChan)
+	}
+
+	t, err := rp.Process.Start(Term, recordedChan, isPty)
+	if err != nil || t == nil {
+		return t, err
+	}
+
+	rwc := &recordingReadWriteCloser{
+		ReadWriteCloser: t.ReadWriteCloser(),
+		output:          rp.stream(CastOutput),
+	}
+	if rp.RecordInput {
+		rwc.input = rp.stream(CastInput)
+	}
+	return recordingTerminal{Terminal: t, rwc: rwc}, nil
+}
+
+// forwardResizes records sizes received from resizeChan, and passes them on to recordedChan.
+// Sizes that have not yet been received by the underlying process are replaced, so that forwarding never blocks.
+//
+// Once resizeChan is closed or the process is cleaned up, recordedChan is closed.
+// Sizes received after cleanup are discarded until resizeChan is closed, so that the sender is never blocked.
+func (rp *RecordingProcess) forwardResizes(resizeChan <-chan term.WindowSize, recordedChan chan term.WindowSize) {
+	for {
+		select {
+		case size, ok := <-resizeChan:
+			if !ok {
+				close(recordedChan)
+				return
+			}
+			rp.recordResize(size)
+			term.SendLatest(recordedChan, size)
+		case <-rp.cleanup:
+			close(recordedChan)
+			for range resizeChan {
+			}
+			return
+		}
+	}
+}
+
+// recordResize records a resize event
+func (rp *RecordingProcess) recordResize(size term.WindowSize) {
+	rp.record(CastResize, fmt.Sprintf("%dx%d", size.Width, size.Height))
+}
+
+// Stop stops the underlying process
+func (rp *RecordingProcess) Stop() error {
+	return rp.Process.Stop()
+}
+
+// Wait waits for the underlying process and records the exit code
+func (rp *RecordingProcess) Wait() (int, error) {
+	code, err := rp.Process.Wait()
+	if err == nil {
+		rp.cast.WriteEvent(CastExit, strconv.Itoa(code))
+	}
+	return code, err
+}
+
+// Cleanup cleans up the underlying process, and stops forwarding resize events
+func (rp *RecordingProcess) Cleanup() error {
+	if rp.cleanup != nil {
+		rp.cleanupOnce.Do(func() { close(rp.cleanup) })
+	}
+	return rp.Process.Cleanup()
+}
+
+// stream returns a new stream that records events of the given type.
+func (rp *RecordingProcess) stream(typ CastEventType) *recordingStream {
+	return &recordingStream{rp: rp, typ: typ}
+}
+
+// record records an event, applying redaction and the size limit.
+func (rp *RecordingProcess) record(typ CastEventType, data string) {
+	if typ == CastInput || typ == CastOutput {
+		if rp.Redact != nil {
+			redacted := rp.Redact(typ, []byte(data))
+			if redacted == nil {
+				return
+			}
+			data = string(redacted)
+		}
+	}
+
+	rp.m.Lock()
+	if rp.truncated {
+		rp.m.Unlock()
+		return
+	}
+	rp.written += int64(len(data))
+	if rp.MaxBytes > 0 && rp.written > rp.MaxBytes {
+		rp.truncated = true
+		typ, data = CastMarker, "truncated"
+	}
+	rp.m.Unlock()
+
+	rp.cast.WriteEvent(typ, data)
+}
+
+// recordingStream records data of a single stream.
+// It holds back incomplete utf8 sequences until they are complete, or the stream ends.
+type recordingStream struct {
+	rp  *RecordingProcess
+	typ CastEventType
+
+	m       sync.Mutex
+	pending []byte
+}
+
+func (rs *recordingStream) record(p []byte) {
+	rs.m.Lock()
+	defer rs.m.Unlock()
+
+	var complete []byte
+	complete, rs.pending = castUTF8(append(rs.pending, p...))
+	rs.pending = append([]byte(nil), rs.pending...)
+
+	if len(complete) > 0 {
+		rs.rp.record(rs.typ, string(complete))
+	}
+}
+
+// flush records an incomplete utf8 sequence that is still held back, replacing it with utf8.RuneError.
+// It is called once the stream has ended.
+func (rs *recordingStream) flush() {
+	rs.m.Lock()
+	defer rs.m.Unlock()
+
+	if len(rs.pending) == 0 {
+		return
+	}
+	rs.pending = nil
+	rs.rp.record(rs.typ, string(utf8.RuneError))
+}
+
+// recordingReader records everything read from an underlying reader.
+type recordingReader struct {
+	io.ReadCloser
+	stream *recordingStream
+}
+
+func (rr *recordingReader) Read(p []byte) (n int, err error) {
+	n, err = rr.ReadCloser.Read(p)
+	if n > 0 {
+		rr.stream.record(p[:n])
+	}
+	if err == io.EOF {
+		rr.stream.flush()
+	}
+	return
+}
+
+func (rr *recordingReader) Close() error {
+	rr.stream.flush()
+	return rr.ReadCloser.Close()
+}
+
+// recordingWriter records everything written to an underlying writer.
+type recordingWriter struct {
+	io.WriteCloser
+	stream *recordingStream
+}
+
+func (rw *recordingWriter) Write(p []byte) (n int, err error) {
+	n, err = rw.WriteCloser.Write(p)
+	if n > 0 {
+		rw.stream.record(p[:n])
+	}
+	return
+}
+
+func (rw *recordingWriter) Close() error {
+	rw.stream.flush()
+	return rw.WriteCloser.Close()
+}
+
+// recordingReadWriteCloser records reads as output and optionally writes as input.
+type recordingReadWriteCloser struct {
+	io.ReadWriteCloser
+	output, input *recordingStream
+}
+
+func (rwc *recordingReadWriteCloser) Read(p []byte) (n int, err error) {
+	n, err = rwc.ReadWriteCloser.Read(p)
+	if n > 0 {
+		rwc.output.record(p[:n])
+	}
+	return
+}
+
+func (rwc *recordingReadWriteCloser) Write(p []byte) (n int, err error) {
+	n, err = rwc.ReadWriteCloser.Write(p)
+	if n > 0 && rwc.input != nil {
+		rwc.input.record(p[:n])
+	}
+	return
+}
+
+func (rwc *recordingReadWriteCloser) Close() error {
+	rwc.output.flush()
+	if rwc.input != nil {
+		rwc.input.flush()
+	}
+	return rwc.ReadWriteCloser.Close()
+}
+
+// recordingTerminal is a terminal whose ReadWriteCloser is recorded.
+type recordingTerminal struct {
+	term.Terminal
+	rwc io.ReadWriteCloser
+}
+
+func (rt recordingTerminal) ReadWriteCloser() io.ReadWriteCloser {
+	return rt.rwc
+}
@@ -0,0 +1,238 @@
+package procutil
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tkw1536/procutil/term"
+)
+
+func TestRecordingProcess(t *testing.T) {
+	tests := []struct {
+		name        string
+		recordInput bool
+		maxBytes    int64
+		redact      func(typ CastEventType, data []byte) []byte
+		want        []CastEvent
+	}{
+		{
+			name: "output only",
+			want: []CastEvent{
+				{Type: CastOutput, Data: "output"},
+				{Type: CastExit, Data: "3"},
+			},
+		},
+		{
+			name:        "output and input",
+			recordInput: true,
+			want: []CastEvent{
+				{Type: CastInput, Data: "input"},
+				{Type: CastOutput, Data: "output"},
+				{Type: CastExit, Data: "3"},
+			},
+		},
+		{
+			name:     "truncated",
+			maxBytes: 3,
+			want: []CastEvent{
+				{Type: CastMarker, Data: "truncated"},
+				{Type: CastExit, Data: "3"},
+			},
+		},
+		{
+			name: "redacted",
+			redact: func(typ CastEventType, data []byte) []byte {
+				return bytes.ToUpper(data)
+			},
+			want: []CastEvent{
+				{Type: CastOutput, Data: "OUTPUT"},
+				{Type: CastExit, Data: "3"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cast bytes.Buffer
+
+			command := &Command{
+				Process: &RecordingProcess{
+					Process:     &testProcess{Out: "output", ExitCode: 3},
+					Output:      &cast,
+					RecordInput: tt.recordInput,
+					MaxBytes:    tt.maxBytes,
+					Redact:      tt.redact,
+				},
+			}
+
+			if err := command.Init(nil, false); err != nil {
+				t.Fatalf("Command.Init() returned %s", err)
+			}
+
+			var out, errOut bytes.Buffer
+			if err := command.Start(&out, &errOut, strings.NewReader("input")); err != nil {
+				t.Fatalf("Command.Start() returned %s", err)
+			}
+
+			if code, err := command.Wait(); code != 3 || err != nil {
+				t.Fatalf("Command.Wait() = (%d, %v), want (3, nil)", code, err)
+			}
+
+			if out.String() != "output" {
+				t.Errorf("Command didn't write output")
+			}
+
+			got, err := ReadCast(&cast)
+			if err != nil {
+				t.Fatalf("ReadCast() returned %s", err)
+			}
+			for i := range got.Events {
+				got.Events[i].Time = 0
+			}
+
+			// input and output are recorded concurrently, so compare them as a set.
+			if len(got.Events) != len(tt.want) {
+				t.Fatalf("recorded %v, want %v", got.Events, tt.want)
+			}
+			for _, want := range tt.want {
+				found := false
+				for _, event := range got.Events {
+					if event == want {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("recorded %v, want %v", got.Events, tt.want)
+				}
+			}
+			if last := got.Events[len(got.Events)-1]; last.Type != CastExit {
+				t.Errorf("last recorded event is %v, want exit event", last)
+			}
+		})
+	}
+}
+
+func TestRecordingProcess_resize(t *testing.T) {
+	if !term.PTYSupport {
+		t.Skip("OS not supported")
+	}
+
+	var cast bytes.Buffer
+	command := &Command{
+		Process: &RecordingProcess{
+			Process: &ExecProcess{
+				Command: "/bin/sh",
+				Args:    []string{"-c", "while true; do stty size; sleep 0.01; done"},
+			},
+			Output: &cast,
+		},
+	}
+	if err := command.Init(context.Background(), true); err != nil {
+		t.Fatalf("Command.Init() returned %s", err)
+	}
+
+	resizeChan := make(chan term.WindowSize)
+	e, err := StartExpect(command, "dumb", resizeChan)
+	if err != nil {
+		t.Fatalf("StartExpect() returned %s", err)
+	}
+
+	resizeChan <- term.WindowSize{Height: 40, Width: 120}
+	if _, err := e.Expect(5*time.Second, ExpectLiteral("40 120")); err != nil {
+		t.Errorf("Expect() returned %s", err)
+	}
+
+	// once the process has exited, resizes are discarded without blocking the sender
+	command.Stop()
+	e.Wait()
+	command.Cleanup()
+
+	for i := 0; i < 10; i++ {
+		select {
+		case resizeChan <- term.WindowSize{Height: 10, Width: 10}:
+		case <-time.After(5 * time.Second):
+			t.Fatal("sending resizes blocked after the process exited")
+		}
+	}
+	close(resizeChan)
+
+	got, err := ReadCast(&cast)
+	if err != nil {
+		t.Fatalf("ReadCast() returned %s", err)
+	}
+	want := CastEvent{Type: CastResize, Data: "120x40"}
+	found := false
+	for _, event := range got.Events {
+		event.Time = 0
+		if event == want {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("recorded %v, want it to contain %v", got.Events, want)
+	}
+}
+
+// Test that the initial size is passed on before the underlying process starts.
+func TestRecordingProcess_initialSize(t *testing.T) {
+	if !term.PTYSupport {
+		t.Skip("OS not supported")
+	}
+
+	var cast bytes.Buffer
+	command := &Command{
+		Process: &RecordingProcess{
+			Process: &ExecProcess{Command: "stty", Args: []string{"size"}},
+			Output:  &cast,
+		},
+	}
+	if err := command.Init(context.Background(), true); err != nil {
+		t.Fatalf("Command.Init() returned %s", err)
+	}
+
+	resizeChan := make(chan term.WindowSize, 1)
+	resizeChan <- term.WindowSize{Height: 40, Width: 120}
+	defer close(resizeChan)
+
+	e, err := StartExpect(command, "dumb", resizeChan)
+	if err != nil {
+		t.Fatalf("StartExpect() returned %s", err)
+	}
+	defer e.Wait()
+
+	if _, err := e.Expect(5*time.Second, ExpectLiteral("40 120")); err != nil {
+		t.Errorf("Expect() returned %s", err)
+	}
+}
+
+// Test that an incomplete utf8 sequence at the end of a stream is recorded.
+func TestRecordingProcess_incompleteUTF8(t *testing.T) {
+	var cast bytes.Buffer
+	writer, err := NewCastWriter(&cast, CastHeader{Width: 80, Height: 24})
+	if err != nil {
+		t.Fatalf("NewCastWriter() returned %s", err)
+	}
+	rp := &RecordingProcess{cast: writer}
+
+	reader := &recordingReader{ReadCloser: ioutil.NopCloser(strings.NewReader("output\xe2\x82")), stream: rp.stream(CastOutput)}
+	if _, err := ioutil.ReadAll(reader); err != nil {
+		t.Fatalf("ReadAll() returned %s", err)
+	}
+	reader.Close()
+
+	got, err := ReadCast(&cast)
+	if err != nil {
+		t.Fatalf("ReadCast() returned %s", err)
+	}
+	want := []CastEvent{{Type: CastOutput, Data: "output"}, {Type: CastOutput, Data: "\ufffd"}}
+	for i := range got.Events {
+		got.Events[i].Time = 0
+	}
+	if !reflect.DeepEqual(got.Events, want) {
+		t.Errorf("recorded %v, want %v", got.Events, want)
+	}
+}
@@ -0,0 +1,302 @@
+package procutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tkw1536/procutil/term"
+)
+
+// ReplayProcess is a process that plays back a recorded cast file, for instance one written by RecordingProcess.
+// It implements the Process interface.
+//
+// Output events are written to standard output (or the pty) with the recorded timing, scaled by Speed.
+// Resize events update the size returned by Size(); they are not otherwise applied.
+// Input to the process is discarded.
+// The process exits with the exit code of the last CastExit event, or 0 if there is none.
+type ReplayProcess struct {
+	Cast *Cast // cast to play back
+
+	// Speed is the factor to speed up playback by, defaults to 1.
+	// A negative speed plays back all events without any delay.
+	Speed float64
+
+	// IdleLimit is the maximum delay between two events, before applying Speed.
+	// Defaults to the idle time limit of the cast header, 0 means no limit.
+	IdleLimit time.Duration
+
+	ctx context.Context
+
+	output      *io.PipeWriter // output is written here
+	stdout, pty io.ReadCloser  // read ends of output
+
+	stdoutClosed chan struct{} // closed once stdout has been closed
+
+	m    sync.Mutex
+	size term.WindowSize // current size of the terminal
+
+	stopOnce sync.Once
+	stopChan chan struct{} // closed on a call to Stop()
+	doneChan chan struct{} // closed once playback is finished
+	code     int           // exit code, valid once doneChan is closed
+}
+
+// ReplayProcess implements the Process interface
+func init() {
+	var _ Process = (*ReplayProcess)(nil)
+}
+
+// NewReplayProcess reads a cast file from reader and returns a process to replay it.
+func NewReplayProcess(reader io.Reader) (*ReplayProcess, error) {
+	cast, err := ReadCast(reader)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayProcess{Cast: cast}, nil
+}
+
+// String returns the command or title of the recording
+func (rp *ReplayProcess) String() string {
+	if rp == nil || rp.Cast == nil {
+		return ""
+	}
+
+	if rp.Cast.Header.Command != "" {
+		return rp.Cast.Header.Command
+	}
+	return rp.Cast.Header.Title
+}
+
+var (
+	errReplayNoCast  = errors.New("ReplayProcess: No cast to replay")
+	errReplayStopped = errors.New("ReplayProcess: Playback stopped")
+)
+
+// Init initializes this process.
+// Once ctx is closed, playback is stopped as if Stop() had been called.
+func (rp *ReplayProcess) Init(ctx context.Context, isPty bool) error {
+	if rp.Cast == nil {
+		return errReplayNoCast
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	rp.ctx = ctx
+	rp.size = term.WindowSize{
+		Height: term.Size(rp.Cast.Header.Height),
+		Width:  term.Size(rp.Cast.Header.Width),
+	}
+
+	var r *io.PipeReader
+	r, rp.output = io.Pipe()
+	if isPty {
+		rp.pty = r
+	} else {
+		rp.stdoutClosed = make(chan struct{})
+		rp.stdout = &replayStdout{PipeReader: r, closed: rp.stdoutClosed}
+	}
+
+	rp.stopChan = make(chan struct{})
+	rp.doneChan = make(chan struct{})
+	return nil
+}
+
+// Stdout returns a pipe to Stdout
+func (rp *ReplayProcess) Stdout() (io.ReadCloser, error) {
+	return rp.stdout, nil
+}
+
+// Stderr returns a pipe to Stderr, which is always empty
+func (rp *ReplayProcess) Stderr() (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader("")), nil
+}
+
+// Stdin returns a pipe to Stdin, which discards all input
+func (rp *ReplayProcess) Stdin() (io.WriteCloser, error) {
+	return nopWriteCloser{ioutil.Discard}, nil
+}
+
+// Start starts playing back the cast
+func (rp *ReplayProcess) Start(Term string, resizeChan <-chan term.WindowSize, isPty bool) (term.Terminal, error) {
+	go rp.play()
+
+	if !isPty {
+		return nil, nil
+	}
+
+	go func() {
+		for size := range resizeChan {
+			rp.m.Lock()
+			rp.size = size
+			rp.m.Unlock()
+		}
+	}()
+
+	return term.NewTerminal(replayPty{rp.pty}), nil
+}
+
+// Size returns the current size of the terminal the cast is played back on.
+// This is the size from the header of the cast, unless the process has since been resized,
+// either by a resize event of the cast or by a resize of the pty.
+func (rp *ReplayProcess) Size() term.WindowSize {
+	rp.m.Lock()
+	defer rp.m.Unlock()
+
+	return rp.size
+}
+
+// play plays back all events and then exits
+func (rp *ReplayProcess) play() {
+	defer close(rp.doneChan)
+	defer rp.output.Close()
+
+	// unblock a pending write once the context is closed, like Stop() does
+	go func() {
+		select {
+		case <-rp.ctx.Done():
+			rp.output.CloseWithError(rp.ctx.Err())
+		case <-rp.doneChan:
+		}
+	}()
+
+	speed := rp.Speed
+	if speed == 0 {
+		speed = 1
+	}
+
+	idle := rp.IdleLimit
+	if idle == 0 {
+		idle = time.Duration(rp.Cast.Header.IdleTimeLimit * float64(time.Second))
+	}
+
+	var last float64
+	for _, event := range rp.Cast.Events {
+		delay := time.Duration((event.Time - last) * float64(time.Second))
+		last = event.Time
+
+		if idle > 0 && delay > idle {
+			delay = idle
+		}
+		if speed > 0 && delay > 0 {
+			if !rp.sleep(time.Duration(float64(delay) / speed)) {
+				rp.code = -1
+				return
+			}
+		}
+
+		switch event.Type {
+		case CastOutput:
+			if _, err := io.WriteString(rp.output, event.Data); err != nil {
+				rp.code = -1
+				return
+			}
+		case CastResize:
+			if size, ok := parseCastSize(event.Data); ok {
+				rp.m.Lock()
+				rp.size = size
+				rp.m.Unlock()
+			}
+		case CastExit:
+			rp.code, _ = strconv.Atoi(event.Data)
+		}
+	}
+}
+
+// parseCastSize parses the data of a resize event, of the form "WIDTHxHEIGHT"
+func parseCastSize(data string) (size term.WindowSize, ok bool) {
+	parts := strings.SplitN(data, "x", 2)
+	if len(parts) != 2 {
+		return size, false
+	}
+	width, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return size, false
+	}
+	height, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return size, false
+	}
+	return term.WindowSize{Width: term.Size(width), Height: term.Size(height)}, true
+}
+
+// sleep sleeps for the provided duration, and returns false if the process was stopped in the meantime.
+func (rp *ReplayProcess) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-rp.stopChan:
+		return false
+	case <-rp.ctx.Done():
+		return false
+	}
+}
+
+// Stop stops playback.
+// Output that is still being written is discarded, and readers of the output receive an error.
+func (rp *ReplayProcess) Stop() error {
+	rp.stopOnce.Do(func() {
+		close(rp.stopChan)
+		rp.output.CloseWithError(errReplayStopped)
+	})
+	return nil
+}
+
+// Wait waits for playback to finish and returns the recorded exit code.
+// When playback was stopped early, returns -1.
+//
+// When not running on a pty, additionally waits for standard output to be closed by the caller.
+func (rp *ReplayProcess) Wait() (int, error) {
+	<-rp.doneChan
+	if rp.stdoutClosed != nil {
+		<-rp.stdoutClosed
+	}
+	return rp.code, nil
+}
+
+// Cleanup stops playback if it is still in progress
+func (rp *ReplayProcess) Cleanup() error {
+	return rp.Stop()
+}
+
+// replayStdout is the standard output of a ReplayProcess.
+// It closes closed once it is closed.
+type replayStdout struct {
+	*io.PipeReader
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (rs *replayStdout) Close() error {
+	rs.closeOnce.Do(func() { close(rs.closed) })
+	return rs.PipeReader.Close()
+}
+
+// replayPty is the pty of a ReplayProcess.
+// It discards all writes.
+type replayPty struct {
+	io.ReadCloser
+}
+
+func (replayPty) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// nopWriteCloser is an io.WriteCloser with a no-op Close method.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,144 @@
+package procutil
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tkw1536/procutil/term"
+)
+
+const testReplayCast = `{"version": 2, "width": 80, "height": 24, "command": "/bin/echo"}
+[0.1, "o", "hello "]
+[0.2, "r", "100x30"]
+[0.3, "i", "ignored"]
+[0.4, "o", "world"]
+[0.5, "x", "42"]
+`
+
+func TestReplayProcess(t *testing.T) {
+	tests := []struct {
+		name     string
+		speed    float64
+		stop     bool
+		wantOut  string
+		wantCode int
+	}{
+		{"replay at original speed", 1, false, "hello world", 42},
+		{"replay without delay", -1, false, "hello world", 42},
+		{"replay and stop", 0.01, true, "", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			process, err := NewReplayProcess(strings.NewReader(testReplayCast))
+			if err != nil {
+				t.Fatalf("NewReplayProcess() returned %s", err)
+			}
+			process.Speed = tt.speed
+
+			command := &Command{Process: process}
+			if err := command.Init(nil, false); err != nil {
+				t.Fatalf("Command.Init() returned %s", err)
+			}
+
+			var out, errOut bytes.Buffer
+			if err := command.Start(&out, &errOut, strings.NewReader("")); err != nil {
+				t.Fatalf("Command.Start() returned %s", err)
+			}
+
+			if tt.stop {
+				time.Sleep(10 * time.Millisecond)
+				if err := command.Stop(); err != nil {
+					t.Fatalf("Command.Stop() returned %s", err)
+				}
+			}
+
+			if code, err := command.Wait(); code != tt.wantCode || err != nil {
+				t.Errorf("Command.Wait() = (%d, %v), want (%d, nil)", code, err, tt.wantCode)
+			}
+			command.Cleanup()
+
+			if got := out.String(); got != tt.wantOut {
+				t.Errorf("Command wrote %q, want %q", got, tt.wantOut)
+			}
+
+			// the resize event is only replayed when playback was not stopped before it
+			wantSize := term.WindowSize{Width: 100, Height: 30}
+			if tt.stop {
+				wantSize = term.WindowSize{Width: 80, Height: 24}
+			}
+			if got := process.Size(); got != wantSize {
+				t.Errorf("Size() = %v, want %v", got, wantSize)
+			}
+		})
+	}
+}
+
+func TestReplayProcess_Stop_unread(t *testing.T) {
+	process, err := NewReplayProcess(strings.NewReader(testReplayCast))
+	if err != nil {
+		t.Fatalf("NewReplayProcess() returned %s", err)
+	}
+	process.Speed = -1
+
+	if err := process.Init(context.Background(), true); err != nil {
+		t.Fatalf("Init() returned %s", err)
+	}
+	if _, err := process.Start("dumb", nil, true); err != nil {
+		t.Fatalf("Start() returned %s", err)
+	}
+
+	// nobody reads the output, so playback is blocked until stopped
+	process.Stop()
+
+	done := make(chan int)
+	go func() {
+		code, _ := process.Wait()
+		done <- code
+	}()
+	select {
+	case code := <-done:
+		if code != -1 {
+			t.Errorf("Wait() = %d, want -1", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait() did not return after Stop()")
+	}
+}
+
+func TestReplayProcess_context_unread(t *testing.T) {
+	process, err := NewReplayProcess(strings.NewReader(testReplayCast))
+	if err != nil {
+		t.Fatalf("NewReplayProcess() returned %s", err)
+	}
+	process.Speed = -1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := process.Init(ctx, true); err != nil {
+		t.Fatalf("Init() returned %s", err)
+	}
+	if _, err := process.Start("dumb", nil, true); err != nil {
+		t.Fatalf("Start() returned %s", err)
+	}
+
+	// nobody reads the output, so playback is blocked until the context is closed
+	cancel()
+
+	done := make(chan int)
+	go func() {
+		code, _ := process.Wait()
+		done <- code
+	}()
+	select {
+	case code := <-done:
+		if code != -1 {
+			t.Errorf("Wait() = %d, want -1", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait() did not return after closing the context")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"runtime"
 	"sync"
+	"time"
 
 	"github.com/tkw1536/procutil/term"
 )
@@ -112,6 +113,9 @@ func (sp *StreamingProcess) initTerm() error {
 	sp.stdin = tty.ReadWriteCloser()
 	sp.stdinTerm = tty
 
+	// there is no separate standard error
+	sp.stderrTerm = term.NewTerminal(nil)
+
 	return nil
 }
 
@@ -152,7 +156,7 @@ func (sp *StreamingProcess) restoreTerminals() {
 	sp.restoreOnce.Do(func() {
 		sp.stdoutTerm.RestoreInput()
 		sp.stderrTerm.RestoreInput()
-		sp.stdinTerm.RestoreOutput()
+		sp.stdoutTerm.RestoreOutput()
 
 		// this check has been adapted from upstream; for some reason they hang on specific platforms
 		if in := sp.stdinTerm.ReadWriteCloser(); in != nil && runtime.GOOS != "darwin" && runtime.GOOS != "windows" {
@@ -161,30 +165,48 @@ func (sp *StreamingProcess) restoreTerminals() {
 	})
 }
 
+// streamResizeDebounce is the minimal delay between resize requests sent to the streamer
+const streamResizeDebounce = 100 * time.Millisecond
+
 // Start starts this process
 func (sp *StreamingProcess) Start(Term string, resizeChan <-chan term.WindowSize, isPty bool) (term.Terminal, error) {
 	if err := sp.Streamer.Init(sp.ctx, Term, isPty); err != nil {
 		return nil, err
 	}
 
+	// use a size that is already available as the initial size
+	var initial *term.WindowSize
 	if isPty {
-		// start resizing the terminal
-		go func() {
-			if resizeChan == nil {
-				return
-			}
-			for size := range resizeChan {
+		select {
+		case size, ok := <-resizeChan:
+			if ok {
+				initial = &size
 				sp.ptyTerm.ResizeTo(size)
-				sp.Streamer.ResizeTo(sp.ctx, size)
 			}
-		}()
+		default:
+		}
 	}
 
 	// start streaming
-	if err := sp.execAndStream(true); err != nil {
+	if err := sp.execAndStream(isPty); err != nil {
 		return nil, err
 	}
 
+	if isPty && resizeChan != nil {
+		// the remote process only exists once attached, so send the initial size now
+		if initial != nil {
+			sp.Streamer.ResizeTo(sp.ctx, *initial)
+		}
+
+		// keep resizing the terminal, but don't flood the streamer with requests
+		go func() {
+			for size := range term.Debounce(resizeChan, streamResizeDebounce) {
+				sp.ptyTerm.ResizeTo(size)
+				sp.Streamer.ResizeTo(sp.ctx, size)
+			}
+		}()
+	}
+
 	// and return
 	return sp.ptyTerm, nil
 }
@@ -234,7 +256,9 @@ func (sp *StreamingProcess) waitStreams() error {
 func (sp *StreamingProcess) Wait() (code int, err error) {
 
 	// wait for the streams to close
-	if err := sp.waitStreams(); err != nil {
+	err = sp.waitStreams()
+	sp.closeOutput()
+	if err != nil {
 		return 0, err
 	}
 
@@ -242,6 +266,19 @@ func (sp *StreamingProcess) Wait() (code int, err error) {
 	return sp.Streamer.Result(sp.ctx)
 }
 
+// closeOutput closes the write ends of standard output and standard error, so that readers receive EOF.
+// On a pty, output is closed along with the pty instead.
+func (sp *StreamingProcess) closeOutput() {
+	if sp.ptyTerm != nil {
+		return
+	}
+	for _, t := range []term.Terminal{sp.stdoutTerm, sp.stderrTerm} {
+		if rwc := t.ReadWriteCloser(); rwc != nil {
+			rwc.Close()
+		}
+	}
+}
+
 // Stop stops the streaming process
 func (sp *StreamingProcess) Stop() (err error) {
 	sp.detachOnce.Do(func() {
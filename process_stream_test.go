@@ -0,0 +1,124 @@
+package procutil
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/tkw1536/procutil/term"
+)
+
+// testStreamer is a Streamer that writes Output to standard output
+type testStreamer struct {
+	Output string
+	isPty  chan bool // when not nil, receives the isPty argument of Attach()
+}
+
+func (ts *testStreamer) String() string { return "test" }
+
+func (ts *testStreamer) Init(ctx context.Context, Term string, isPty bool) error { return nil }
+
+func (ts *testStreamer) StreamOutput(ctx context.Context, stdout, stderr io.Writer, restoreTerms func(), errChan chan error) {
+	_, err := io.WriteString(stdout, ts.Output)
+	errChan <- err
+}
+
+func (ts *testStreamer) StreamInput(ctx context.Context, stdin io.Reader, restoreTerms func(), doneChan chan struct{}) {
+}
+
+func (ts *testStreamer) Attach(ctx context.Context, isPty bool) error {
+	if ts.isPty != nil {
+		ts.isPty <- isPty
+	}
+	return nil
+}
+
+func (ts *testStreamer) ResizeTo(ctx context.Context, size term.WindowSize) error { return nil }
+func (ts *testStreamer) Result(ctx context.Context) (int, error)                  { return 0, nil }
+func (ts *testStreamer) Detach(ctx context.Context) error                         { return nil }
+
+// Test that standard output is closed once the process has exited.
+func TestStreamingProcess_output(t *testing.T) {
+	process := &StreamingProcess{Streamer: &testStreamer{Output: "hello"}}
+	if err := process.Init(context.Background(), false); err != nil {
+		t.Fatalf("Init() returned %s", err)
+	}
+	defer process.Cleanup()
+
+	stdout, _ := process.Stdout()
+	if _, err := process.Start("", nil, false); err != nil {
+		t.Fatalf("Start() returned %s", err)
+	}
+	if code, err := process.Wait(); code != 0 || err != nil {
+		t.Errorf("Wait() = (%d, %v), want (0, nil)", code, err)
+	}
+
+	read := make(chan string)
+	go func() {
+		data, _ := ioutil.ReadAll(stdout)
+		read <- string(data)
+	}()
+	select {
+	case got := <-read:
+		if got != "hello" {
+			t.Errorf("read %q from standard output, want \"hello\"", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("standard output was not closed once the process exited")
+	}
+}
+
+// Test that a process can be started on a pty, where there is no separate standard error.
+func TestStreamingProcess_pty(t *testing.T) {
+	if !term.PTYSupport {
+		t.Skip("OS not supported")
+	}
+
+	process := &StreamingProcess{Streamer: &testStreamer{Output: "hello"}}
+	if err := process.Init(context.Background(), true); err != nil {
+		t.Fatalf("Init() returned %s", err)
+	}
+	defer process.Cleanup()
+
+	if _, err := process.Start("dumb", nil, true); err != nil {
+		t.Fatalf("Start() returned %s", err)
+	}
+	if code, err := process.Wait(); code != 0 || err != nil {
+		t.Errorf("Wait() = (%d, %v), want (0, nil)", code, err)
+	}
+}
+
+// Test that the streamer is only attached as a tty when the process runs on a pty.
+func TestStreamingProcess_attach(t *testing.T) {
+	tests := []struct {
+		name  string
+		isPty bool
+	}{
+		{"plain", false},
+		{"pty", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.isPty && !term.PTYSupport {
+				t.Skip("OS not supported")
+			}
+
+			streamer := &testStreamer{isPty: make(chan bool, 1)}
+			process := &StreamingProcess{Streamer: streamer}
+			if err := process.Init(context.Background(), tt.isPty); err != nil {
+				t.Fatalf("Init() returned %s", err)
+			}
+			defer process.Cleanup()
+
+			if _, err := process.Start("dumb", nil, tt.isPty); err != nil {
+				t.Fatalf("Start() returned %s", err)
+			}
+			if isPty := <-streamer.isPty; isPty != tt.isPty {
+				t.Errorf("Attach() called with isPty = %t, want %t", isPty, tt.isPty)
+			}
+			process.Wait()
+		})
+	}
+}
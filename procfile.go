@@ -0,0 +1,68 @@
+package procutil
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ProcfileEntry is a single named command of a Procfile
+type ProcfileEntry struct {
+	Name    string // name of the process, e.g. "web"
+	Command string // command line, interpreted by "/bin/sh -c"
+}
+
+// Process returns a new process running the command line of this entry using "/bin/sh -c".
+// The process is started in a new process group, so that stopping it also stops any processes started by the command line.
+func (pe ProcfileEntry) Process() *ExecProcess {
+	return &ExecProcess{
+		Command:      "/bin/sh",
+		Args:         []string{"-c", pe.Command},
+		ProcessGroup: true,
+	}
+}
+
+// Errors returned by ParseProcfile
+var (
+	ErrProcfileSyntax    = errors.New("Procfile: Invalid line")
+	ErrProcfileDuplicate = errors.New("Procfile: Duplicate process name")
+)
+
+// procfileLine matches a single non-empty line of a Procfile
+var procfileLine = regexp.MustCompile(`^([A-Za-z0-9_-]+):\s*(.+)$`)
+
+// ParseProcfile parses a Procfile from reader.
+//
+// Every line of a Procfile has the form 'name: command', where name consists of letters, digits, '-' and '_'.
+// Empty lines, and lines starting with '#', are ignored.
+// Entries are returned in the order they appear in.
+func ParseProcfile(reader io.Reader) ([]ProcfileEntry, error) {
+	var entries []ProcfileEntry
+	names := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(reader)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		match := procfileLine.FindStringSubmatch(line)
+		if match == nil {
+			return nil, errors.Wrapf(ErrProcfileSyntax, "Line %d: %q", number, line)
+		}
+		if _, ok := names[match[1]]; ok {
+			return nil, errors.Wrapf(ErrProcfileDuplicate, "Line %d: %q", number, match[1])
+		}
+		names[match[1]] = struct{}{}
+
+		entries = append(entries, ProcfileEntry{Name: match[1], Command: strings.TrimSpace(match[2])})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
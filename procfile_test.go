@@ -0,0 +1,47 @@
+package procutil
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseProcfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []ProcfileEntry
+		wantErr error
+	}{
+		{"empty", "", nil, nil},
+		{"single entry", "web: ./server --port 8080\n", []ProcfileEntry{{"web", "./server --port 8080"}}, nil},
+		{"comments and blank lines", "# processes\n\nweb: ./server\n  \nworker_1:   ./worker  \n", []ProcfileEntry{{"web", "./server"}, {"worker_1", "./worker"}}, nil},
+		{"no final newline", "web: ./server", []ProcfileEntry{{"web", "./server"}}, nil},
+		{"command with colon", "web: echo a:b", []ProcfileEntry{{"web", "echo a:b"}}, nil},
+		{"missing command", "web:\n", nil, ErrProcfileSyntax},
+		{"invalid name", "web server: ./server\n", nil, ErrProcfileSyntax},
+		{"duplicate name", "web: a\nweb: b\n", nil, ErrProcfileDuplicate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProcfile(strings.NewReader(tt.input))
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("ParseProcfile() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseProcfile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcfileEntry_Process(t *testing.T) {
+	process := ProcfileEntry{Name: "web", Command: "echo hello"}.Process()
+	if process.Command != "/bin/sh" || !reflect.DeepEqual(process.Args, []string{"-c", "echo hello"}) {
+		t.Errorf("Process() = %v %v, want /bin/sh [-c echo hello]", process.Command, process.Args)
+	}
+	if !process.ProcessGroup {
+		t.Error("Process() does not start a new process group")
+	}
+}
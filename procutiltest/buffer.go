@@ -0,0 +1,28 @@
+package procutiltest
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Buffer is a bytes.Buffer that is safe for concurrent use.
+// It can be used to capture the output of a process, which is typically written from a different goroutine than it is read from.
+type Buffer struct {
+	m      sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	return b.buffer.Write(p)
+}
+
+// String returns the contents written to the buffer so far
+func (b *Buffer) String() string {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	return b.buffer.String()
+}
@@ -0,0 +1,227 @@
+package procutiltest
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tkw1536/procutil"
+	"github.com/tkw1536/procutil/term"
+)
+
+// Conformance describes the behaviour of a Process or Streamer to be checked by TestProcess() or TestStreamer().
+//
+// The process under test must read its input until the end and then exit with code 0.
+// The end of input is signaled by closing standard input, or by sending an EOF character (Ctrl-D) on a pty.
+// It must not exit before the end of input, unless it is stopped or its context is cancelled.
+// A typical example is the 'cat' command.
+type Conformance struct {
+	// Echo indicates that the process copies its input to its output.
+	Echo bool
+
+	// Pty indicates that the process supports running on a pty.
+	Pty bool
+
+	// Timeout is the maximal time to wait for the process to react.
+	// When zero, DefaultConformanceTimeout is used.
+	Timeout time.Duration
+}
+
+// DefaultConformanceTimeout is the default value for Conformance.Timeout
+const DefaultConformanceTimeout = 5 * time.Second
+
+// conformanceInput is the input sent to the process under test
+const conformanceInput = "hello world"
+
+// TestProcess checks that processes returned by newProcess conform to the contract of the procutil.Process interface.
+// newProcess is called once for every check, and must return a new process every time.
+//
+// The process is run using a procutil.Command, as described by c.
+// Any violation of the contract is reported as an error on t.
+func TestProcess(t *testing.T, newProcess func() procutil.Process, c Conformance) {
+	if c.Timeout == 0 {
+		c.Timeout = DefaultConformanceTimeout
+	}
+
+	t.Run("String", func(t *testing.T) {
+		// String() must not panic, even before Init()
+		process := newProcess()
+		_ = process.String()
+		process.Init(context.Background(), false)
+		_ = process.String()
+	})
+
+	t.Run("Lifecycle", func(t *testing.T) {
+		command := &procutil.Command{Process: newProcess()}
+		if err := command.Init(context.Background(), false); err != nil {
+			t.Fatalf("Init() returned %s", err)
+		}
+
+		var stdout, stderr Buffer
+		if err := command.Start(&stdout, &stderr, strings.NewReader(conformanceInput)); err != nil {
+			t.Fatalf("Start() returned %s", err)
+		}
+
+		if code, err, ok := waitTimeout(command, c.Timeout); !ok {
+			t.Fatalf("Wait() did not return within %s after the end of input", c.Timeout)
+		} else if code != 0 || err != nil {
+			t.Errorf("Wait() = (%d, %v), want (0, nil)", code, err)
+		}
+
+		if err := command.Cleanup(); err != nil {
+			t.Errorf("Cleanup() returned %s", err)
+		}
+
+		if c.Echo {
+			waitFor(c.Timeout, func() bool { return strings.Contains(stdout.String(), conformanceInput) })
+			if got := stdout.String(); !strings.Contains(got, conformanceInput) {
+				t.Errorf("process wrote %q to standard output, want to contain input %q", got, conformanceInput)
+			}
+		}
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		command := &procutil.Command{Process: newProcess()}
+		if err := command.Init(context.Background(), false); err != nil {
+			t.Fatalf("Init() returned %s", err)
+		}
+
+		// input that never ends
+		in, inW := io.Pipe()
+		defer inW.Close()
+
+		if err := command.Start(ioutil.Discard, ioutil.Discard, in); err != nil {
+			t.Fatalf("Start() returned %s", err)
+		}
+
+		if _, _, ok := waitTimeout(command, 100*time.Millisecond); ok {
+			t.Fatal("Wait() returned before the end of input")
+		}
+
+		if err := command.Stop(); err != nil {
+			t.Errorf("Stop() returned %s", err)
+		}
+		if _, _, ok := waitTimeout(command, c.Timeout); !ok {
+			t.Fatalf("Wait() did not return within %s after Stop()", c.Timeout)
+		}
+		command.Cleanup()
+	})
+
+	t.Run("Context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		command := &procutil.Command{Process: newProcess()}
+		if err := command.Init(ctx, false); err != nil {
+			t.Fatalf("Init() returned %s", err)
+		}
+
+		// input that never ends
+		in, inW := io.Pipe()
+		defer inW.Close()
+
+		if err := command.Start(ioutil.Discard, ioutil.Discard, in); err != nil {
+			t.Fatalf("Start() returned %s", err)
+		}
+
+		cancel()
+		if _, _, ok := waitTimeout(command, c.Timeout); !ok {
+			t.Fatalf("Wait() did not return within %s after cancelling the context", c.Timeout)
+		}
+		command.Cleanup()
+	})
+
+	t.Run("Pty", func(t *testing.T) {
+		if !c.Pty {
+			t.Skip("pty not supported")
+		}
+
+		command := &procutil.Command{Process: newProcess()}
+		if err := command.Init(context.Background(), true); err != nil {
+			t.Fatalf("Init() returned %s", err)
+		}
+
+		ft := term.NewFakeTerminal(term.WindowSize{Height: 24, Width: 80})
+		if err := command.StartPty(ft.ReadWriteCloser(), "xterm", ft.ResizeChan()); err != nil {
+			t.Fatalf("StartPty() returned %s", err)
+		}
+
+		// read output in the background, as the pty might block otherwise
+		output := make(chan string, 1)
+		go func() {
+			data, _ := ioutil.ReadAll(ft.Output())
+			output <- string(data)
+		}()
+
+		ft.Resize(term.WindowSize{Height: 30, Width: 100})
+		io.WriteString(ft.Input(), conformanceInput+"\r\x04")
+
+		if code, err, ok := waitTimeout(command, c.Timeout); !ok {
+			t.Fatalf("Wait() did not return within %s after the end of input", c.Timeout)
+		} else if code != 0 || err != nil {
+			t.Errorf("Wait() = (%d, %v), want (0, nil)", code, err)
+		}
+
+		ft.Input().Close()
+		if err := command.Cleanup(); err != nil {
+			t.Errorf("Cleanup() returned %s", err)
+		}
+
+		if !c.Echo {
+			return
+		}
+		select {
+		case got := <-output:
+			if !strings.Contains(got, conformanceInput) {
+				t.Errorf("process wrote %q to the pty, want to contain input %q", got, conformanceInput)
+			}
+		case <-time.After(c.Timeout):
+			t.Errorf("pty was not closed within %s after the process exited", c.Timeout)
+		}
+	})
+}
+
+// TestStreamer checks that streamers returned by newStreamer conform to the contract of the procutil.Streamer interface.
+// newStreamer is called once for every check, and must return a new streamer every time.
+//
+// The streamer is run as part of a procutil.StreamingProcess, and is checked using TestProcess.
+// When the operating system does not support ptys, c.Pty is ignored.
+func TestStreamer(t *testing.T, newStreamer func() procutil.Streamer, c Conformance) {
+	c.Pty = c.Pty && term.PTYSupport
+	TestProcess(t, func() procutil.Process {
+		return &procutil.StreamingProcess{Streamer: newStreamer()}
+	}, c)
+}
+
+// waitTimeout waits for command to exit for at most d.
+// ok indicates if the command exited in time.
+func waitTimeout(command *procutil.Command, d time.Duration) (code int, err error, ok bool) {
+	type result struct {
+		code int
+		err  error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		code, err := command.Wait()
+		done <- result{code, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.code, res.err, true
+	case <-time.After(d):
+		return 0, nil, false
+	}
+}
+
+// waitFor waits up to d for condition to become true.
+func waitFor(d time.Duration, condition func() bool) {
+	deadline := time.Now().Add(d)
+	for !condition() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
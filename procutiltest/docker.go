@@ -0,0 +1,480 @@
+package procutiltest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/tkw1536/procutil"
+	"github.com/tkw1536/procutil/term"
+)
+
+// DockerServer is an in-process fake of the Docker Engine API, intended to test code using docker exec without a docker daemon.
+//
+// It implements the endpoints to create, start, resize and inspect exec instances.
+// Every exec instance is backed by a procutil.Process returned from NewProcess, typically a fake Process.
+// Starting an exec hijacks the connection like the real daemon does.
+// Without a tty, output is multiplexed using the framing of the stdcopy package; with a tty, the raw stream of the pty is sent.
+//
+// Any API version prefix of the form "/vX.Y" is accepted.
+// Other endpoints are not implemented, and return a "not found" error.
+type DockerServer struct {
+	// NewProcess returns the process to run for a new exec instance.
+	// When it returns an error, creating the exec instance fails with that error.
+	NewProcess func(containerID string, config types.ExecConfig) (procutil.Process, error)
+
+	server *httptest.Server
+
+	ctx    context.Context // context of all processes, cancelled by Close()
+	cancel context.CancelFunc
+	wg     sync.WaitGroup // running processes
+
+	m      sync.Mutex
+	execs  map[string]*dockerExec
+	order  []string // ids of execs, in order of creation
+	nextID int
+}
+
+// DockerExec is a snapshot of an exec instance of a DockerServer.
+type DockerExec struct {
+	ID          string
+	ContainerID string
+	Config      types.ExecConfig
+	Process     procutil.Process
+
+	Started  bool // if the exec instance has been started
+	Running  bool // if the process is still running
+	Tty      bool // if the exec instance was started with a tty
+	ExitCode int  // exit code of the process, once it is no longer running
+
+	Resizes []term.WindowSize // sizes passed to the resize endpoint
+}
+
+// dockerExec is an exec instance of a DockerServer.
+// All fields are protected by the mutex of the server.
+type dockerExec struct {
+	DockerExec
+	resizes chan term.WindowSize // resizes to pass to the process
+	started bool                 // if the process has returned from Start(), and may be stopped
+}
+
+// NewDockerServer creates and starts a new DockerServer using newProcess.
+// It must be closed by the caller using Close().
+func NewDockerServer(newProcess func(containerID string, config types.ExecConfig) (procutil.Process, error)) *DockerServer {
+	ds := &DockerServer{
+		NewProcess: newProcess,
+		execs:      make(map[string]*dockerExec),
+	}
+	ds.ctx, ds.cancel = context.WithCancel(context.Background())
+	ds.server = httptest.NewServer(http.HandlerFunc(ds.serveHTTP))
+	return ds
+}
+
+// Host returns the docker host of this server, in a format suitable for client.WithHost() or the DOCKER_HOST variable.
+func (ds *DockerServer) Host() string {
+	return "tcp://" + ds.server.Listener.Addr().String()
+}
+
+// Client returns a new docker client connected to this server.
+// It should be closed by the caller.
+func (ds *DockerServer) Client() (*client.Client, error) {
+	return client.NewClientWithOpts(client.WithHost(ds.Host()), client.WithVersion(api.DefaultVersion))
+}
+
+// Execs returns a snapshot of all exec instances created so far, in order of creation.
+func (ds *DockerServer) Execs() []DockerExec {
+	ds.m.Lock()
+	defer ds.m.Unlock()
+
+	execs := make([]DockerExec, len(ds.order))
+	for i, id := range ds.order {
+		execs[i] = ds.execs[id].DockerExec
+		execs[i].Resizes = append([]term.WindowSize(nil), execs[i].Resizes...)
+	}
+	return execs
+}
+
+// Close stops all running processes and shuts down the server.
+// It waits for all processes to exit.
+//
+// Processes that are still starting are stopped once they have started, see started().
+func (ds *DockerServer) Close() {
+	ds.cancel()
+
+	ds.m.Lock()
+	for _, exec := range ds.execs {
+		if exec.Running && exec.started {
+			exec.Process.Stop()
+		}
+	}
+	ds.m.Unlock()
+
+	ds.server.Close()
+	ds.wg.Wait()
+}
+
+var (
+	dockerVersionPrefix = regexp.MustCompile(`^/v[0-9.]+/`)
+	dockerExecCreate    = regexp.MustCompile(`^/containers/([^/]+)/exec$`)
+	dockerExecStart     = regexp.MustCompile(`^/exec/([^/]+)/start$`)
+	dockerExecResize    = regexp.MustCompile(`^/exec/([^/]+)/resize$`)
+	dockerExecInspect   = regexp.MustCompile(`^/exec/([^/]+)/json$`)
+)
+
+var (
+	errDockerNotFound     = errors.New("page not found")
+	errDockerNoSuchExec   = errors.New("No such exec instance")
+	errDockerExecStarted  = errors.New("Exec instance has already been started")
+	errDockerExecNotAlive = errors.New("Exec instance is not running")
+	errDockerNoHijack     = errors.New("Connection can not be hijacked")
+)
+
+func (ds *DockerServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Api-Version", api.DefaultVersion)
+
+	path := dockerVersionPrefix.ReplaceAllString(r.URL.Path, "/")
+	if path == "/_ping" && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, "OK")
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if m := dockerExecCreate.FindStringSubmatch(path); m != nil {
+			ds.execCreate(w, r, m[1])
+			return
+		}
+		if m := dockerExecStart.FindStringSubmatch(path); m != nil {
+			ds.execStart(w, r, m[1])
+			return
+		}
+		if m := dockerExecResize.FindStringSubmatch(path); m != nil {
+			ds.execResize(w, r, m[1])
+			return
+		}
+	}
+	if r.Method == http.MethodGet {
+		if m := dockerExecInspect.FindStringSubmatch(path); m != nil {
+			ds.execInspect(w, r, m[1])
+			return
+		}
+	}
+
+	writeDockerError(w, http.StatusNotFound, errDockerNotFound)
+}
+
+// execCreate implements POST /containers/{id}/exec
+func (ds *DockerServer) execCreate(w http.ResponseWriter, r *http.Request, containerID string) {
+	var config types.ExecConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		writeDockerError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	process, err := ds.NewProcess(containerID, config)
+	if err != nil {
+		writeDockerError(w, http.StatusNotFound, err)
+		return
+	}
+
+	ds.m.Lock()
+	ds.nextID++
+	id := fmt.Sprintf("exec%d", ds.nextID)
+	ds.execs[id] = &dockerExec{
+		DockerExec: DockerExec{
+			ID:          id,
+			ContainerID: containerID,
+			Config:      config,
+			Process:     process,
+		},
+	}
+	ds.order = append(ds.order, id)
+	ds.m.Unlock()
+
+	writeDockerJSON(w, http.StatusCreated, types.IDResponse{ID: id})
+}
+
+// execStart implements POST /exec/{id}/start
+func (ds *DockerServer) execStart(w http.ResponseWriter, r *http.Request, id string) {
+	var check types.ExecStartCheck
+	if err := json.NewDecoder(r.Body).Decode(&check); err != nil {
+		writeDockerError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ds.m.Lock()
+	exec, ok := ds.execs[id]
+	switch {
+	case !ok:
+		ds.m.Unlock()
+		writeDockerError(w, http.StatusNotFound, errDockerNoSuchExec)
+		return
+	case exec.Started:
+		ds.m.Unlock()
+		writeDockerError(w, http.StatusConflict, errDockerExecStarted)
+		return
+	}
+	exec.Started, exec.Running, exec.Tty = true, true, check.Tty || exec.Config.Tty
+	exec.resizes = make(chan term.WindowSize, 1)
+	config, tty := exec.Config, exec.Tty
+	ds.wg.Add(1)
+	ds.m.Unlock()
+
+	if check.Detach {
+		w.WriteHeader(http.StatusOK)
+		go ds.run(exec, config, tty, nil)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		ds.exited(exec, -1)
+		ds.wg.Done()
+		writeDockerError(w, http.StatusInternalServerError, errDockerNoHijack)
+		return
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		ds.exited(exec, -1)
+		ds.wg.Done()
+		writeDockerError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	contentType := "application/vnd.docker.multiplexed-stream"
+	if tty {
+		contentType = "application/vnd.docker.raw-stream"
+	}
+
+	// like the real daemon, upgrade the connection if the client asked for it
+	if r.Header.Get("Upgrade") != "" {
+		fmt.Fprintf(conn, "HTTP/1.1 101 UPGRADED\r\nContent-Type: %s\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n", contentType)
+	} else {
+		fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Type: %s\r\n\r\n", contentType)
+	}
+
+	go ds.run(exec, config, tty, &dockerConn{Conn: conn, Reader: buf.Reader})
+}
+
+// dockerConn is a hijacked connection.
+// Reads are buffered, as the http server might already have read part of the stream.
+type dockerConn struct {
+	net.Conn
+	io.Reader
+}
+
+func (dc *dockerConn) Read(p []byte) (int, error) {
+	return dc.Reader.Read(p)
+}
+
+// run runs the process of exec, and streams it over conn.
+// When conn is nil, output is discarded and the process receives no input.
+func (ds *DockerServer) run(exec *dockerExec, config types.ExecConfig, tty bool, conn *dockerConn) {
+	defer ds.wg.Done()
+
+	code, err := ds.runProcess(exec, config, tty, conn)
+	if err != nil {
+		code = -1
+		if conn != nil && !tty {
+			fmt.Fprintf(stdcopy.NewStdWriter(conn, stdcopy.Stderr), "%s\n", err)
+		}
+	}
+
+	// record the exit code before closing the connection.
+	// Clients inspect the exec instance as soon as the stream ends.
+	ds.exited(exec, code)
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+func (ds *DockerServer) runProcess(exec *dockerExec, config types.ExecConfig, tty bool, conn *dockerConn) (int, error) {
+	process := exec.Process
+	defer process.Cleanup()
+
+	if err := process.Init(ds.ctx, tty); err != nil {
+		return 0, err
+	}
+
+	var in io.Reader = strings.NewReader("")
+	var out, errOut io.Writer = ioutil.Discard, ioutil.Discard
+	if conn != nil {
+		if config.AttachStdin {
+			in = conn
+		}
+		if config.AttachStdout {
+			out = conn
+		}
+		if config.AttachStderr {
+			errOut = conn
+		}
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	if tty {
+		t, err := process.Start(dockerTerm(config.Env), exec.resizes, true)
+		if err != nil {
+			return 0, err
+		}
+		ds.started(exec)
+		rwc := t.ReadWriteCloser()
+
+		// input is not waited for, as the process may exit without reading all of it
+		go io.Copy(rwc, in)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			io.Copy(out, rwc)
+		}()
+
+		return process.Wait()
+	}
+
+	stdin, err := process.Stdin()
+	if err != nil {
+		return 0, err
+	}
+	stdout, err := process.Stdout()
+	if err != nil {
+		return 0, err
+	}
+	stderr, err := process.Stderr()
+	if err != nil {
+		return 0, err
+	}
+
+	go func() {
+		defer stdin.Close()
+		io.Copy(stdin, in)
+	}()
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer stdout.Close()
+		io.Copy(stdcopy.NewStdWriter(out, stdcopy.Stdout), stdout)
+	}()
+	go func() {
+		defer wg.Done()
+		defer stderr.Close()
+		io.Copy(stdcopy.NewStdWriter(errOut, stdcopy.Stderr), stderr)
+	}()
+
+	if _, err := process.Start("", nil, false); err != nil {
+		return 0, err
+	}
+	ds.started(exec)
+	return process.Wait()
+}
+
+// started marks the process of exec as started.
+// When the server has been closed in the meantime, the process is stopped, as Close() skipped it.
+func (ds *DockerServer) started(exec *dockerExec) {
+	ds.m.Lock()
+	defer ds.m.Unlock()
+
+	exec.started = true
+	if ds.ctx.Err() != nil {
+		exec.Process.Stop()
+	}
+}
+
+// exited marks exec as exited with the given code
+func (ds *DockerServer) exited(exec *dockerExec, code int) {
+	ds.m.Lock()
+	defer ds.m.Unlock()
+
+	exec.Running, exec.ExitCode = false, code
+	close(exec.resizes)
+}
+
+// dockerTerm returns the value of the TERM variable in env
+func dockerTerm(env []string) (Term string) {
+	for _, v := range env {
+		if strings.HasPrefix(v, "TERM=") {
+			Term = strings.TrimPrefix(v, "TERM=")
+		}
+	}
+	return
+}
+
+// execResize implements POST /exec/{id}/resize
+func (ds *DockerServer) execResize(w http.ResponseWriter, r *http.Request, id string) {
+	height, herr := strconv.ParseUint(r.URL.Query().Get("h"), 10, 16)
+	width, werr := strconv.ParseUint(r.URL.Query().Get("w"), 10, 16)
+	if herr != nil || werr != nil {
+		writeDockerError(w, http.StatusBadRequest, errors.New("Invalid height or width"))
+		return
+	}
+	size := term.WindowSize{Height: term.Size(height), Width: term.Size(width)}
+
+	ds.m.Lock()
+	defer ds.m.Unlock()
+
+	exec, ok := ds.execs[id]
+	switch {
+	case !ok:
+		writeDockerError(w, http.StatusNotFound, errDockerNoSuchExec)
+		return
+	case !exec.Running:
+		writeDockerError(w, http.StatusConflict, errDockerExecNotAlive)
+		return
+	}
+
+	exec.Resizes = append(exec.Resizes, size)
+	if exec.Tty {
+		term.SendLatest(exec.resizes, size)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// execInspect implements GET /exec/{id}/json
+func (ds *DockerServer) execInspect(w http.ResponseWriter, r *http.Request, id string) {
+	ds.m.Lock()
+	exec, ok := ds.execs[id]
+	var res types.ContainerExecInspect
+	if ok {
+		res = types.ContainerExecInspect{
+			ExecID:      exec.ID,
+			ContainerID: exec.ContainerID,
+			Running:     exec.Running,
+			ExitCode:    exec.ExitCode,
+		}
+	}
+	ds.m.Unlock()
+
+	if !ok {
+		writeDockerError(w, http.StatusNotFound, errDockerNoSuchExec)
+		return
+	}
+	writeDockerJSON(w, http.StatusOK, res)
+}
+
+// writeDockerJSON writes value as a json response
+func writeDockerJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeDockerError writes err as an error response, in the format used by the docker daemon
+func writeDockerError(w http.ResponseWriter, status int, err error) {
+	writeDockerJSON(w, status, types.ErrorResponse{Message: err.Error()})
+}
@@ -0,0 +1,118 @@
+package procutiltest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/tkw1536/procutil"
+	"github.com/tkw1536/procutil/term"
+)
+
+func TestDockerServer_errors(t *testing.T) {
+	server := NewDockerServer(func(containerID string, config types.ExecConfig) (procutil.Process, error) {
+		if containerID != "container" {
+			return nil, errors.New("No such container")
+		}
+		return &Process{}, nil
+	})
+	defer server.Close()
+
+	cli, err := server.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+
+	if _, err := cli.ContainerExecCreate(ctx, "missing", types.ExecConfig{}); !client.IsErrNotFound(err) {
+		t.Errorf("ContainerExecCreate() of missing container returned %v, want not found error", err)
+	}
+	if _, err := cli.ContainerExecInspect(ctx, "missing"); !client.IsErrNotFound(err) {
+		t.Errorf("ContainerExecInspect() of missing exec returned %v, want not found error", err)
+	}
+
+	res, err := cli.ContainerExecCreate(ctx, "container", types.ExecConfig{})
+	if err != nil {
+		t.Fatalf("ContainerExecCreate() returned %s", err)
+	}
+	if err := cli.ContainerExecResize(ctx, res.ID, types.ResizeOptions{Height: 24, Width: 80}); err == nil {
+		t.Error("ContainerExecResize() of exec that is not running did not return an error")
+	}
+	if err := cli.ContainerExecStart(ctx, res.ID, types.ExecStartCheck{Detach: true}); err != nil {
+		t.Fatalf("ContainerExecStart() returned %s", err)
+	}
+	if err := cli.ContainerExecStart(ctx, res.ID, types.ExecStartCheck{Detach: true}); err == nil {
+		t.Error("ContainerExecStart() of exec that was already started did not return an error")
+	}
+
+	waitFor(DefaultConformanceTimeout, func() bool { return !server.Execs()[0].Running })
+	if inspect, err := cli.ContainerExecInspect(ctx, res.ID); err != nil || inspect.Running || inspect.ExitCode != 0 {
+		t.Errorf("ContainerExecInspect() = (%+v, %v), want exited exec", inspect, err)
+	}
+}
+
+// slowStartProcess is a Process that blocks in Start() until unblock is closed
+type slowStartProcess struct {
+	*Process
+	starting chan struct{} // closed once Start() has been called
+	unblock  chan struct{}
+}
+
+func (sp *slowStartProcess) Start(Term string, resizeChan <-chan term.WindowSize, isPty bool) (term.Terminal, error) {
+	close(sp.starting)
+	<-sp.unblock
+	return sp.Process.Start(Term, resizeChan, isPty)
+}
+
+func TestDockerServer_Close_starting(t *testing.T) {
+	process := &slowStartProcess{
+		Process:  &Process{Echo: true},
+		starting: make(chan struct{}),
+		unblock:  make(chan struct{}),
+	}
+	server := NewDockerServer(func(containerID string, config types.ExecConfig) (procutil.Process, error) {
+		return process, nil
+	})
+
+	cli, err := server.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+	res, err := cli.ContainerExecCreate(ctx, "container", types.ExecConfig{})
+	if err != nil {
+		t.Fatalf("ContainerExecCreate() returned %s", err)
+	}
+	if err := cli.ContainerExecStart(ctx, res.ID, types.ExecStartCheck{Detach: true}); err != nil {
+		t.Fatalf("ContainerExecStart() returned %s", err)
+	}
+	<-process.starting
+
+	// close the server while the process is starting
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		server.Close()
+	}()
+	waitFor(DefaultConformanceTimeout, func() bool { return server.ctx.Err() != nil })
+	close(process.unblock)
+
+	select {
+	case <-closed:
+	case <-time.After(DefaultConformanceTimeout):
+		t.Fatal("Close() did not return")
+	}
+
+	calls := strings.Join(process.Calls(), ",")
+	if start, stop := strings.Index(calls, "Start"), strings.Index(calls, "Stop"); start == -1 || stop < start {
+		t.Errorf("Process was called with %s, want Stop() after Start()", calls)
+	}
+}
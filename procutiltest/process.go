@@ -0,0 +1,329 @@
+// Package procutiltest implements utilities for testing code using procutil.
+//
+// It provides fake implementations of procutil.Process and procutil.Streamer with scripted behaviour,
+// along with conformance suites to check third-party implementations of these interfaces.
+// DockerServer fakes the parts of the Docker Engine API needed to run processes using docker exec.
+package procutiltest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tkw1536/procutil"
+	"github.com/tkw1536/procutil/term"
+)
+
+// Output is a single piece of scripted output of a fake Process or Streamer.
+type Output struct {
+	Delay  time.Duration // delay before writing Data
+	Stderr bool          // write Data to standard error instead of standard output (ignored on a pty)
+	Data   string
+}
+
+// eof is the character that ends input on a pty
+const eof = 0x04
+
+// Process is a fake procutil.Process with scripted behaviour.
+//
+// When started, it writes Output and then exits with ExitCode.
+// Input to the process is recorded, and can be retrieved using Input().
+// When Echo is true, the process also copies its input to its output, and only exits at the end of input.
+// On a pty, input ends with an EOF character (Ctrl-D); otherwise it ends once standard input is closed.
+//
+// Any of the Err fields can be set to make the corresponding method fail with that error.
+// When the process is stopped or its context is cancelled before it is done, it exits with code -1.
+//
+// A Process may not be reused.
+type Process struct {
+	Name     string   // returned by String()
+	Output   []Output // output to write
+	Echo     bool     // copy input to output
+	ExitCode int      // code to exit with
+
+	InitErr, StdoutErr, StderrErr, StdinErr, StartErr, StopErr, WaitErr, CleanupErr error
+
+	m       sync.Mutex
+	calls   []string
+	input   bytes.Buffer
+	term    string
+	resizes []term.WindowSize
+
+	ctx   context.Context
+	isPty bool
+
+	stdout, stderr   *io.PipeReader
+	stdoutW, stderrW *io.PipeWriter
+	stdinR           *io.PipeReader
+	stdin            *io.PipeWriter
+	pty              *term.FakeTerminal
+
+	stopOnce sync.Once
+	stopped  chan struct{} // closed by Stop()
+	done     chan struct{} // closed once the process has exited
+	code     int
+}
+
+// Process implements procutil.Process
+func init() {
+	var _ procutil.Process = (*Process)(nil)
+}
+
+// record records a call to the method name.
+func (p *Process) record(name string) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.calls = append(p.calls, name)
+}
+
+// Calls returns the names of the methods of the process called so far, in order.
+// Calls to String() are not recorded.
+func (p *Process) Calls() []string {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return append([]string(nil), p.calls...)
+}
+
+// Input returns all input received by the process so far.
+func (p *Process) Input() string {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return p.input.String()
+}
+
+// Term returns the value of Term passed to Start().
+func (p *Process) Term() string {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return p.term
+}
+
+// Resizes returns all sizes the pty of the process has been resized to.
+func (p *Process) Resizes() []term.WindowSize {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return append([]term.WindowSize(nil), p.resizes...)
+}
+
+// String returns Name
+func (p *Process) String() string {
+	return p.Name
+}
+
+// Init initializes this process
+func (p *Process) Init(ctx context.Context, isPty bool) error {
+	p.record("Init")
+	if p.InitErr != nil {
+		return p.InitErr
+	}
+
+	p.ctx, p.isPty = ctx, isPty
+	p.stopped = make(chan struct{})
+	p.done = make(chan struct{})
+
+	p.stdout, p.stdoutW = io.Pipe()
+	p.stderr, p.stderrW = io.Pipe()
+	p.stdinR, p.stdin = io.Pipe()
+	return nil
+}
+
+// Stdout returns a pipe to Stdout
+func (p *Process) Stdout() (io.ReadCloser, error) {
+	p.record("Stdout")
+	if p.StdoutErr != nil {
+		return nil, p.StdoutErr
+	}
+	return p.stdout, nil
+}
+
+// Stderr returns a pipe to Stderr
+func (p *Process) Stderr() (io.ReadCloser, error) {
+	p.record("Stderr")
+	if p.StderrErr != nil {
+		return nil, p.StderrErr
+	}
+	return p.stderr, nil
+}
+
+// Stdin returns a pipe to Stdin
+func (p *Process) Stdin() (io.WriteCloser, error) {
+	p.record("Stdin")
+	if p.StdinErr != nil {
+		return nil, p.StdinErr
+	}
+	return p.stdin, nil
+}
+
+// Start starts this process.
+// On a pty, it returns a term.FakeTerminal.
+func (p *Process) Start(Term string, resizeChan <-chan term.WindowSize, isPty bool) (term.Terminal, error) {
+	p.record("Start")
+	if p.StartErr != nil {
+		return nil, p.StartErr
+	}
+
+	p.m.Lock()
+	p.term = Term
+	p.m.Unlock()
+
+	if !isPty {
+		go p.run(p.stdoutW, p.stderrW, p.stdinR, func() {
+			p.stdoutW.Close()
+			p.stderrW.Close()
+		})
+		return nil, nil
+	}
+
+	// The fake terminal represents the pty from the outside.
+	// Output of the process is read from it, and input to the process is written to it.
+	p.pty = term.NewFakeTerminal(term.WindowSize{})
+	go func() {
+		for size := range resizeChan {
+			p.pty.ResizeTo(size)
+
+			p.m.Lock()
+			p.resizes = append(p.resizes, size)
+			p.m.Unlock()
+		}
+	}()
+
+	out := p.pty.Input()
+	go p.run(out, out, p.pty.Output(), func() { out.Close() })
+	return p.pty, nil
+}
+
+// run runs the process.
+// It writes output to out and errOut, reads input from in, and calls closeOutput once done.
+func (p *Process) run(out, errOut io.Writer, in io.Reader, closeOutput func()) {
+	defer close(p.done)
+	defer closeOutput()
+
+	// read (and possibly echo) input in the background
+	inputDone := make(chan struct{})
+	go func() {
+		defer close(inputDone)
+		readInput(in, p.isPty, func(chunk []byte) {
+			p.m.Lock()
+			p.input.Write(chunk)
+			p.m.Unlock()
+
+			if p.Echo {
+				out.Write(chunk)
+			}
+		})
+	}()
+
+	p.code = -1
+	for _, output := range p.Output {
+		if !p.sleep(output.Delay) {
+			return
+		}
+
+		w := out
+		if output.Stderr {
+			w = errOut
+		}
+		io.WriteString(w, output.Data)
+	}
+
+	if p.Echo {
+		select {
+		case <-inputDone:
+		case <-p.stopped:
+			return
+		case <-p.ctx.Done():
+			return
+		}
+	}
+
+	p.code = p.ExitCode
+}
+
+// sleep sleeps for d, and returns false if the process was stopped in the meantime.
+func (p *Process) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-p.stopped:
+		return false
+	case <-p.ctx.Done():
+		return false
+	}
+}
+
+var errProcessNotStarted = errors.New("Process: Not started")
+
+// Stop stops this process
+func (p *Process) Stop() error {
+	p.record("Stop")
+	if p.StopErr != nil {
+		return p.StopErr
+	}
+	if p.stopped == nil {
+		return errProcessNotStarted
+	}
+
+	p.stopOnce.Do(func() { close(p.stopped) })
+	return nil
+}
+
+// Wait waits for the process and returns the exit code
+func (p *Process) Wait() (int, error) {
+	p.record("Wait")
+	if p.WaitErr != nil {
+		return 0, p.WaitErr
+	}
+	if p.done == nil {
+		return 0, errProcessNotStarted
+	}
+
+	<-p.done
+	return p.code, nil
+}
+
+// Cleanup cleans up this process
+func (p *Process) Cleanup() error {
+	p.record("Cleanup")
+	if p.stdinR != nil {
+		p.stdinR.Close()
+	}
+	if p.pty != nil {
+		p.pty.Close()
+	}
+	return p.CleanupErr
+}
+
+// readInput reads chunks of input from in and passes them to handle.
+// On a pty, reading stops at an EOF character.
+func readInput(in io.Reader, isPty bool, handle func(chunk []byte)) {
+	buffer := make([]byte, 1024)
+	for {
+		n, err := in.Read(buffer)
+		chunk := buffer[:n]
+
+		ended := err != nil
+		if isPty {
+			if index := bytes.IndexByte(chunk, eof); index >= 0 {
+				chunk, ended = chunk[:index], true
+			}
+		}
+
+		if len(chunk) > 0 {
+			handle(chunk)
+		}
+		if ended {
+			return
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package procutiltest
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tkw1536/procutil"
+)
+
+func TestProcess_conformance(t *testing.T) {
+	TestProcess(t, func() procutil.Process {
+		return &Process{Name: "fake", Echo: true}
+	}, Conformance{Echo: true, Pty: true})
+}
+
+func TestStreamer_conformance(t *testing.T) {
+	TestStreamer(t, func() procutil.Streamer {
+		return &Streamer{Name: "fake", Echo: true}
+	}, Conformance{Echo: true, Pty: true})
+}
+
+func TestProcess_script(t *testing.T) {
+	process := &Process{
+		Output: []Output{
+			{Data: "out"},
+			{Data: "err", Stderr: true, Delay: 10 * time.Millisecond},
+		},
+		ExitCode: 42,
+	}
+
+	command := &procutil.Command{Process: process}
+	command.Init(context.Background(), false)
+
+	var stdout, stderr Buffer
+	if err := command.Start(&stdout, &stderr, strings.NewReader("input")); err != nil {
+		t.Fatalf("Start() returned %s", err)
+	}
+	if code, err := command.Wait(); code != 42 || err != nil {
+		t.Errorf("Wait() = (%d, %v), want (42, nil)", code, err)
+	}
+	command.Cleanup()
+
+	waitFor(time.Second, func() bool { return stdout.String() == "out" && stderr.String() == "err" })
+	if stdout.String() != "out" || stderr.String() != "err" {
+		t.Errorf("process wrote (%q, %q), want (\"out\", \"err\")", stdout.String(), stderr.String())
+	}
+
+	wantCalls := []string{"Init", "Stdin", "Stdout", "Stderr", "Start", "Wait", "Cleanup"}
+	if got := process.Calls(); !reflect.DeepEqual(got, wantCalls) {
+		t.Errorf("Calls() = %v, want %v", got, wantCalls)
+	}
+}
+
+func TestProcess_errors(t *testing.T) {
+	errInjected := errors.New("injected")
+
+	tests := []struct {
+		name    string
+		process *Process
+		wantErr string // name of the method that should fail
+	}{
+		{"Init", &Process{InitErr: errInjected}, "Init"},
+		{"Stdin", &Process{StdinErr: errInjected}, "Start"},
+		{"Stdout", &Process{StdoutErr: errInjected}, "Start"},
+		{"Stderr", &Process{StderrErr: errInjected}, "Start"},
+		{"Start", &Process{StartErr: errInjected}, "Start"},
+		{"Wait", &Process{WaitErr: errInjected}, "Wait"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := &procutil.Command{Process: tt.process}
+
+			err := command.Init(context.Background(), false)
+			if tt.wantErr == "Init" {
+				if !errors.Is(err, errInjected) {
+					t.Errorf("Init() returned %v, want injected error", err)
+				}
+				return
+			}
+
+			err = command.Start(ioutil.Discard, ioutil.Discard, strings.NewReader(""))
+			if tt.wantErr == "Start" {
+				if !errors.Is(err, errInjected) {
+					t.Errorf("Start() returned %v, want injected error", err)
+				}
+				return
+			}
+
+			_, err = command.Wait()
+			if !errors.Is(err, errInjected) {
+				t.Errorf("Wait() returned %v, want injected error", err)
+			}
+		})
+	}
+}
+
+func TestStreamer_errors(t *testing.T) {
+	errInjected := errors.New("injected")
+
+	tests := []struct {
+		name     string
+		streamer *Streamer
+		wantErr  string // name of the method that should fail
+	}{
+		{"Init", &Streamer{InitErr: errInjected}, "Start"},
+		{"Attach", &Streamer{AttachErr: errInjected}, "Start"},
+		{"Output", &Streamer{OutputErr: errInjected}, "Wait"},
+		{"Result", &Streamer{ResultErr: errInjected}, "Wait"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := &procutil.Command{Process: &procutil.StreamingProcess{Streamer: tt.streamer}}
+			if err := command.Init(context.Background(), false); err != nil {
+				t.Fatalf("Init() returned %s", err)
+			}
+
+			err := command.Start(ioutil.Discard, ioutil.Discard, strings.NewReader(""))
+			if tt.wantErr == "Start" {
+				if !errors.Is(err, errInjected) {
+					t.Errorf("Start() returned %v, want injected error", err)
+				}
+				return
+			}
+
+			_, err = command.Wait()
+			if !errors.Is(err, errInjected) {
+				t.Errorf("Wait() returned %v, want injected error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package procutiltest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tkw1536/procutil"
+	"github.com/tkw1536/procutil/term"
+)
+
+// Streamer is a fake procutil.Streamer with scripted behaviour.
+// It is typically used as the Streamer of a procutil.StreamingProcess.
+//
+// Once attached, it streams Output and then reports ExitCode as result.
+// Input is recorded, and can be retrieved using Input().
+// When Echo is true, the streamer also copies input to output, and only finishes streaming output at the end of input.
+// On a pty, input ends with an EOF character (Ctrl-D); otherwise it ends once standard input is closed.
+//
+// Any of the Err fields can be set to make the corresponding method fail with that error.
+// OutputErr is reported once output streaming has finished.
+//
+// A Streamer may not be reused.
+type Streamer struct {
+	Name     string   // returned by String()
+	Output   []Output // output to stream
+	Echo     bool     // copy input to output
+	ExitCode int      // result to return
+
+	InitErr, AttachErr, OutputErr, ResizeErr, ResultErr, DetachErr error
+
+	m       sync.Mutex
+	calls   []string
+	input   bytes.Buffer
+	term    string
+	isPty   bool
+	resizes []term.WindowSize
+
+	echo       chan []byte // input to be echoed
+	detachOnce sync.Once
+	detached   chan struct{} // closed by Detach()
+}
+
+// Streamer implements procutil.Streamer
+func init() {
+	var _ procutil.Streamer = (*Streamer)(nil)
+}
+
+func (s *Streamer) record(name string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.calls = append(s.calls, name)
+}
+
+// Calls returns the names of the methods of the streamer called so far, in order.
+// Calls to String() are not recorded.
+func (s *Streamer) Calls() []string {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return append([]string(nil), s.calls...)
+}
+
+// Input returns all input received by the streamer so far.
+func (s *Streamer) Input() string {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.input.String()
+}
+
+// Term returns the value of Term passed to Init().
+func (s *Streamer) Term() string {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.term
+}
+
+// Resizes returns all sizes passed to ResizeTo().
+func (s *Streamer) Resizes() []term.WindowSize {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return append([]term.WindowSize(nil), s.resizes...)
+}
+
+// String returns Name
+func (s *Streamer) String() string {
+	return s.Name
+}
+
+// Init initializes this streamer
+func (s *Streamer) Init(ctx context.Context, Term string, isPty bool) error {
+	s.record("Init")
+	if s.InitErr != nil {
+		return s.InitErr
+	}
+
+	s.m.Lock()
+	s.term, s.isPty = Term, isPty
+	s.m.Unlock()
+
+	s.echo = make(chan []byte)
+	s.detached = make(chan struct{})
+	return nil
+}
+
+// Attach attaches to this streamer
+func (s *Streamer) Attach(ctx context.Context, isPty bool) error {
+	s.record("Attach")
+	return s.AttachErr
+}
+
+// StreamOutput streams the scripted output, followed by echoed input if Echo is set.
+// When stderr is nil, all output is written to stdout.
+func (s *Streamer) StreamOutput(ctx context.Context, stdout, stderr io.Writer, restoreTerms func(), errChan chan error) {
+	s.record("StreamOutput")
+
+	err := s.streamOutput(ctx, stdout, stderr)
+	if err == nil {
+		err = s.OutputErr
+	}
+	errChan <- err
+}
+
+func (s *Streamer) streamOutput(ctx context.Context, stdout, stderr io.Writer) error {
+	for _, output := range s.Output {
+		if err := s.sleep(ctx, output.Delay); err != nil {
+			return err
+		}
+
+		w := stdout
+		if output.Stderr && stderr != nil {
+			w = stderr
+		}
+		io.WriteString(w, output.Data)
+	}
+
+	if !s.Echo {
+		return nil
+	}
+
+	for {
+		select {
+		case chunk, ok := <-s.echo:
+			if !ok {
+				return nil
+			}
+			stdout.Write(chunk)
+		case <-s.detached:
+			return io.ErrClosedPipe
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// sleep sleeps for d, and returns an error if the streamer is detached or ctx is closed in the meantime.
+func (s *Streamer) sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-s.detached:
+		return io.ErrClosedPipe
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// StreamInput records input from stdin, and passes it on to be echoed if Echo is set.
+func (s *Streamer) StreamInput(ctx context.Context, stdin io.Reader, restoreTerms func(), doneChan chan struct{}) {
+	s.record("StreamInput")
+	defer close(doneChan)
+	defer close(s.echo)
+
+	readInput(stdin, s.isPty, func(chunk []byte) {
+		s.m.Lock()
+		s.input.Write(chunk)
+		s.m.Unlock()
+
+		if !s.Echo {
+			return
+		}
+
+		select {
+		case s.echo <- append([]byte(nil), chunk...):
+		case <-s.detached:
+		case <-ctx.Done():
+		}
+	})
+}
+
+// ResizeTo records size
+func (s *Streamer) ResizeTo(ctx context.Context, size term.WindowSize) error {
+	s.record("ResizeTo")
+
+	s.m.Lock()
+	s.resizes = append(s.resizes, size)
+	s.m.Unlock()
+
+	return s.ResizeErr
+}
+
+// Result returns ExitCode
+func (s *Streamer) Result(ctx context.Context) (int, error) {
+	s.record("Result")
+	if s.ResultErr != nil {
+		return 0, s.ResultErr
+	}
+	return s.ExitCode, nil
+}
+
+// Detach detaches from this streamer, and stops streaming output.
+func (s *Streamer) Detach(ctx context.Context) error {
+	s.record("Detach")
+	if s.detached != nil {
+		s.detachOnce.Do(func() { close(s.detached) })
+	}
+	return s.DetachErr
+}
@@ -0,0 +1,170 @@
+package procutil
+
+import (
+	"sync"
+)
+
+// RingBuffer is an io.Writer that holds the last bytes written to it, up to a fixed size.
+// It is safe for concurrent use.
+//
+// A RingBuffer is typically used to keep the tail of the output of a command for error reports.
+type RingBuffer struct {
+	m    sync.Mutex
+	data []byte // circular storage
+	pos  int    // position the next byte is written to
+	full bool   // has data been filled completely?
+}
+
+// NewRingBuffer creates a new RingBuffer holding the last size bytes written to it.
+// When size is not positive, the buffer holds nothing.
+func NewRingBuffer(size int) *RingBuffer {
+	if size < 0 {
+		size = 0
+	}
+	return &RingBuffer{data: make([]byte, size)}
+}
+
+// Write writes p to the buffer, discarding the oldest bytes if needed.
+// It never fails.
+func (rb *RingBuffer) Write(p []byte) (int, error) {
+	rb.m.Lock()
+	defer rb.m.Unlock()
+
+	n := len(p)
+	size := len(rb.data)
+	if size == 0 {
+		return n, nil
+	}
+
+	// only the last size bytes are kept
+	if len(p) >= size {
+		copy(rb.data, p[len(p)-size:])
+		rb.pos, rb.full = 0, true
+		return n, nil
+	}
+
+	written := copy(rb.data[rb.pos:], p)
+	if written < len(p) {
+		copy(rb.data, p[written:])
+	}
+	if rb.pos+len(p) >= size {
+		rb.full = true
+	}
+	rb.pos = (rb.pos + len(p)) % size
+	return n, nil
+}
+
+// Bytes returns a copy of the content of this buffer, oldest byte first.
+func (rb *RingBuffer) Bytes() []byte {
+	rb.m.Lock()
+	defer rb.m.Unlock()
+
+	if !rb.full {
+		return append([]byte(nil), rb.data[:rb.pos]...)
+	}
+
+	buffer := make([]byte, 0, len(rb.data))
+	buffer = append(buffer, rb.data[rb.pos:]...)
+	return append(buffer, rb.data[:rb.pos]...)
+}
+
+// String returns the content of this buffer as a string
+func (rb *RingBuffer) String() string {
+	return string(rb.Bytes())
+}
+
+// Len returns the number of bytes currently held by this buffer
+func (rb *RingBuffer) Len() int {
+	rb.m.Lock()
+	defer rb.m.Unlock()
+
+	if rb.full {
+		return len(rb.data)
+	}
+	return rb.pos
+}
+
+// Size returns the maximum number of bytes held by this buffer
+func (rb *RingBuffer) Size() int {
+	return len(rb.data)
+}
+
+// Reset discards the content of this buffer
+func (rb *RingBuffer) Reset() {
+	rb.m.Lock()
+	defer rb.m.Unlock()
+
+	rb.pos, rb.full = 0, false
+}
+
+// LineRing is an io.Writer that holds the last lines written to it, up to a fixed number of lines.
+// It is safe for concurrent use.
+//
+// Lines are split like by a LineSink, including handling of carriage returns and the maximum line length.
+type LineRing struct {
+	m     sync.Mutex // protects the fields below
+	lines []string   // circular storage
+	next  int        // index the next line is written to
+	full  bool       // has lines been filled completely?
+
+	writer *lineWriter
+}
+
+// NewLineRing creates a new LineRing holding the last count lines written to it.
+// When count is not positive, the ring holds no lines.
+func NewLineRing(count int) *LineRing {
+	if count < 0 {
+		count = 0
+	}
+	lr := &LineRing{lines: make([]string, count)}
+	lr.writer = &lineWriter{
+		sink: &LineSink{Handler: lr.add},
+		max:  DefaultMaxLineLength,
+	}
+	return lr
+}
+
+// Write writes p to the ring, discarding the oldest lines if needed.
+// It never fails.
+func (lr *LineRing) Write(p []byte) (int, error) {
+	return lr.writer.Write(p)
+}
+
+// add adds a completed line to the ring
+func (lr *LineRing) add(record Record) {
+	lr.m.Lock()
+	defer lr.m.Unlock()
+
+	if len(lr.lines) == 0 {
+		return
+	}
+	lr.lines[lr.next] = record.Line
+	lr.next = (lr.next + 1) % len(lr.lines)
+	if lr.next == 0 {
+		lr.full = true
+	}
+}
+
+// Lines returns the lines currently held by the ring, oldest line first.
+// When the last line written is incomplete, it is included as the last line, and counts towards the number of lines.
+func (lr *LineRing) Lines() []string {
+	lr.writer.m.Lock()
+	defer lr.writer.m.Unlock()
+
+	lr.m.Lock()
+	defer lr.m.Unlock()
+
+	var lines []string
+	if lr.full {
+		lines = append(lines, lr.lines[lr.next:]...)
+	}
+	lines = append(lines, lr.lines[:lr.next]...)
+
+	if partial := lr.writer.line; len(partial) > 0 && len(lr.lines) > 0 {
+		if len(lines) == len(lr.lines) {
+			lines = lines[1:]
+		}
+		lines = append(lines, string(partial))
+	}
+	return lines
+}
@@ -0,0 +1,74 @@
+package procutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRingBuffer(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		writes []string
+		want   string
+	}{
+		{"empty", 4, nil, ""},
+		{"zero size", 0, []string{"abc"}, ""},
+		{"partially filled", 4, []string{"ab"}, "ab"},
+		{"exactly filled", 4, []string{"ab", "cd"}, "abcd"},
+		{"wrapping write", 4, []string{"abc", "def"}, "cdef"},
+		{"many small writes", 4, []string{"a", "b", "c", "d", "e", "f"}, "cdef"},
+		{"large write", 4, []string{"ab", "cdefgh"}, "efgh"},
+		{"write after large write", 4, []string{"abcdefgh", "ij"}, "ghij"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rb := NewRingBuffer(tt.size)
+			for _, write := range tt.writes {
+				if n, err := rb.Write([]byte(write)); n != len(write) || err != nil {
+					t.Fatalf("Write() = (%d, %v), want (%d, nil)", n, err, len(write))
+				}
+			}
+
+			if got := rb.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			if got := rb.Len(); got != len(tt.want) {
+				t.Errorf("Len() = %d, want %d", got, len(tt.want))
+			}
+
+			rb.Reset()
+			if got := rb.String(); got != "" {
+				t.Errorf("String() after Reset() = %q, want \"\"", got)
+			}
+		})
+	}
+}
+
+func TestLineRing(t *testing.T) {
+	tests := []struct {
+		name   string
+		count  int
+		writes []string
+		want   []string
+	}{
+		{"empty", 2, nil, nil},
+		{"zero count", 0, []string{"a\nb"}, nil},
+		{"fewer lines", 3, []string{"a\nb\n"}, []string{"a", "b"}},
+		{"more lines", 2, []string{"a\nb\n", "c\nd\n"}, []string{"c", "d"}},
+		{"partial line", 2, []string{"a\nb\nc"}, []string{"b", "c"}},
+		{"carriage return", 2, []string{"a\n10%\r100%\n"}, []string{"a", "100%"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lr := NewLineRing(tt.count)
+			for _, write := range tt.writes {
+				lr.Write([]byte(write))
+			}
+
+			if got := lr.Lines(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package procutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/tkw1536/procutil/term"
+)
+
+// Session is a process running on a pty that is independent of the clients connected to it.
+//
+// Clients may attach to and detach from a session at any point while it is running.
+// Upon attaching, a client first receives the scrollback of the session, followed by any subsequent output.
+// Input from clients that are not read-only is forwarded to the process.
+//
+// The size of the pty is determined by the attached clients according to the ResizePolicy.
+//
+// A Session may not be copied once it has been started.
+type Session struct {
+	Process Process // the process to run, must support running on a pty
+
+	TERM       string       // name of the terminal, passed to Command.StartPty()
+	Scrollback int          // maximum number of bytes of output to replay to newly attached clients, defaults to DefaultScrollback
+	Resize     ResizePolicy // policy used to determine the size of the pty
+
+	command Command
+
+	m sync.Mutex // protects all fields below
+
+	started bool
+	done    bool
+
+	clients    map[*SessionClient]struct{}
+	scrollback *RingBuffer
+	stamp      uint64 // incremented every time a client attaches or resizes
+
+	size       term.WindowSize // last size sent to the process
+	resizeChan chan term.WindowSize
+
+	inR *io.PipeReader // read end of input from clients
+	inW *io.PipeWriter // write end of input from clients
+}
+
+// DefaultScrollback is the default amount of scrollback kept by a Session.
+const DefaultScrollback = 64 * 1024
+
+// DefaultSessionClientBuffer is the number of pending writes buffered for each SessionClient.
+// Clients that fall further behind are detached, to prevent them from stalling the session.
+const DefaultSessionClientBuffer = 128
+
+// ResizePolicy determines how a session is sized when multiple clients are attached.
+type ResizePolicy int
+
+const (
+	// ResizeSmallest sizes the session to the smallest height and width of all attached clients.
+	ResizeSmallest ResizePolicy = iota
+
+	// ResizeLatest sizes the session to the client that most recently attached or resized.
+	ResizeLatest
+)
+
+var errSessionAlreadyStarted = errors.New("Session: Already started")
+
+// Start starts the underlying process of this session on a new pty.
+// Once the context is closed, the process will be killed.
+//
+// Start may only be called once.
+func (s *Session) Start(ctx context.Context) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.started {
+		return errSessionAlreadyStarted
+	}
+	s.started = true
+
+	if s.Scrollback <= 0 {
+		s.Scrollback = DefaultScrollback
+	}
+	s.scrollback = NewRingBuffer(s.Scrollback)
+	s.clients = make(map[*SessionClient]struct{})
+	s.resizeChan = make(chan term.WindowSize, 1)
+	s.inR, s.inW = io.Pipe()
+
+	s.command.Process = s.Process
+	if err := s.command.Init(ctx, true); err != nil {
+		s.done = true
+		return err
+	}
+
+	if err := s.command.StartPty(sessionPty{s}, s.TERM, s.resizeChan); err != nil {
+		s.done = true
+		return err
+	}
+
+	return nil
+}
+
+// Wait waits for the process in this session to exit and returns its exit code.
+func (s *Session) Wait() (int, error) {
+	return s.command.Wait()
+}
+
+// Stop stops the process in this session.
+func (s *Session) Stop() error {
+	return s.command.Stop()
+}
+
+// Clients returns the number of clients currently attached to this session.
+func (s *Session) Clients() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return len(s.clients)
+}
+
+var errSessionNotRunning = errors.New("Session: Not running")
+
+// Attach attaches a new client to this session.
+//
+// Output of the session is written to conn, starting with the current scrollback.
+// When readOnly is false, anything read from conn is forwarded to the process; otherwise it is discarded.
+// size is the initial size of the client, and may be nil when the client does not have a size.
+//
+// The client is detached once conn returns an error, the session exits or Detach() is called.
+// conn is closed when the client is detached.
+func (s *Session) Attach(conn io.ReadWriteCloser, size *term.WindowSize, readOnly bool) (*SessionClient, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.started || s.done {
+		return nil, errSessionNotRunning
+	}
+
+	client := &SessionClient{
+		session:  s,
+		conn:     conn,
+		readOnly: readOnly,
+		out:      make(chan []byte, DefaultSessionClientBuffer),
+		done:     make(chan struct{}),
+	}
+
+	// replay the scrollback
+	if scrollback := s.scrollback.Bytes(); len(scrollback) > 0 {
+		client.out <- scrollback
+	}
+
+	s.clients[client] = struct{}{}
+	if size != nil {
+		client.setSize(*size)
+	}
+	s.resize()
+
+	go client.writeOutput()
+	go client.readInput()
+
+	return client, nil
+}
+
+// output is called when the process writes output.
+func (s *Session) output(p []byte) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.scrollback.Write(p)
+
+	for client := range s.clients {
+		buffer := make([]byte, len(p))
+		copy(buffer, p)
+
+		select {
+		case client.out <- buffer:
+		default:
+			// client can not keep up
+			s.detach(client)
+		}
+	}
+}
+
+// finish is called when the process has finished writing output.
+func (s *Session) finish() {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.done {
+		return
+	}
+	s.done = true
+
+	for client := range s.clients {
+		s.detach(client)
+	}
+
+	s.inW.Close()
+	close(s.resizeChan)
+}
+
+// detach removes client from this session.
+// s.m must be held by the caller.
+func (s *Session) detach(client *SessionClient) {
+	if _, ok := s.clients[client]; !ok {
+		return
+	}
+	delete(s.clients, client)
+	close(client.out)
+
+	s.resize()
+}
+
+// resize sends an appropriate size to the process according to the resize policy.
+// s.m must be held by the caller.
+func (s *Session) resize() {
+	if s.done {
+		return
+	}
+
+	var size term.WindowSize
+	var stamp uint64
+	for client := range s.clients {
+		if client.size == nil {
+			continue
+		}
+
+		switch s.Resize {
+		case ResizeLatest:
+			if client.stamp > stamp {
+				size, stamp = *client.size, client.stamp
+			}
+		default:
+			if size.Height == 0 || client.size.Height < size.Height {
+				size.Height = client.size.Height
+			}
+			if size.Width == 0 || client.size.Width < size.Width {
+				size.Width = client.size.Width
+			}
+		}
+	}
+
+	if size.Height == 0 || size.Width == 0 || size == s.size {
+		return
+	}
+	s.size = size
+
+	// replace any pending size with the current one
+	term.SendLatest(s.resizeChan, size)
+}
+
+// sessionPty is the terminal side of the pty of a session.
+// It implements io.ReadWriteCloser and DualCloser.
+type sessionPty struct {
+	s *Session
+}
+
+func (sp sessionPty) Read(p []byte) (int, error) {
+	return sp.s.inR.Read(p)
+}
+
+func (sp sessionPty) Write(p []byte) (int, error) {
+	sp.s.output(p)
+	return len(p), nil
+}
+
+func (sp sessionPty) Close() error {
+	return nil
+}
+
+func (sp sessionPty) CloseWrite() error {
+	sp.s.finish()
+	return nil
+}
+
+// SessionClient represents a client attached to a Session.
+type SessionClient struct {
+	session  *Session
+	conn     io.ReadWriteCloser
+	readOnly bool
+
+	// protected by session.m
+	size  *term.WindowSize
+	stamp uint64
+	out   chan []byte
+
+	done chan struct{} // closed once the client has been fully detached
+}
+
+// Resize informs the session that the size of this client has changed.
+func (c *SessionClient) Resize(size term.WindowSize) {
+	c.session.m.Lock()
+	defer c.session.m.Unlock()
+
+	if _, ok := c.session.clients[c]; !ok {
+		return
+	}
+
+	c.setSize(size)
+	c.session.resize()
+}
+
+// setSize sets the size of this client.
+// c.session.m must be held by the caller.
+func (c *SessionClient) setSize(size term.WindowSize) {
+	c.session.stamp++
+	c.size = &size
+	c.stamp = c.session.stamp
+}
+
+// Detach detaches this client from the session and closes the underlying connection.
+// It is safe to call Detach multiple times.
+func (c *SessionClient) Detach() error {
+	c.session.m.Lock()
+	c.session.detach(c)
+	c.session.m.Unlock()
+
+	<-c.done
+	return nil
+}
+
+// Done returns a channel that is closed once this client has been detached.
+func (c *SessionClient) Done() <-chan struct{} {
+	return c.done
+}
+
+// writeOutput writes output of the session to the client.
+func (c *SessionClient) writeOutput() {
+	defer close(c.done)
+	defer c.conn.Close()
+
+	failed := false
+	for chunk := range c.out {
+		if failed {
+			continue
+		}
+		if _, err := c.conn.Write(chunk); err != nil {
+			failed = true
+			go c.Detach()
+		}
+	}
+}
+
+// readInput reads input from the client and forwards it to the session.
+func (c *SessionClient) readInput() {
+	defer c.Detach()
+
+	var dest io.Writer = c.session.inW
+	if c.readOnly {
+		dest = ioutil.Discard
+	}
+	io.Copy(dest, c.conn)
+}
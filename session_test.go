@@ -0,0 +1,114 @@
+package procutil
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tkw1536/procutil/term"
+)
+
+// readUntil reads from conn until the output contains want.
+func readUntil(t *testing.T, conn net.Conn, want string) string {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+
+	var builder strings.Builder
+	b := make([]byte, 1)
+	for !strings.Contains(builder.String(), want) {
+		if _, err := conn.Read(b); err != nil {
+			t.Fatalf("read %q, but never got %q: %s", builder.String(), want, err)
+		}
+		builder.WriteByte(b[0])
+	}
+	return builder.String()
+}
+
+func TestSession(t *testing.T) {
+	if !term.PTYSupport {
+		t.Skip("OS not supported")
+	}
+
+	session := &Session{
+		Process: &ExecProcess{
+			Command: "/bin/sh",
+			Args:    []string{"-c", "echo ready; read line; echo got-$line"},
+		},
+		TERM: "xterm",
+	}
+	if err := session.Start(context.Background()); err != nil {
+		t.Fatalf("Session.Start() returned %s", err)
+	}
+
+	// attach a read-only client, which should see the scrollback
+	watcher, watcherRemote := net.Pipe()
+	if _, err := session.Attach(watcherRemote, &term.WindowSize{Height: 24, Width: 80}, true); err != nil {
+		t.Fatalf("Session.Attach() returned %s", err)
+	}
+	readUntil(t, watcher, "ready")
+
+	// attach a read-write client, and send some input
+	writer, writerRemote := net.Pipe()
+	client, err := session.Attach(writerRemote, &term.WindowSize{Height: 20, Width: 100}, false)
+	if err != nil {
+		t.Fatalf("Session.Attach() returned %s", err)
+	}
+	if got := session.Clients(); got != 2 {
+		t.Errorf("Session.Clients() = %d, want 2", got)
+	}
+	go io.Copy(ioutil.Discard, writer)
+	if _, err := writer.Write([]byte("hello\n")); err != nil {
+		t.Fatalf("writing input failed: %s", err)
+	}
+
+	// both clients should see the output
+	readUntil(t, watcher, "got-hello")
+	<-client.Done()
+
+	if code, err := session.Wait(); code != 0 || err != nil {
+		t.Errorf("Session.Wait() = (%d, %v), want (0, nil)", code, err)
+	}
+	if got := session.Clients(); got != 0 {
+		t.Errorf("Session.Clients() = %d, want 0", got)
+	}
+}
+
+func TestSession_resize(t *testing.T) {
+	small := term.WindowSize{Height: 10, Width: 100}
+	large := term.WindowSize{Height: 20, Width: 50}
+
+	tests := []struct {
+		name   string
+		policy ResizePolicy
+		want   term.WindowSize
+	}{
+		{"smallest", ResizeSmallest, term.WindowSize{Height: 10, Width: 50}},
+		{"latest", ResizeLatest, large},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Session{
+				Resize:     tt.policy,
+				clients:    make(map[*SessionClient]struct{}),
+				resizeChan: make(chan term.WindowSize, 1),
+			}
+
+			for _, size := range []term.WindowSize{small, large} {
+				client := &SessionClient{session: s}
+				s.clients[client] = struct{}{}
+				client.setSize(size)
+				s.resize()
+			}
+
+			if got := <-s.resizeChan; got != tt.want {
+				t.Errorf("Session.resize() sent %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -78,6 +78,11 @@ func (des *DockerExecStreamer) Init(ctx context.Context, Term string, isPty bool
 		des.config.Tty = true
 		des.config.Env = append(des.config.Env, "TERM="+Term)
 	}
+
+	// propagate the trace context, if any
+	if tp := traceParentEnv(ctx); tp != "" {
+		des.config.Env = append(des.config.Env, tp)
+	}
 	return nil
 }
 
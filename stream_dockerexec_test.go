@@ -0,0 +1,125 @@
+package procutil_test
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/tkw1536/procutil"
+	"github.com/tkw1536/procutil/procutiltest"
+)
+
+func TestDockerExecStreamer_conformance(t *testing.T) {
+	server := procutiltest.NewDockerServer(func(containerID string, config types.ExecConfig) (procutil.Process, error) {
+		return &procutiltest.Process{Echo: true}, nil
+	})
+	defer server.Close()
+
+	client, err := server.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	procutiltest.TestStreamer(t, func() procutil.Streamer {
+		return procutil.NewDockerExecProcess(client, "container", []string{"cat"}).Streamer
+	}, procutiltest.Conformance{Echo: true, Pty: true})
+}
+
+func TestDockerExecStreamer_output(t *testing.T) {
+	server := procutiltest.NewDockerServer(func(containerID string, config types.ExecConfig) (procutil.Process, error) {
+		return &procutiltest.Process{
+			Output: []procutiltest.Output{
+				{Data: "out"},
+				{Data: "err", Stderr: true},
+			},
+			ExitCode: 3,
+		}, nil
+	})
+	defer server.Close()
+
+	client, err := server.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	command := &procutil.Command{Process: procutil.NewDockerExecProcess(client, "container", []string{"echo"})}
+	if err := command.Init(context.Background(), false); err != nil {
+		t.Fatalf("Init() returned %s", err)
+	}
+
+	var stdout, stderr procutiltest.Buffer
+	if err := command.Start(&stdout, &stderr, strings.NewReader("")); err != nil {
+		t.Fatalf("Start() returned %s", err)
+	}
+	if code, err := command.Wait(); code != 3 || err != nil {
+		t.Errorf("Wait() = (%d, %v), want (3, nil)", code, err)
+	}
+	command.Cleanup()
+
+	// output is copied asynchronously
+	deadline := time.Now().Add(time.Second)
+	for (stdout.String() != "out" || stderr.String() != "err") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stdout.String() != "out" || stderr.String() != "err" {
+		t.Errorf("process wrote (%q, %q), want (\"out\", \"err\")", stdout.String(), stderr.String())
+	}
+
+	execs := server.Execs()
+	if len(execs) != 1 {
+		t.Fatalf("server has %d exec instances, want 1", len(execs))
+	}
+	if exec := execs[0]; exec.ContainerID != "container" || exec.Running || exec.ExitCode != 3 || exec.Tty {
+		t.Errorf("server has exec instance %+v", exec)
+	}
+}
+
+func TestDockerExecStreamer_traceParent(t *testing.T) {
+	server := procutiltest.NewDockerServer(func(containerID string, config types.ExecConfig) (procutil.Process, error) {
+		return &procutiltest.Process{}, nil
+	})
+	defer server.Close()
+
+	client, err := server.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	command := &procutil.Command{Process: procutil.NewDockerExecProcess(client, "container", []string{"true"})}
+	ctx := procutil.ContextWithSpan(context.Background(), traceSpan{})
+	if err := command.Init(ctx, false); err != nil {
+		t.Fatalf("Init() returned %s", err)
+	}
+	if err := command.Start(ioutil.Discard, ioutil.Discard, strings.NewReader("")); err != nil {
+		t.Fatalf("Start() returned %s", err)
+	}
+	command.Wait()
+	command.Cleanup()
+
+	execs := server.Execs()
+	if len(execs) != 1 {
+		t.Fatalf("server has %d exec instances, want 1", len(execs))
+	}
+	want := procutil.TraceParentEnv + "=" + traceSpan{}.SpanContext().TraceParent()
+	if env := execs[0].Config.Env; len(env) != 1 || env[0] != want {
+		t.Errorf("exec instance has environment %v, want [%s]", env, want)
+	}
+}
+
+// traceSpan is a span with a fixed span context that ignores all calls to it
+type traceSpan struct{}
+
+func (traceSpan) SpanContext() procutil.SpanContext {
+	return procutil.SpanContext{TraceID: [16]byte{1}, SpanID: [8]byte{2}, Sampled: true}
+}
+func (traceSpan) AddEvent(name string, attributes ...procutil.Attribute) {}
+func (traceSpan) SetAttributes(attributes ...procutil.Attribute)         {}
+func (traceSpan) RecordError(err error)                                  {}
+func (traceSpan) SetStatus(code procutil.StatusCode, description string) {}
+func (traceSpan) End()                                                   {}
@@ -0,0 +1,375 @@
+package procutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Supervisor runs a process, and restarts it according to a RestartPolicy when it exits.
+//
+// Every run uses a new Process returned by NewProcess.
+// When restarting, the supervisor waits using exponential backoff with jitter.
+// The delay starts at MinBackoff and doubles with every consecutive restart, up to MaxBackoff.
+// A run that lasts at least ResetAfter resets the backoff and the count of consecutive restarts.
+//
+// Once the context passed to Run() is closed, the supervisor shuts down the current process gracefully.
+// It first sends StopSignal to the process, when it supports job control, and waits for up to StopTimeout.
+// If the process has not exited by then, it is stopped using Command.Stop().
+// An *ExecProcess is run in its own process group, see ExecProcess.ProcessGroup, so that its children are shut down as well.
+// Children of the process that keep its output open do not delay shutdown for more than DefaultWaitDelay.
+//
+// A Supervisor may only be run once.
+type Supervisor struct {
+	NewProcess func() (Process, error) // returns the process to run, called for every run
+
+	Restart     RestartPolicy // when to restart the process
+	MaxRestarts int           // maximum number of consecutive restarts, or 0 for no limit
+
+	MinBackoff time.Duration // delay before the first restart, defaults to DefaultMinBackoff
+	MaxBackoff time.Duration // maximum delay between restarts, defaults to DefaultMaxBackoff
+	Jitter     float64       // randomizes delays by up to this fraction, e.g. 0.1 for ±10%
+	ResetAfter time.Duration // minimum duration of a run to reset the backoff, defaults to DefaultResetAfter
+
+	StopSignal  os.Signal     // signal used for graceful shutdown, defaults to SIGTERM
+	StopTimeout time.Duration // time to wait for the process to exit after StopSignal, defaults to DefaultStopTimeout
+
+	Out, Err io.Writer // receive the output of every run, may be nil
+	Observer Observer  // when not nil, observes the command of every run
+
+	m        sync.Mutex // protects the fields below
+	started  bool
+	state    SupervisorState
+	history  []SupervisorRun
+	restarts int      // consecutive restarts
+	command  *Command // command of the current run, if any
+}
+
+// RestartPolicy determines when a Supervisor restarts a process
+type RestartPolicy int
+
+const (
+	// RestartNever never restarts the process
+	RestartNever RestartPolicy = iota
+	// RestartOnFailure restarts the process when it exits with a non-zero exit code, or fails to run
+	RestartOnFailure
+	// RestartAlways restarts the process whenever it exits
+	RestartAlways
+)
+
+func (p RestartPolicy) String() string {
+	switch p {
+	case RestartNever:
+		return "never"
+	case RestartOnFailure:
+		return "on-failure"
+	case RestartAlways:
+		return "always"
+	default:
+		return fmt.Sprintf("RestartPolicy(%d)", int(p))
+	}
+}
+
+// SupervisorState is the state of a Supervisor
+type SupervisorState int
+
+const (
+	// SupervisorIdle is the state of a Supervisor before Run() has been called
+	SupervisorIdle SupervisorState = iota
+	// SupervisorRunning is the state of a Supervisor while the process is running
+	SupervisorRunning
+	// SupervisorBackoff is the state of a Supervisor while waiting to restart the process
+	SupervisorBackoff
+	// SupervisorStopping is the state of a Supervisor while shutting down the process
+	SupervisorStopping
+	// SupervisorStopped is the state of a Supervisor once Run() has returned
+	SupervisorStopped
+)
+
+func (s SupervisorState) String() string {
+	switch s {
+	case SupervisorIdle:
+		return "idle"
+	case SupervisorRunning:
+		return "running"
+	case SupervisorBackoff:
+		return "backoff"
+	case SupervisorStopping:
+		return "stopping"
+	case SupervisorStopped:
+		return "stopped"
+	default:
+		return fmt.Sprintf("SupervisorState(%d)", int(s))
+	}
+}
+
+// SupervisorRun describes a single run of the process of a Supervisor
+type SupervisorRun struct {
+	Process  string    // String() of the process
+	Start    time.Time // time the run started
+	End      time.Time // time the run ended
+	ExitCode int       // exit code of the process
+	Err      error     // error of the run, if any; wraps an *ExitError when the process exited with a non-zero exit code
+}
+
+// Defaults for the fields of Supervisor
+const (
+	DefaultMinBackoff  = time.Second
+	DefaultMaxBackoff  = time.Minute
+	DefaultResetAfter  = time.Minute
+	DefaultStopTimeout = 10 * time.Second
+)
+
+// MaxSupervisorHistory is the maximum number of runs kept in the history of a Supervisor
+const MaxSupervisorHistory = 100
+
+// Errors returned by Supervisor.Run()
+var (
+	ErrSupervisorAlreadyStarted = errors.New("Supervisor: Already started")
+	ErrSupervisorNoProcess      = errors.New("Supervisor: NewProcess is nil")
+	ErrSupervisorMaxRestarts    = errors.New("Supervisor: Maximum number of restarts reached")
+)
+
+// Run runs the process, restarting it according to the restart policy, and blocks until supervision ends.
+//
+// When the process is not restarted, returns the error of the last run, if any.
+// When the maximum number of restarts has been reached, returns an error wrapping ErrSupervisorMaxRestarts.
+// When ctx is closed, shuts down the process gracefully and returns nil.
+func (s *Supervisor) Run(ctx context.Context) error {
+	s.m.Lock()
+	if s.started {
+		s.m.Unlock()
+		return ErrSupervisorAlreadyStarted
+	}
+	s.started = true
+	s.m.Unlock()
+
+	defer s.setState(SupervisorStopped)
+
+	if s.NewProcess == nil {
+		return ErrSupervisorNoProcess
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for {
+		run := s.run(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		failed := run.Err != nil
+		if s.Restart == RestartNever || (s.Restart == RestartOnFailure && !failed) {
+			return run.Err
+		}
+
+		// reset the restarts after a long run
+		s.m.Lock()
+		if run.End.Sub(run.Start) >= s.resetAfter() {
+			s.restarts = 0
+		}
+		restarts := s.restarts
+		s.m.Unlock()
+
+		if s.MaxRestarts > 0 && restarts >= s.MaxRestarts {
+			if run.Err != nil {
+				return errors.Wrapf(ErrSupervisorMaxRestarts, "Last run failed: %s", run.Err)
+			}
+			return ErrSupervisorMaxRestarts
+		}
+
+		// wait before restarting
+		s.setState(SupervisorBackoff)
+		timer := time.NewTimer(s.backoff(restarts, random))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		}
+
+		s.m.Lock()
+		s.restarts++
+		s.m.Unlock()
+	}
+}
+
+// run runs the process once, and records the run in the history
+func (s *Supervisor) run(ctx context.Context) (run SupervisorRun) {
+	run.Start = time.Now()
+	defer func() {
+		run.End = time.Now()
+
+		s.m.Lock()
+		defer s.m.Unlock()
+
+		s.command = nil
+		s.history = append(s.history, run)
+		if len(s.history) > MaxSupervisorHistory {
+			s.history = append([]SupervisorRun(nil), s.history[len(s.history)-MaxSupervisorHistory:]...)
+		}
+	}()
+
+	process, err := s.NewProcess()
+	if err != nil {
+		run.Err = err
+		return
+	}
+	if ep, ok := process.(*ExecProcess); ok {
+		ep.ProcessGroup = true
+	}
+
+	command := &Command{Process: process}
+	if s.Observer != nil {
+		command.AddObserver(s.Observer)
+	}
+
+	s.m.Lock()
+	s.command = command
+	s.state = SupervisorRunning
+	s.m.Unlock()
+
+	// the process is only killed by cancelling runCtx once graceful shutdown has failed
+	runCtx, cancel := context.WithCancel(valueContext{ctx})
+	defer cancel()
+
+	exited := make(chan struct{})
+	watching := make(chan struct{})
+	go func() {
+		defer close(watching)
+		select {
+		case <-exited:
+		case <-ctx.Done():
+			s.shutdown(command, exited)
+			cancel()
+		}
+	}()
+
+	run.Err = command.Run(runCtx, s.Out, s.Err, nil)
+	close(exited)
+	<-watching
+	run.Process = command.String()
+
+	if command.State() == CommandStateDone {
+		run.ExitCode, _ = command.Wait()
+	}
+	return
+}
+
+// shutdown gracefully shuts down command, and returns once it has exited or been stopped
+func (s *Supervisor) shutdown(command *Command, exited <-chan struct{}) {
+	s.setState(SupervisorStopping)
+	stopGracefully(command, s.StopSignal, s.StopTimeout, exited)
+}
+
+// stopGracefully sends signal to command, and waits for up to timeout for exited to be closed.
+// If the command does not support job control or has not exited in time, it is stopped using Command.Stop().
+// signal defaults to SIGTERM, and timeout to DefaultStopTimeout.
+func stopGracefully(command *Command, signal os.Signal, timeout time.Duration, exited <-chan struct{}) {
+	if signal == nil {
+		signal = syscall.SIGTERM
+	}
+	if timeout <= 0 {
+		timeout = DefaultStopTimeout
+	}
+
+	if err := command.Signal(signal); err == nil {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-exited:
+			return
+		case <-timer.C:
+		}
+	}
+
+	command.Stop()
+}
+
+// backoff returns the delay before the restart after the given number of consecutive restarts
+func (s *Supervisor) backoff(restarts int, random *rand.Rand) time.Duration {
+	min, max := s.MinBackoff, s.MaxBackoff
+	if min <= 0 {
+		min = DefaultMinBackoff
+	}
+	if max <= 0 {
+		max = DefaultMaxBackoff
+	}
+
+	delay := float64(min) * math.Pow(2, float64(restarts))
+	if delay > float64(max) {
+		delay = float64(max)
+	}
+	if s.Jitter > 0 {
+		delay *= 1 + s.Jitter*(2*random.Float64()-1)
+	}
+	return time.Duration(delay)
+}
+
+func (s *Supervisor) resetAfter() time.Duration {
+	if s.ResetAfter <= 0 {
+		return DefaultResetAfter
+	}
+	return s.ResetAfter
+}
+
+func (s *Supervisor) setState(state SupervisorState) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.state = state
+}
+
+// State returns the current state of the supervisor
+func (s *Supervisor) State() SupervisorState {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.state
+}
+
+// Restarts returns the number of consecutive restarts of the process
+func (s *Supervisor) Restarts() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.restarts
+}
+
+// History returns the completed runs of the process, oldest first.
+// At most MaxSupervisorHistory runs are kept.
+func (s *Supervisor) History() []SupervisorRun {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return append([]SupervisorRun(nil), s.history...)
+}
+
+// Command returns the command of the current run, or nil when the process is not running.
+// It can be used to interact with the running process, for example to send it signals.
+func (s *Supervisor) Command() *Command {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.command
+}
+
+// valueContext is a context that has the values of the underlying context, but is never cancelled
+type valueContext struct {
+	context.Context
+}
+
+func (valueContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (valueContext) Done() <-chan struct{}       { return nil }
+func (valueContext) Err() error                  { return nil }
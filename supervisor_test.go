@@ -0,0 +1,192 @@
+package procutil
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testProcesses returns a Supervisor.NewProcess function returning a testProcess for each of the given exit codes.
+// Once all exit codes have been used, the last one is repeated.
+func testProcesses(codes ...int) func() (Process, error) {
+	var m sync.Mutex
+	i := 0
+	return func() (Process, error) {
+		m.Lock()
+		defer m.Unlock()
+
+		code := codes[i]
+		if i < len(codes)-1 {
+			i++
+		}
+		return &testProcess{ExitCode: code}, nil
+	}
+}
+
+func TestSupervisor_Run(t *testing.T) {
+	errNewProcess := errors.New("no process")
+
+	tests := []struct {
+		name        string
+		newProcess  func() (Process, error)
+		policy      RestartPolicy
+		maxRestarts int
+		wantCodes   []int
+		wantErr     error
+		wantExit    bool
+	}{
+		{"never, success", testProcesses(0), RestartNever, 0, []int{0}, nil, false},
+		{"never, failure", testProcesses(3), RestartNever, 0, []int{3}, nil, true},
+		{"on failure, eventual success", testProcesses(1, 2, 0), RestartOnFailure, 0, []int{1, 2, 0}, nil, false},
+		{"on failure, max restarts", testProcesses(1), RestartOnFailure, 2, []int{1, 1, 1}, ErrSupervisorMaxRestarts, false},
+		{"always, max restarts", testProcesses(0), RestartAlways, 1, []int{0, 0}, ErrSupervisorMaxRestarts, false},
+		{"failing factory", func() (Process, error) { return nil, errNewProcess }, RestartOnFailure, 1, []int{0, 0}, ErrSupervisorMaxRestarts, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			supervisor := &Supervisor{
+				NewProcess:  tt.newProcess,
+				Restart:     tt.policy,
+				MaxRestarts: tt.maxRestarts,
+				MinBackoff:  time.Millisecond,
+				MaxBackoff:  time.Millisecond,
+				Jitter:      0.5,
+			}
+
+			err := supervisor.Run(context.Background())
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Run() returned %v, want %v", err, tt.wantErr)
+			}
+			var exitErr *ExitError
+			if tt.wantExit != errors.As(err, &exitErr) {
+				t.Errorf("Run() returned %v, want *ExitError = %t", err, tt.wantExit)
+			}
+			if tt.wantErr == nil && !tt.wantExit && err != nil {
+				t.Errorf("Run() returned %v, want nil", err)
+			}
+
+			var codes []int
+			for _, run := range supervisor.History() {
+				codes = append(codes, run.ExitCode)
+				if run.End.Before(run.Start) {
+					t.Errorf("run %+v ended before it started", run)
+				}
+			}
+			if len(codes) != len(tt.wantCodes) {
+				t.Fatalf("History() has exit codes %v, want %v", codes, tt.wantCodes)
+			}
+			for i := range codes {
+				if codes[i] != tt.wantCodes[i] {
+					t.Errorf("History() has exit codes %v, want %v", codes, tt.wantCodes)
+					break
+				}
+			}
+
+			if got := supervisor.Restarts(); got != len(tt.wantCodes)-1 {
+				t.Errorf("Restarts() = %d, want %d", got, len(tt.wantCodes)-1)
+			}
+			if state := supervisor.State(); state != SupervisorStopped {
+				t.Errorf("State() = %v, want %v", state, SupervisorStopped)
+			}
+			if err := supervisor.Run(context.Background()); err != ErrSupervisorAlreadyStarted {
+				t.Errorf("second Run() returned %v, want ErrSupervisorAlreadyStarted", err)
+			}
+		})
+	}
+}
+
+func TestSupervisor_backoff(t *testing.T) {
+	supervisor := &Supervisor{MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	for restarts, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		if got := supervisor.backoff(restarts, nil); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", restarts, got, want)
+		}
+	}
+
+	supervisor.Jitter = 0.5
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if got := supervisor.backoff(1, random); got < time.Second || got > 3*time.Second {
+			t.Fatalf("backoff(1) with jitter = %v, want between 1s and 3s", got)
+		}
+	}
+}
+
+func TestSupervisor_shutdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		wantCode int
+	}{
+		{"graceful", `trap "exit 7" TERM; echo ready; while true; do sleep 0.01; done`, 7},
+		{"timeout", `trap "" TERM; echo ready; while true; do sleep 0.01; done`, -1},
+		{"graceful, forked child", `(sleep 1; touch "$LEAKED") & trap "exit 7" TERM; echo ready; while true; do sleep 0.01; done`, 7},
+		{"timeout, forked child", `(sleep 1; touch "$LEAKED") & trap "" TERM; echo ready; while true; do sleep 0.01; done`, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaked := filepath.Join(t.TempDir(), "leaked")
+			ready := make(chan struct{})
+			var once sync.Once
+			sink := &LineSink{Handler: func(record Record) {
+				if record.Line == "ready" {
+					once.Do(func() { close(ready) })
+				}
+			}}
+
+			supervisor := &Supervisor{
+				NewProcess: func() (Process, error) {
+					return &ExecProcess{Command: "/bin/sh", Args: []string{"-c", tt.script}, Env: []string{"LEAKED=" + leaked}}, nil
+				},
+				Restart:     RestartAlways,
+				StopTimeout: 100 * time.Millisecond,
+				Out:         sink.Stdout(),
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() {
+				done <- supervisor.Run(ctx)
+			}()
+
+			select {
+			case <-ready:
+			case <-time.After(5 * time.Second):
+				t.Fatal("process did not become ready")
+			}
+			start := time.Now()
+			if state := supervisor.State(); state != SupervisorRunning {
+				t.Errorf("State() = %v, want %v", state, SupervisorRunning)
+			}
+			if supervisor.Command() == nil {
+				t.Error("Command() = nil while running")
+			}
+			cancel()
+
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Errorf("Run() returned %v, want nil", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Run() did not return after cancellation")
+			}
+
+			history := supervisor.History()
+			if len(history) != 1 || history[0].ExitCode != tt.wantCode {
+				t.Errorf("History() = %+v, want a single run with exit code %d", history, tt.wantCode)
+			}
+
+			// a child that survived the shutdown creates the file
+			time.Sleep(time.Until(start.Add(1500 * time.Millisecond)))
+			if _, err := os.Stat(leaked); err == nil {
+				t.Error("child of the process is still running after shutdown")
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package term
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// FakeTerminal is an in-memory Terminal intended for tests.
+// It can be used to test code that expects a Terminal without any support from the operating system.
+//
+// A FakeTerminal always reports being a terminal.
+// Data written to its ReadWriteCloser can be read from Output(), and data written to Input() can be read from its ReadWriteCloser.
+// Both directions are buffered, and writes never block.
+// It does not emulate a line discipline; in particular input is never echoed.
+//
+// Calls to methods of the Terminal interface that change the state of the terminal are recorded, and can be retrieved using Calls().
+// Size changes can be scripted using Resize().
+//
+// A FakeTerminal is safe for concurrent use.
+type FakeTerminal struct {
+	input, output *fakePipe
+
+	m       sync.Mutex
+	calls   []string
+	size    WindowSize
+	resizes chan WindowSize
+	closed  bool
+
+	mode                Mode
+	inSaved, outSaved   *Mode // mode before SetRawInput() and SetRawOutput()
+	rawInput, rawOutput bool
+}
+
+// FakeTerminal implements Terminal
+func init() {
+	var _ Terminal = (*FakeTerminal)(nil)
+}
+
+// DefaultFakeMode is the mode of a new FakeTerminal.
+// It corresponds to a terminal in cooked mode with the usual control characters.
+var DefaultFakeMode = Mode{
+	Echo:             true,
+	Canonical:        true,
+	Signals:          true,
+	OutputProcessing: true,
+
+	Interrupt: 0x03, // Ctrl-C
+	Quit:      0x1c, // Ctrl-\
+	Erase:     0x7f, // Backspace
+	Kill:      0x15, // Ctrl-U
+	EOF:       0x04, // Ctrl-D
+	Suspend:   0x1a, // Ctrl-Z
+}
+
+// NewFakeTerminal returns a new FakeTerminal of the given size and with DefaultFakeMode.
+func NewFakeTerminal(size WindowSize) *FakeTerminal {
+	ft := &FakeTerminal{
+		input:   newFakePipe(),
+		output:  newFakePipe(),
+		size:    size,
+		resizes: make(chan WindowSize, 1),
+		mode:    DefaultFakeMode,
+	}
+	ft.resizes <- size
+	return ft
+}
+
+// Input returns a writer to send input to the terminal, as if it was typed by the user.
+// Closing it causes reads from the terminal to return io.EOF once all input has been read.
+func (ft *FakeTerminal) Input() io.WriteCloser {
+	return ft.input
+}
+
+// Output returns a reader to read the output written to the terminal.
+// Reads block until output is available, and return io.EOF once the terminal has been closed.
+func (ft *FakeTerminal) Output() io.Reader {
+	return ft.output
+}
+
+// Calls returns the names of the state-changing methods of the Terminal interface called so far, in order.
+// These are SetRawInput, RestoreInput, SetRawOutput, RestoreOutput, ResizeTo, SetMode and Close.
+func (ft *FakeTerminal) Calls() []string {
+	ft.m.Lock()
+	defer ft.m.Unlock()
+
+	return append([]string(nil), ft.calls...)
+}
+
+// IsRaw reports if input and output of the terminal are currently in raw mode.
+func (ft *FakeTerminal) IsRaw() (input, output bool) {
+	ft.m.Lock()
+	defer ft.m.Unlock()
+
+	return ft.rawInput, ft.rawOutput
+}
+
+// Resize changes the size of the terminal, as if the user had resized the window.
+// The new size is sent to ResizeChan().
+// Unlike ResizeTo(), it is not recorded as a call.
+func (ft *FakeTerminal) Resize(size WindowSize) {
+	ft.m.Lock()
+	defer ft.m.Unlock()
+
+	ft.size = size
+	if !ft.closed {
+		SendLatest(ft.resizes, size)
+	}
+}
+
+// ResizeChan returns a channel that receives the size of the terminal, once initially and then on every call to Resize().
+// When the receiver is slow, only the latest size is kept.
+// The channel is closed when the terminal is closed.
+func (ft *FakeTerminal) ResizeChan() <-chan WindowSize {
+	return ft.resizes
+}
+
+// record records a call to a method of the Terminal interface.
+// ft.m must be held by the caller.
+func (ft *FakeTerminal) record(name string) {
+	ft.calls = append(ft.calls, name)
+}
+
+func (ft *FakeTerminal) ReadWriteCloser() io.ReadWriteCloser {
+	return fakeReadWriteCloser{ft}
+}
+
+func (ft *FakeTerminal) Close() error {
+	ft.m.Lock()
+	defer ft.m.Unlock()
+
+	ft.record("Close")
+	ft.close()
+	return nil
+}
+
+// close closes the terminal.
+// ft.m must be held by the caller.
+func (ft *FakeTerminal) close() {
+	if ft.closed {
+		return
+	}
+	ft.closed = true
+
+	ft.input.Close()
+	ft.output.Close()
+	close(ft.resizes)
+}
+
+func (ft *FakeTerminal) IsTerminal() bool {
+	return true
+}
+
+func (ft *FakeTerminal) SetRawInput() error {
+	ft.m.Lock()
+	defer ft.m.Unlock()
+
+	ft.record("SetRawInput")
+	if ft.rawInput {
+		return nil
+	}
+
+	saved := ft.mode
+	ft.inSaved, ft.rawInput = &saved, true
+	ft.mode.Echo, ft.mode.Canonical, ft.mode.Signals = false, false, false
+	return nil
+}
+
+func (ft *FakeTerminal) RestoreInput() error {
+	ft.m.Lock()
+	defer ft.m.Unlock()
+
+	ft.record("RestoreInput")
+	if !ft.rawInput {
+		return nil
+	}
+
+	ft.mode.Echo, ft.mode.Canonical, ft.mode.Signals = ft.inSaved.Echo, ft.inSaved.Canonical, ft.inSaved.Signals
+	ft.inSaved, ft.rawInput = nil, false
+	return nil
+}
+
+func (ft *FakeTerminal) SetRawOutput() error {
+	ft.m.Lock()
+	defer ft.m.Unlock()
+
+	ft.record("SetRawOutput")
+	if ft.rawOutput {
+		return nil
+	}
+
+	saved := ft.mode
+	ft.outSaved, ft.rawOutput = &saved, true
+	ft.mode.OutputProcessing = false
+	return nil
+}
+
+func (ft *FakeTerminal) RestoreOutput() error {
+	ft.m.Lock()
+	defer ft.m.Unlock()
+
+	ft.record("RestoreOutput")
+	if !ft.rawOutput {
+		return nil
+	}
+
+	ft.mode.OutputProcessing = ft.outSaved.OutputProcessing
+	ft.outSaved, ft.rawOutput = nil, false
+	return nil
+}
+
+func (ft *FakeTerminal) GetSize() (*WindowSize, error) {
+	ft.m.Lock()
+	defer ft.m.Unlock()
+
+	size := ft.size
+	return &size, nil
+}
+
+func (ft *FakeTerminal) ResizeTo(size WindowSize) error {
+	ft.m.Lock()
+	defer ft.m.Unlock()
+
+	ft.record("ResizeTo")
+	ft.size = size
+	return nil
+}
+
+func (ft *FakeTerminal) GetMode() (*Mode, error) {
+	ft.m.Lock()
+	defer ft.m.Unlock()
+
+	mode := ft.mode
+	return &mode, nil
+}
+
+func (ft *FakeTerminal) SetMode(mode Mode) error {
+	ft.m.Lock()
+	defer ft.m.Unlock()
+
+	ft.record("SetMode")
+	ft.mode = mode
+	return nil
+}
+
+// fakeReadWriteCloser reads the input and writes the output of a FakeTerminal.
+type fakeReadWriteCloser struct {
+	ft *FakeTerminal
+}
+
+func (f fakeReadWriteCloser) Read(p []byte) (int, error) {
+	return f.ft.input.Read(p)
+}
+
+func (f fakeReadWriteCloser) Write(p []byte) (int, error) {
+	return f.ft.output.Write(p)
+}
+
+func (f fakeReadWriteCloser) Close() error {
+	f.ft.m.Lock()
+	defer f.ft.m.Unlock()
+
+	f.ft.close()
+	return nil
+}
+
+// fakePipe is an in-memory pipe with an unbounded buffer.
+type fakePipe struct {
+	m      sync.Mutex
+	cond   *sync.Cond
+	buffer bytes.Buffer
+	closed bool
+}
+
+func newFakePipe() *fakePipe {
+	fp := &fakePipe{}
+	fp.cond = sync.NewCond(&fp.m)
+	return fp
+}
+
+// Read reads buffered data, blocking until some is available.
+// Once the pipe is closed and all data has been read, returns io.EOF.
+func (fp *fakePipe) Read(p []byte) (int, error) {
+	fp.m.Lock()
+	defer fp.m.Unlock()
+
+	for fp.buffer.Len() == 0 && !fp.closed {
+		fp.cond.Wait()
+	}
+	if fp.buffer.Len() == 0 {
+		return 0, io.EOF
+	}
+	return fp.buffer.Read(p)
+}
+
+// Write buffers p, and never blocks.
+func (fp *fakePipe) Write(p []byte) (int, error) {
+	fp.m.Lock()
+	defer fp.m.Unlock()
+
+	if fp.closed {
+		return 0, io.ErrClosedPipe
+	}
+	fp.cond.Broadcast()
+	return fp.buffer.Write(p)
+}
+
+func (fp *fakePipe) Close() error {
+	fp.m.Lock()
+	defer fp.m.Unlock()
+
+	fp.closed = true
+	fp.cond.Broadcast()
+	return nil
+}
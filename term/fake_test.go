@@ -0,0 +1,59 @@
+package term
+
+import (
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestFakeTerminal(t *testing.T) {
+	ft := NewFakeTerminal(WindowSize{Height: 24, Width: 80})
+
+	// raw mode is recorded and reflected in the mode
+	ft.SetRawInput()
+	ft.SetRawOutput()
+	if input, output := ft.IsRaw(); !input || !output {
+		t.Errorf("IsRaw() = (%v, %v), want (true, true)", input, output)
+	}
+	if mode, _ := ft.GetMode(); mode.Echo || mode.OutputProcessing {
+		t.Errorf("GetMode() = %+v, want raw mode", *mode)
+	}
+	ft.RestoreOutput()
+	ft.RestoreInput()
+	if mode, _ := ft.GetMode(); *mode != DefaultFakeMode {
+		t.Errorf("GetMode() = %+v, want restored mode", *mode)
+	}
+
+	// prompts can be used on the fake terminal
+	io.WriteString(ft.Input(), "hello\r")
+	line, err := ReadLine(ft, "> ")
+	if line != "hello" || err != nil {
+		t.Errorf("ReadLine() = (%q, %v), want (\"hello\", nil)", line, err)
+	}
+
+	// scripted resizes are sent to the resize channel, latest first
+	ft.Resize(WindowSize{Height: 30, Width: 100})
+	ft.Resize(WindowSize{Height: 40, Width: 120})
+	if got := <-ft.ResizeChan(); got != (WindowSize{Height: 40, Width: 120}) {
+		t.Errorf("ResizeChan() received %v, want latest size", got)
+	}
+	if size, _ := ft.GetSize(); *size != (WindowSize{Height: 40, Width: 120}) {
+		t.Errorf("GetSize() = %v, want latest size", *size)
+	}
+
+	ft.Close()
+	if _, ok := <-ft.ResizeChan(); ok {
+		t.Error("ResizeChan() not closed after Close()")
+	}
+
+	output, _ := ioutil.ReadAll(ft.Output())
+	if string(output) != "> hello\r\n" {
+		t.Errorf("Output() = %q, want prompt and echoed line", output)
+	}
+
+	wantCalls := []string{"SetRawInput", "SetRawOutput", "RestoreOutput", "RestoreInput", "SetMode", "SetMode", "Close"}
+	if got := ft.Calls(); !reflect.DeepEqual(got, wantCalls) {
+		t.Errorf("Calls() = %v, want %v", got, wantCalls)
+	}
+}
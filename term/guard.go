@@ -0,0 +1,281 @@
+package term
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/tkw1536/procutil/term/lowlevel"
+)
+
+// The raw mode of terminals is tracked by a global registry, so that it can be restored when the process crashes.
+//
+// Each file descriptor is registered when it is first put into raw input or output mode.
+// The registry counts how often each mode has been requested, and only restores the original state of a file descriptor once every request has been undone.
+// This means that several Terminals referring to the same file descriptor can safely set and restore raw mode independently.
+// Terminals whose mode is changed temporarily in other ways, such as by ReadLine(), are registered along with the mode to restore.
+//
+// When installed using InstallSignalGuard(), and while any file descriptor is registered,
+// the signal guard restores all terminals when the process receives a fatal signal.
+// The signal is then re-raised, to be handled as if the guard had not been installed.
+// To restore terminals in other cases, see RestoreOnPanic() and Exit().
+
+// guardSignals are the signals that cause the guard to restore all terminals.
+var guardSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// registry holds all file descriptors that are currently in raw mode, and all terminals with a saved mode.
+var registry = struct {
+	sync.Mutex
+	fds       map[lowlevel.FileDescriptor]*rawFd
+	modes     map[*savedMode]struct{} // terminals to reset to a saved mode, see saveMode()
+	guards    int                     // number of calls to InstallSignalGuard() that have not been undone
+	stopGuard func()                  // stops the signal guard, nil if not running
+}{
+	fds:   make(map[lowlevel.FileDescriptor]*rawFd),
+	modes: make(map[*savedMode]struct{}),
+}
+
+// rawFd is the registry entry of a single file descriptor.
+type rawFd struct {
+	original      *lowlevel.TerminalState // the state before the first call to acquireRaw
+	input, output int                     // number of outstanding requests for each mode
+}
+
+// acquireRaw puts fd into raw input or output mode and registers it.
+// It returns the registry entry, to be passed to releaseRaw.
+func acquireRaw(fd lowlevel.FileDescriptor, output bool) (*rawFd, error) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	r := registry.fds[fd]
+	if r == nil {
+		original, err := lowlevel.SaveTerminal(fd)
+		if err != nil {
+			return nil, err
+		}
+		r = &rawFd{original: original}
+	}
+
+	count := r.count(output)
+	if *count == 0 {
+		if err := setRaw(fd, output); err != nil {
+			return nil, err
+		}
+	}
+	*count++
+
+	if _, ok := registry.fds[fd]; !ok {
+		registry.fds[fd] = r
+		updateGuard()
+	}
+	return r, nil
+}
+
+// releaseRaw undoes a single call to acquireRaw.
+// When r is no longer registered, for instance because RestoreAll() has been called, does nothing.
+func releaseRaw(fd lowlevel.FileDescriptor, r *rawFd, output bool) error {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if registry.fds[fd] != r {
+		return nil
+	}
+
+	count := r.count(output)
+	if *count == 0 {
+		return nil
+	}
+	*count--
+	if *count > 0 {
+		return nil
+	}
+
+	// restore the original state, and re-apply the mode that is still requested (if any)
+	err := lowlevel.ResetTerminal(fd, r.original)
+	switch {
+	case r.input > 0:
+		if rerr := setRaw(fd, false); err == nil {
+			err = rerr
+		}
+	case r.output > 0:
+		if rerr := setRaw(fd, true); err == nil {
+			err = rerr
+		}
+	default:
+		unregister(fd)
+	}
+	return err
+}
+
+// savedMode is a terminal along with the mode to reset it to.
+type savedMode struct {
+	t    Terminal
+	mode Mode
+}
+
+// saveMode registers t, so that RestoreAll() and the signal guard reset it to mode.
+// The returned function unregisters t again, without changing its mode.
+func saveMode(t Terminal, mode Mode) (unregister func()) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	saved := &savedMode{t: t, mode: mode}
+	registry.modes[saved] = struct{}{}
+	updateGuard()
+
+	return func() {
+		registry.Lock()
+		defer registry.Unlock()
+
+		if _, ok := registry.modes[saved]; ok {
+			delete(registry.modes, saved)
+			updateGuard()
+		}
+	}
+}
+
+// registered checks if r is the current registry entry of fd.
+func registered(fd lowlevel.FileDescriptor, r *rawFd) bool {
+	registry.Lock()
+	defer registry.Unlock()
+
+	return r != nil && registry.fds[fd] == r
+}
+
+func (r *rawFd) count(output bool) *int {
+	if output {
+		return &r.output
+	}
+	return &r.input
+}
+
+func setRaw(fd lowlevel.FileDescriptor, output bool) (err error) {
+	if output {
+		_, err = lowlevel.SetRawTerminalOutput(fd)
+	} else {
+		_, err = lowlevel.SetRawTerminal(fd)
+	}
+	return
+}
+
+// unregister removes fd from the registry, and stops the guard if no more file descriptors are registered.
+// registry must be locked by the caller.
+func unregister(fd lowlevel.FileDescriptor) {
+	delete(registry.fds, fd)
+	updateGuard()
+}
+
+// updateGuard starts or stops the signal guard, depending on if it is installed and any terminals are registered.
+// registry must be locked by the caller.
+func updateGuard() {
+	active := registry.guards > 0 && (len(registry.fds) > 0 || len(registry.modes) > 0)
+	switch {
+	case active && registry.stopGuard == nil:
+		registry.stopGuard = startGuard()
+	case !active && registry.stopGuard != nil:
+		registry.stopGuard()
+		registry.stopGuard = nil
+	}
+}
+
+// InstallSignalGuard installs a signal guard that restores all terminals in raw mode, or reading a prompt, when the process receives SIGINT, SIGTERM, SIGHUP or SIGQUIT.
+// The signal is then re-raised, to be handled as if the guard had not been installed.
+// Signals are only intercepted while any such terminal exists.
+//
+// The guard is not installed by default.
+// Programs that handle these signals themselves should not install it, and instead call RestoreAll() from their handlers.
+//
+// The returned function uninstalls the guard again.
+// When InstallSignalGuard() is called several times, the guard remains installed until every returned function has been called.
+func InstallSignalGuard() (uninstall func()) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.guards++
+	updateGuard()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			registry.Lock()
+			defer registry.Unlock()
+
+			registry.guards--
+			updateGuard()
+		})
+	}
+}
+
+// RestoreAll restores all terminals that have been put into raw mode by this package to their original state.
+// This includes terminals currently reading a prompt using ReadLine() or ReadPassword().
+// Subsequent calls to RestoreInput() or RestoreOutput() on any Terminal that was in raw mode do nothing.
+//
+// Returns the first error that occurs, but always attempts to restore every terminal.
+func RestoreAll() (err error) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	for fd, r := range registry.fds {
+		if rerr := lowlevel.ResetTerminal(fd, r.original); err == nil {
+			err = rerr
+		}
+		unregister(fd)
+	}
+	for saved := range registry.modes {
+		if rerr := saved.t.SetMode(saved.mode); err == nil {
+			err = rerr
+		}
+		delete(registry.modes, saved)
+	}
+	updateGuard()
+	return
+}
+
+// RestoreOnPanic restores all terminals when the calling goroutine panics, and then continues panicking.
+// It must be called directly using defer, typically at the start of main() and any long-running goroutine:
+//
+//	defer term.RestoreOnPanic()
+func RestoreOnPanic() {
+	if r := recover(); r != nil {
+		RestoreAll()
+		panic(r)
+	}
+}
+
+// Exit restores all terminals and then exits the current program using os.Exit(code).
+// It should be used instead of os.Exit() by programs that put a terminal into raw mode.
+func Exit(code int) {
+	RestoreAll()
+	os.Exit(code)
+}
+
+// startGuard starts restoring all terminals when a fatal signal is received.
+// The returned function stops the guard.
+func startGuard() (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, guardSignals...)
+
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			RestoreAll()
+			signal.Stop(signals)
+			raise(sig)
+		case <-stopped:
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(stopped)
+	}
+}
+
+// raise sends sig to the current process.
+func raise(sig os.Signal) {
+	if p, err := os.FindProcess(os.Getpid()); err == nil {
+		p.Signal(sig)
+	}
+}
@@ -0,0 +1,188 @@
+package term
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+// rawEcho checks if echo has been disabled on t.
+func rawEcho(t *testing.T, tm Terminal) bool {
+	mode, err := tm.GetMode()
+	if err != nil {
+		t.Fatalf("GetMode() returned %s", err)
+	}
+	return !mode.Echo
+}
+
+func TestRawRegistry(t *testing.T) {
+	if !PTYSupport {
+		t.Skip("OS not supported")
+	}
+
+	pty, tty, err := OpenTerminal()
+	if err != nil {
+		t.Fatal("OpenTerminal() returned error")
+	}
+	defer pty.Close()
+	defer tty.Close()
+
+	// two terminals referring to the same file descriptor
+	first := NewTerminal(tty.ReadWriteCloser())
+	second := NewTerminal(tty.ReadWriteCloser())
+
+	t.Run("nested raw input", func(t *testing.T) {
+		first.SetRawInput()
+		second.SetRawInput()
+
+		first.RestoreInput()
+		if !rawEcho(t, tty) {
+			t.Error("RestoreInput() restored terminal still in use")
+		}
+
+		second.RestoreInput()
+		if rawEcho(t, tty) {
+			t.Error("RestoreInput() did not restore terminal")
+		}
+	})
+
+	t.Run("raw input and output", func(t *testing.T) {
+		first.SetRawInput()
+		second.SetRawOutput()
+
+		second.RestoreOutput()
+		if !rawEcho(t, tty) {
+			t.Error("RestoreOutput() restored raw input")
+		}
+
+		first.RestoreInput()
+		if rawEcho(t, tty) {
+			t.Error("RestoreInput() did not restore terminal")
+		}
+	})
+
+	t.Run("RestoreAll", func(t *testing.T) {
+		first.SetRawInput()
+		second.SetRawInput()
+
+		if err := RestoreAll(); err != nil {
+			t.Errorf("RestoreAll() returned %s", err)
+		}
+		if rawEcho(t, tty) {
+			t.Error("RestoreAll() did not restore terminal")
+		}
+
+		// restoring afterwards should do nothing, even when the terminal is raw again.
+		second.SetRawInput()
+		first.RestoreInput()
+		if !rawEcho(t, tty) {
+			t.Error("RestoreInput() after RestoreAll() restored terminal")
+		}
+		second.RestoreInput()
+	})
+
+	t.Run("RestoreOnPanic", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("RestoreOnPanic() did not continue panicking")
+			}
+			if rawEcho(t, tty) {
+				t.Error("RestoreOnPanic() did not restore terminal")
+			}
+		}()
+
+		func() {
+			defer RestoreOnPanic()
+			first.SetRawInput()
+			panic("something went wrong")
+		}()
+	})
+	first.RestoreInput()
+}
+
+// Test that signals are only intercepted while the guard is installed and a terminal is in raw mode.
+func TestInstallSignalGuard(t *testing.T) {
+	if !PTYSupport {
+		t.Skip("OS not supported")
+	}
+
+	pty, tty, err := OpenTerminal()
+	if err != nil {
+		t.Fatal("OpenTerminal() returned error")
+	}
+	defer pty.Close()
+	defer tty.Close()
+
+	guarding := func() bool {
+		registry.Lock()
+		defer registry.Unlock()
+		return registry.stopGuard != nil
+	}
+
+	tty.SetRawInput()
+	if guarding() {
+		t.Error("guard is running without InstallSignalGuard()")
+	}
+
+	uninstall := InstallSignalGuard()
+	if !guarding() {
+		t.Error("guard is not running after InstallSignalGuard()")
+	}
+
+	tty.RestoreInput()
+	if guarding() {
+		t.Error("guard is running without a terminal in raw mode")
+	}
+
+	tty.SetRawInput()
+	uninstall()
+	uninstall()
+	if guarding() {
+		t.Error("guard is running after uninstalling it")
+	}
+	tty.RestoreInput()
+
+	registry.Lock()
+	defer registry.Unlock()
+	if registry.guards != 0 {
+		t.Errorf("guard installed %d times, want 0", registry.guards)
+	}
+}
+
+// Test that a terminal is restored when the process receives SIGTERM, once the guard has been installed.
+// We spawn a subprocess that kills itself while stdin is in raw mode.
+func TestRawRegistry_signal(t *testing.T) {
+	if !PTYSupport {
+		t.Skip("OS not supported")
+	}
+
+	if os.Getenv("BE_RAWTERM") == "1" {
+		InstallSignalGuard()
+		stdin := NewTerminal(os.Stdin)
+		if err := stdin.SetRawInput(); err != nil {
+			os.Exit(1)
+		}
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		select {}
+	}
+
+	pty, tty, err := OpenTerminal()
+	if err != nil {
+		t.Fatal("OpenTerminal() returned error")
+	}
+	defer pty.Close()
+	defer tty.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestRawRegistry_signal")
+	cmd.Env = append(os.Environ(), "BE_RAWTERM=1")
+	cmd.Stdin = tty.ReadWriteCloser().(*os.File)
+
+	err = cmd.Run()
+	if e, ok := err.(*exec.ExitError); !ok || e.ProcessState.Sys().(syscall.WaitStatus).Signal() != syscall.SIGTERM {
+		t.Fatalf("cmd.Run() returned %v, want to be killed by SIGTERM", err)
+	}
+	if rawEcho(t, tty) {
+		t.Error("terminal was not restored on SIGTERM")
+	}
+}
@@ -0,0 +1,64 @@
+package term
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/tkw1536/procutil/term/lowlevel"
+)
+
+// JobEvent is a change in the state of a child process, see WaitJobEvent().
+type JobEvent = lowlevel.JobEvent
+
+const (
+	// JobExited indicates that the process has exited.
+	JobExited = lowlevel.JobExited
+	// JobStopped indicates that the process has been stopped by a signal.
+	JobStopped = lowlevel.JobStopped
+	// JobContinued indicates that the process has been continued by SIGCONT.
+	JobContinued = lowlevel.JobContinued
+)
+
+// JobEventSupport indicates if the current operating system supports the WaitJobEvent() function
+const JobEventSupport = lowlevel.JobEventSupport
+
+// WaitJobEvent waits for the child process pid to exit, stop or continue.
+//
+// The process is not reaped when it exits, and should still be waited for using exec.Cmd.Wait().
+// When the process has already been reaped, returns an error.
+func WaitJobEvent(pid int) (JobEvent, error) {
+	return lowlevel.WaitJobEvent(pid)
+}
+
+var errNotASyscallSignal = errors.New("Terminal: Unsupported signal")
+
+// SignalGroup sends sig to every process in the process group pgid.
+func SignalGroup(pgid int, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return errNotASyscallSignal
+	}
+	return lowlevel.SignalGroup(pgid, s)
+}
+
+// SetNewProcessGroup configures cmd to start the process as the leader of a new process group.
+// The id of the group is then the pid of the process, and can be passed to SignalGroup().
+//
+// It should not be used for processes started on a terminal using ExecTerminal(), as these already lead their own group.
+func SetNewProcessGroup(cmd *exec.Cmd) error {
+	return lowlevel.SetNewProcessGroup(cmd)
+}
+
+// ForegroundGroup returns the id of the foreground process group of t.
+// This is the process group that receives signals generated by the keyboard, such as Ctrl-C and Ctrl-Z.
+//
+// When t is not a terminal, returns ErrNotATerminal.
+func ForegroundGroup(t Terminal) (pgid int, err error) {
+	fd, isTerminal := lowlevel.GetFdInfo(t.ReadWriteCloser())
+	if !isTerminal {
+		return 0, ErrNotATerminal
+	}
+	return lowlevel.GetForegroundGroup(fd)
+}
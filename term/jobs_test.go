@@ -0,0 +1,30 @@
+package term
+
+import (
+	"os/exec"
+	"testing"
+)
+
+// Test that a process started with ExecTerminal is the foreground process group of its' terminal.
+func TestForegroundGroup(t *testing.T) {
+	if !PTYSupport {
+		t.Skip("OS not supported")
+	}
+
+	cmd := exec.Command("sleep", "10")
+	pty, err := ExecTerminal(cmd)
+	if err != nil {
+		t.Fatal("ExecTerminal() returned error")
+	}
+	defer pty.Close()
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	pgid, err := ForegroundGroup(pty)
+	if err != nil {
+		t.Fatalf("ForegroundGroup() returned %s", err)
+	}
+	if pgid != cmd.Process.Pid {
+		t.Errorf("ForegroundGroup() = %d, want %d", pgid, cmd.Process.Pid)
+	}
+}
@@ -1,6 +1,8 @@
 package lowlevel
 
 import (
+	"os"
+
 	mobyterm "github.com/moby/term"
 )
 
@@ -9,5 +11,13 @@ type FileDescriptor = uintptr
 
 // GetFdInfo returns information about the terminal referred to by file.
 func GetFdInfo(file interface{}) (fd FileDescriptor, isTerminal bool) {
+	// calling Fd() on an *os.File puts it into blocking mode, so use the raw file descriptor where possible.
+	if f, ok := file.(*os.File); ok {
+		if raw, err := f.SyscallConn(); err == nil {
+			if err := raw.Control(func(rawFd uintptr) { fd = rawFd }); err == nil {
+				return fd, mobyterm.IsTerminal(fd)
+			}
+		}
+	}
 	return mobyterm.GetFdInfo(file)
 }
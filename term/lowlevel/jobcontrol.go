@@ -0,0 +1,13 @@
+package lowlevel
+
+// JobEvent is a change in the state of a child process.
+type JobEvent int
+
+const (
+	// JobExited indicates that the process has exited.
+	JobExited JobEvent = iota
+	// JobStopped indicates that the process has been stopped by a signal.
+	JobStopped
+	// JobContinued indicates that the process has been continued by SIGCONT.
+	JobContinued
+)
@@ -0,0 +1,11 @@
+// +build !linux !amd64,!arm64,!ppc64,!ppc64le,!riscv64,!s390x
+
+package lowlevel
+
+// JobEventSupport indicates if the current platform supports the WaitJobEvent() function
+const JobEventSupport = false
+
+// WaitJobEvent waits for the child process pid to exit, stop or continue.
+func WaitJobEvent(pid int) (JobEvent, error) {
+	return JobExited, ErrOSUnsupported
+}
@@ -0,0 +1,83 @@
+// +build linux,amd64 linux,arm64 linux,ppc64 linux,ppc64le linux,riscv64 linux,s390x
+
+package lowlevel
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// JobEventSupport indicates if the current platform supports the WaitJobEvent() function
+const JobEventSupport = true
+
+// constants for waitid(2), not all of which are provided by the unix package
+const (
+	waitidPPid = 1
+
+	cldExited    = 1
+	cldKilled    = 2
+	cldDumped    = 3
+	cldTrapped   = 4
+	cldStopped   = 5
+	cldContinued = 6
+)
+
+// waitidInfo is the part of siginfo_t filled in by waitid(2).
+// The layout matches 64-bit platforms, where the union is aligned to 8 bytes.
+type waitidInfo struct {
+	Signo  int32
+	Errno  int32
+	Code   int32
+	_      int32
+	Pid    int32
+	Uid    uint32
+	Status int32
+	_      [100]byte // remainder of the 128-byte siginfo_t
+}
+
+func waitid(pid int, options int) (info waitidInfo, err error) {
+	for {
+		_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, waitidPPid, uintptr(pid), uintptr(unsafe.Pointer(&info)), uintptr(options), 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return info, errno
+		}
+		return info, nil
+	}
+}
+
+// WaitJobEvent waits for the child process pid to exit, stop or continue.
+//
+// Exit events are not consumed, meaning the process is not reaped and can still be waited for using exec.Cmd.Wait().
+// Once the process has been reaped, returns an error.
+func WaitJobEvent(pid int) (JobEvent, error) {
+	for {
+		// wait for any event, but leave it in a waitable state
+		info, err := waitid(pid, unix.WEXITED|unix.WSTOPPED|unix.WCONTINUED|unix.WNOWAIT)
+		if err != nil {
+			return JobExited, err
+		}
+
+		switch info.Code {
+		case cldExited, cldKilled, cldDumped:
+			return JobExited, nil
+		case cldStopped, cldTrapped, cldContinued:
+			// consume the stop or continue event, so that it is not reported again.
+			// this never reaps the process, as WEXITED is not passed.
+			info, err = waitid(pid, unix.WSTOPPED|unix.WCONTINUED|unix.WNOHANG)
+			if err != nil {
+				return JobExited, err
+			}
+			switch info.Code {
+			case cldStopped, cldTrapped:
+				return JobStopped, nil
+			case cldContinued:
+				return JobContinued, nil
+			}
+		}
+	}
+}
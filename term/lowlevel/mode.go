@@ -0,0 +1,12 @@
+package lowlevel
+
+// Mode describes the portable subset of the mode of a terminal.
+type Mode struct {
+	Echo             bool // echo input characters (ECHO)
+	Canonical        bool // read input line by line, and process erase and kill characters (ICANON)
+	Signals          bool // generate signals for the interrupt, quit and suspend characters (ISIG)
+	OutputProcessing bool // process output, e.g. translate "\n" to "\r\n" (OPOST)
+
+	// Special characters of the terminal.
+	Interrupt, Quit, Erase, Kill, EOF, Suspend byte
+}
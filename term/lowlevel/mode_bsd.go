@@ -0,0 +1,8 @@
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package lowlevel
+
+import "golang.org/x/sys/unix"
+
+const ioctlGetTermios = unix.TIOCGETA
+const ioctlSetTermios = unix.TIOCSETA
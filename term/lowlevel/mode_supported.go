@@ -0,0 +1,69 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package lowlevel
+
+import "golang.org/x/sys/unix"
+
+// GetMode gets the mode of the terminal referred to by the provided file descriptor.
+func GetMode(fd FileDescriptor) (*Mode, error) {
+	termios, err := unix.IoctlGetTermios(int(fd), ioctlGetTermios)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Mode{
+		Echo:             termios.Lflag&unix.ECHO != 0,
+		Canonical:        termios.Lflag&unix.ICANON != 0,
+		Signals:          termios.Lflag&unix.ISIG != 0,
+		OutputProcessing: termios.Oflag&unix.OPOST != 0,
+
+		Interrupt: termios.Cc[unix.VINTR],
+		Quit:      termios.Cc[unix.VQUIT],
+		Erase:     termios.Cc[unix.VERASE],
+		Kill:      termios.Cc[unix.VKILL],
+		EOF:       termios.Cc[unix.VEOF],
+		Suspend:   termios.Cc[unix.VSUSP],
+	}, nil
+}
+
+// SetMode sets the mode of the terminal referred to by the provided file descriptor.
+// Other settings of the terminal are left unchanged.
+//
+// When canonical mode is disabled, reads are configured to return as soon as a single byte is available.
+func SetMode(fd FileDescriptor, mode *Mode) error {
+	termios, err := unix.IoctlGetTermios(int(fd), ioctlGetTermios)
+	if err != nil {
+		return err
+	}
+
+	// flag types differ between platforms, so this can not use a helper function
+	termios.Lflag &^= unix.ECHO | unix.ICANON | unix.ISIG
+	if mode.Echo {
+		termios.Lflag |= unix.ECHO
+	}
+	if mode.Canonical {
+		termios.Lflag |= unix.ICANON
+	}
+	if mode.Signals {
+		termios.Lflag |= unix.ISIG
+	}
+
+	termios.Oflag &^= unix.OPOST
+	if mode.OutputProcessing {
+		termios.Oflag |= unix.OPOST
+	}
+
+	termios.Cc[unix.VINTR] = mode.Interrupt
+	termios.Cc[unix.VQUIT] = mode.Quit
+	termios.Cc[unix.VERASE] = mode.Erase
+	termios.Cc[unix.VKILL] = mode.Kill
+	termios.Cc[unix.VEOF] = mode.EOF
+	termios.Cc[unix.VSUSP] = mode.Suspend
+
+	if !mode.Canonical {
+		termios.Cc[unix.VMIN] = 1
+		termios.Cc[unix.VTIME] = 0
+	}
+
+	return unix.IoctlSetTermios(int(fd), ioctlSetTermios, termios)
+}
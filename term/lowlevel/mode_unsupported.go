@@ -0,0 +1,13 @@
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package lowlevel
+
+// GetMode gets the mode of the terminal referred to by the provided file descriptor.
+func GetMode(fd FileDescriptor) (*Mode, error) {
+	return nil, ErrOSUnsupported
+}
+
+// SetMode sets the mode of the terminal referred to by the provided file descriptor.
+func SetMode(fd FileDescriptor, mode *Mode) error {
+	return ErrOSUnsupported
+}
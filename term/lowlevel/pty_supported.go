@@ -5,6 +5,7 @@ package lowlevel
 import (
 	"os"
 	"os/exec"
+	"syscall"
 
 	creackpty "github.com/creack/pty"
 )
@@ -13,11 +14,71 @@ import (
 const PTYSupport = true // platform is supported
 
 // OpenPty opens a new tty and returns the corresponding (tty, pty) file descriptors.
+//
+// The second file descriptor, the end used as terminal by a process, is in non-blocking mode.
+// This means that closing it interrupts any pending read or write.
+// The first one remains in blocking mode, as a non-blocking read of it may miss output written just before the other end is closed.
 func OpenPty() (tty, pty *os.File, err error) {
-	return creackpty.Open()
+	tty, pty, err = creackpty.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if pty, err = nonBlocking(pty); err != nil {
+		tty.Close()
+		return nil, nil, err
+	}
+	return tty, pty, nil
+}
+
+// nonBlocking returns a copy of file in non-blocking mode, and closes file.
+//
+// A new file is needed, as the runtime only polls files that are non-blocking when created.
+// Calling Fd() on the returned file puts it back into blocking mode.
+func nonBlocking(file *os.File) (*os.File, error) {
+	defer file.Close()
+
+	fd, err := syscall.Dup(int(file.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), file.Name()), nil
 }
 
 // StartOnPty starts c on a new pty and returns a file descriptor describing it.
-func StartOnPty(c *exec.Cmd) (fd *os.File, err error) {
-	return creackpty.Start(c)
+// When size is not nil, the pty is resized to size before c is started.
+//
+// The tty is used for each of the standard streams of c that is nil.
+// c is started in a new session, with the tty as controlling terminal.
+// This makes the process group of c the foreground process group of the tty, allowing c to perform job control.
+// When none of the streams of c is the tty, the controlling terminal is not set.
+func StartOnPty(c *exec.Cmd, size *Winsize) (fd *os.File, err error) {
+	attrs := &syscall.SysProcAttr{}
+	if c.SysProcAttr != nil {
+		*attrs = *c.SysProcAttr
+	}
+	attrs.Setsid = true
+
+	// the controlling terminal is given as a file descriptor in the child
+	switch {
+	case c.Stdin == nil:
+		attrs.Setctty, attrs.Ctty = true, 0
+	case c.Stdout == nil:
+		attrs.Setctty, attrs.Ctty = true, 1
+	case c.Stderr == nil:
+		attrs.Setctty, attrs.Ctty = true, 2
+	default:
+		attrs.Setctty = false
+	}
+
+	var sz *creackpty.Winsize
+	if size != nil {
+		sz = &creackpty.Winsize{Rows: size.Height, Cols: size.Width, X: size.XPixel, Y: size.YPixel}
+	}
+
+	return creackpty.StartWithAttrs(c, sz, attrs)
 }
@@ -16,6 +16,7 @@ func OpenPty() (tty, pty *os.File, err error) {
 }
 
 // StartOnPty starts c on a new pty and returns a file descriptor describing it.
-func StartOnPty(c *exec.Cmd) (fd *os.File, err error) {
+// When size is not nil, the pty is resized to size before c is started.
+func StartOnPty(c *exec.Cmd, size *Winsize) (fd *os.File, err error) {
 	return nil, ErrWindowsUnsupported
 }
@@ -9,10 +9,24 @@ type TerminalState struct {
 	state mobyterm.State
 }
 
+// SaveTerminal returns the current state of the terminal referred to by fd for use by ResetTerminal.
+func SaveTerminal(fd FileDescriptor) (state *TerminalState, err error) {
+	var s *mobyterm.State
+	s, err = mobyterm.SaveState(fd)
+	if s != nil {
+		state = &TerminalState{
+			state: *s,
+		}
+	}
+	return
+}
+
 // SetRawTerminal sets the terminal referred to by fd into raw mode and returns it's previous state for use by ResetTerminal.
+//
+// Unlike the upstream function, this does not install a handler for interrupts.
 func SetRawTerminal(fd FileDescriptor) (state *TerminalState, err error) {
 	var s *mobyterm.State
-	s, err = mobyterm.SetRawTerminal(fd)
+	s, err = mobyterm.MakeRaw(fd)
 	if s != nil {
 		state = &TerminalState{
 			state: *s,
@@ -5,40 +5,49 @@ package lowlevel
 import (
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 )
 
 // WindowResize returns a channel that receives every time the current terminal window is resized.
-// When initial is true, it will additionally receive at some point after the function has returned.
+// When initial is true, it additionally receives once immediately.
 //
-// In addition this function also returns a function cleanup that can be used to close the channel notify.
+// Resize events are coalesced; when the receiver is slow, multiple resizes may only be received once.
+// The channel is closed once cleanup has been called.
 func WindowResize(initial bool) (onResize <-chan struct{}, cleanup func(), err error) {
 	c := make(chan struct{}, 1)
+	if initial {
+		c <- struct{}{}
+	}
 
-	// make a channel for signals and trigger the initial size
+	// make a channel for signals
 	osSigC := make(chan os.Signal, 1)
 	signal.Notify(osSigC, syscall.SIGWINCH)
 
-	// every time we get a signal send a trigger
+	// every time we get a signal send a trigger, unless one is still pending
+	stop := make(chan struct{})
 	go func() {
-		for range osSigC {
-			c <- struct{}{}
+		defer close(c)
+		for {
+			select {
+			case <-osSigC:
+				select {
+				case c <- struct{}{}:
+				default:
+				}
+			case <-stop:
+				return
+			}
 		}
 	}()
 
-	onResize = c
+	var cleanupOnce sync.Once
 	cleanup = func() {
-		signal.Reset(syscall.SIGWINCH)
-		close(c)
-	}
-
-	// send initial signal when set
-	if initial {
-		go func() {
-			defer func() { recover() }() // don't care about closing of the channel.
-			osSigC <- syscall.SIGWINCH
-		}()
+		cleanupOnce.Do(func() {
+			signal.Stop(osSigC)
+			close(stop)
+		})
 	}
 
-	return
+	return c, cleanup, nil
 }
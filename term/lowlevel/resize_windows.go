@@ -2,22 +2,23 @@
 
 package lowlevel
 
+import "sync"
+
 // TODO: At the moment most of these functions return an error on windows.
 
 // WindowResize returns a channel that receives every time the current terminal window is resized.
-// When initial is true, it will additionally receive at some point after the function has returned.
+// When initial is true, it additionally receives once immediately.
 //
-// In addition this function also returns a function cleanup that can be used to close the channel notify.
+// Resize events are coalesced; when the receiver is slow, multiple resizes may only be received once.
+// The channel is closed once cleanup has been called.
 func WindowResize(initial bool) (onResize <-chan struct{}, cleanup func(), err error) {
-	c := make(chan struct{})
+	c := make(chan struct{}, 1)
 
 	// on windows, only send an initial signal and do not listen to resize events (for now!)
 	if initial {
-		go func() {
-			defer func() { recover() }() // don't care about closing the channel.
-			c <- struct{}{}
-		}()
+		c <- struct{}{}
 	}
 
-	return c, func() { close(c) }, nil
+	var cleanupOnce sync.Once
+	return c, func() { cleanupOnce.Do(func() { close(c) }) }, nil
 }
@@ -0,0 +1,29 @@
+// +build !windows
+
+package lowlevel
+
+import (
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// SignalGroup sends sig to all processes in the process group pgid.
+func SignalGroup(pgid int, sig syscall.Signal) error {
+	return syscall.Kill(-pgid, sig)
+}
+
+// SetNewProcessGroup configures cmd to start the process as the leader of a new process group.
+func SetNewProcessGroup(cmd *exec.Cmd) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	return nil
+}
+
+// GetForegroundGroup returns the id of the foreground process group of the terminal referred to by fd.
+func GetForegroundGroup(fd FileDescriptor) (pgid int, err error) {
+	return unix.IoctlGetInt(int(fd), unix.TIOCGPGRP)
+}
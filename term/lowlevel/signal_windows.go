@@ -0,0 +1,23 @@
+// +build windows
+
+package lowlevel
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// SignalGroup sends sig to all processes in the process group pgid.
+func SignalGroup(pgid int, sig syscall.Signal) error {
+	return ErrOSUnsupported
+}
+
+// SetNewProcessGroup configures cmd to start the process as the leader of a new process group.
+func SetNewProcessGroup(cmd *exec.Cmd) error {
+	return ErrOSUnsupported
+}
+
+// GetForegroundGroup returns the id of the foreground process group of the terminal referred to by fd.
+func GetForegroundGroup(fd FileDescriptor) (pgid int, err error) {
+	return 0, ErrOSUnsupported
+}
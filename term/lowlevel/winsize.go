@@ -0,0 +1,11 @@
+package lowlevel
+
+// Size is an os-specific alias for dimensions of a terminal.
+// It is guaranteed to be some integer type.
+type Size = uint16
+
+// Winsize is the size of a terminal window.
+type Winsize struct {
+	Height, Width  Size // in characters
+	XPixel, YPixel Size // in pixels, zero when unknown
+}
@@ -0,0 +1,29 @@
+// +build !windows
+
+package lowlevel
+
+import "golang.org/x/sys/unix"
+
+// GetWinsize gets the window size of the terminal referred to by the provided file descriptor.
+func GetWinsize(fd FileDescriptor) (*Winsize, error) {
+	ws, err := unix.IoctlGetWinsize(int(fd), unix.TIOCGWINSZ)
+	if err != nil {
+		return nil, err
+	}
+	return &Winsize{
+		Height: ws.Row,
+		Width:  ws.Col,
+		XPixel: ws.Xpixel,
+		YPixel: ws.Ypixel,
+	}, nil
+}
+
+// SetWinsize sets the window size of the terminal referred to by the provided file descriptor.
+func SetWinsize(fd FileDescriptor, size *Winsize) error {
+	return unix.IoctlSetWinsize(int(fd), unix.TIOCSWINSZ, &unix.Winsize{
+		Row:    size.Height,
+		Col:    size.Width,
+		Xpixel: size.XPixel,
+		Ypixel: size.YPixel,
+	})
+}
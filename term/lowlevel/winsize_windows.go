@@ -0,0 +1,19 @@
+// +build windows
+
+package lowlevel
+
+import mobyterm "github.com/moby/term"
+
+// GetWinsize gets the window size of the terminal referred to by the provided file descriptor.
+func GetWinsize(fd FileDescriptor) (*Winsize, error) {
+	size, err := mobyterm.GetWinsize(fd)
+	if err != nil {
+		return nil, err
+	}
+	return &Winsize{Height: size.Height, Width: size.Width}, nil
+}
+
+// SetWinsize sets the window size of the terminal referred to by the provided file descriptor.
+func SetWinsize(fd FileDescriptor, size *Winsize) error {
+	return ErrOSUnsupported
+}
@@ -0,0 +1,47 @@
+package term
+
+import "github.com/tkw1536/procutil/term/lowlevel"
+
+// Mode describes the portable subset of the mode of a terminal.
+// It can be read and changed using the GetMode() and SetMode() methods of a Terminal.
+type Mode = lowlevel.Mode
+
+// UpdateMode reads the mode of t, calls update to modify it, and then sets the modified mode on t.
+func UpdateMode(t Terminal, update func(mode *Mode)) error {
+	mode, err := t.GetMode()
+	if err != nil {
+		return err
+	}
+	update(mode)
+	return t.SetMode(*mode)
+}
+
+// SetEcho enables or disables echoing of input characters on t.
+func SetEcho(t Terminal, echo bool) error {
+	return UpdateMode(t, func(mode *Mode) { mode.Echo = echo })
+}
+
+// SetCanonical enables or disables canonical (line-by-line) input on t.
+func SetCanonical(t Terminal, canonical bool) error {
+	return UpdateMode(t, func(mode *Mode) { mode.Canonical = canonical })
+}
+
+// SetSignals enables or disables generation of signals for special characters on t.
+func SetSignals(t Terminal, signals bool) error {
+	return UpdateMode(t, func(mode *Mode) { mode.Signals = signals })
+}
+
+// SetOutputProcessing enables or disables post-processing of output on t.
+func SetOutputProcessing(t Terminal, processing bool) error {
+	return UpdateMode(t, func(mode *Mode) { mode.OutputProcessing = processing })
+}
+
+// SetCbreak puts t into cbreak mode.
+// In cbreak mode input is available character by character and not echoed, but signals are still generated.
+func SetCbreak(t Terminal) error {
+	return UpdateMode(t, func(mode *Mode) {
+		mode.Echo = false
+		mode.Canonical = false
+		mode.Signals = true
+	})
+}
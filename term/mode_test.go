@@ -0,0 +1,71 @@
+package term
+
+import "testing"
+
+func TestTerminal_Mode(t *testing.T) {
+	t.Run("nil non-terminal", func(t *testing.T) {
+		term := NewTerminal(nil)
+
+		if _, err := term.GetMode(); err != ErrNotATerminal {
+			t.Error("GetMode() is not ErrNotATerminal")
+		}
+		if err := term.SetMode(Mode{}); err != ErrNotATerminal {
+			t.Error("SetMode() is not ErrNotATerminal")
+		}
+		if err := SetEcho(term, false); err != ErrNotATerminal {
+			t.Error("SetEcho() is not ErrNotATerminal")
+		}
+	})
+
+	t.Run("real terminal", func(t *testing.T) {
+		if !PTYSupport {
+			t.Skip("OS not supported")
+		}
+
+		pty, term, err := OpenTerminal()
+		if err != nil {
+			t.Fatal("OpenTerminal() returned error")
+		}
+		defer pty.Close()
+		defer term.Close()
+
+		mode, err := term.GetMode()
+		if err != nil {
+			t.Fatalf("GetMode() returned %s", err)
+		}
+		if !mode.Echo || !mode.Canonical || !mode.Signals {
+			t.Errorf("GetMode() = %+v, want echo, canonical and signals to be enabled", mode)
+		}
+
+		tests := []struct {
+			name   string
+			update func(t Terminal) error
+			want   func(mode Mode) Mode
+		}{
+			{"disable echo", func(t Terminal) error { return SetEcho(t, false) }, func(m Mode) Mode { m.Echo = false; return m }},
+			{"disable canonical", func(t Terminal) error { return SetCanonical(t, false) }, func(m Mode) Mode { m.Canonical = false; return m }},
+			{"disable signals", func(t Terminal) error { return SetSignals(t, false) }, func(m Mode) Mode { m.Signals = false; return m }},
+			{"disable output processing", func(t Terminal) error { return SetOutputProcessing(t, false) }, func(m Mode) Mode { m.OutputProcessing = false; return m }},
+			{"cbreak", SetCbreak, func(m Mode) Mode { m.Echo, m.Canonical, m.Signals = false, false, true; return m }},
+			{"set special characters", func(t Terminal) error {
+				return UpdateMode(t, func(mode *Mode) { mode.Interrupt, mode.EOF, mode.Erase = 'x', 'y', 'z' })
+			}, func(m Mode) Mode { m.Interrupt, m.EOF, m.Erase = 'x', 'y', 'z'; return m }},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				defer term.SetMode(*mode)
+
+				if err := tt.update(term); err != nil {
+					t.Fatalf("update returned %s", err)
+				}
+				got, err := term.GetMode()
+				if err != nil {
+					t.Fatalf("GetMode() returned %s", err)
+				}
+				if want := tt.want(*mode); *got != want {
+					t.Errorf("GetMode() = %+v, want %+v", *got, want)
+				}
+			})
+		}
+	})
+}
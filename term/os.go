@@ -1,7 +1,6 @@
 package term
 
 import (
-	"os"
 	"os/exec"
 
 	"github.com/tkw1536/procutil/term/lowlevel"
@@ -13,67 +12,63 @@ const PTYSupport = lowlevel.PTYSupport
 // ExecTerminal starts c on a new pty.
 // The user should close pty when finished.
 func ExecTerminal(c *exec.Cmd) (pty Terminal, err error) {
-	fd, err := lowlevel.StartOnPty(c)
-	return NewTerminal(fd), err
+	return ExecTerminalWithSize(c, nil)
 }
 
-// GetStdTerminal returns information about the terminal represented by os.Stdout and puts it's input and output in raw mode.
-// When os.Stdout is not a terminal, does nothing.
-func GetStdTerminal() (term Terminal, TERM string, resizeChan <-chan WindowSize, cleanup func(), err error) {
-	term = NewTerminal(os.Stdout)
-	cleanup = func() {}
-	if !term.IsTerminal() { // if we didn't receive a terminal, exit
-		term = nil
-		return
+// ExecTerminalWithSize is like ExecTerminal, but resizes the pty to size before starting c.
+// This ensures that c sees the correct size immediately.
+// When size is nil, the pty is not resized.
+func ExecTerminalWithSize(c *exec.Cmd, size *WindowSize) (pty Terminal, err error) {
+	var ws *lowlevel.Winsize
+	if size != nil {
+		ws = size.winsize()
 	}
 
-	err = term.SetRawInput()
-	if err != nil {
-		return
-	}
+	fd, err := lowlevel.StartOnPty(c, ws)
+	return NewTerminal(fd), err
+}
 
-	err = term.SetRawOutput()
-	if err != nil {
-		term.RestoreInput() // restore input which we may have broken
-		return
-	}
+// GetStdTerminal returns information about the terminal represented by os.Stdout and puts os.Stdin and os.Stdout in raw mode.
+// When os.Stdout is not a terminal, does nothing and returns a nil term.
+//
+// Use OpenStdTerminal to also handle the case where os.Stdin is not a terminal, or to access os.Stdin directly.
+func GetStdTerminal() (term Terminal, TERM string, resizeChan <-chan WindowSize, cleanup func(), err error) {
+	cleanup = func() {}
 
-	var resizeCleanup func()
-	resizeChan, resizeCleanup, err = monitorSize(term)
-	if err != nil {
-		// restore input and ouput to preven breakage
-		term.RestoreInput()
-		term.RestoreOutput()
+	std, err := OpenStdTerminal()
+	if err != nil || !std.IsTerminal() {
 		return
 	}
 
-	TERM = os.Getenv("TERM")
-
-	cleanup = func() {
-		term.RestoreInput()
-		term.RestoreOutput()
-		resizeCleanup()
-	}
-
-	return
+	cleanup = func() { std.Restore() }
+	return std.Stdout, std.TERM, std.Resize, cleanup, nil
 }
 
+// monitorSize returns a channel that receives the size of term, once initially and then whenever it changes.
+// The initial size is available as soon as monitorSize returns.
+//
+// When the receiver is slow, intermediate sizes are dropped; the latest size is always received.
+// The channel is closed once cleanup is called.
 func monitorSize(term Terminal) (ws <-chan WindowSize, cleanup func(), err error) {
-	// send the window size every time we get a resize event
-	wsc := make(chan WindowSize, 1)
-	onResize, cleanup, err := lowlevel.WindowResize(true)
+	onResize, cleanup, err := lowlevel.WindowResize(false)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// whenver we get a signal, get the current terminal size, and return it!
+	wsc := make(chan WindowSize, 1)
+	if size, err := term.GetSize(); err == nil {
+		wsc <- *size
+	}
+
+	// whenver we get a signal, get the current terminal size, and send it!
 	go func() {
+		defer close(wsc)
 		for range onResize {
 			size, err := term.GetSize()
 			if err != nil || size == nil {
 				continue
 			}
-			wsc <- *size
+			SendLatest(wsc, *size)
 		}
 	}()
 
@@ -95,3 +95,78 @@ func TestGetStdTerminal(t *testing.T) {
 		t.Fatalf("GetStdTerminal(): expected status 0 but got %v", err)
 	})
 }
+
+func CheckStdTerminal() {
+	os.Exit(func() (code int) {
+		std, err := OpenStdTerminal()
+		if err != nil {
+			return 1 << 4 // error
+		}
+		defer std.Restore()
+
+		if std.Stdin.IsTerminal() {
+			code |= 1 << 0
+		}
+		if std.Stdout.IsTerminal() {
+			code |= 1 << 1
+		}
+		if std.Resize != nil {
+			code |= 1 << 2
+		}
+		if mode, err := std.Stdin.GetMode(); err == nil && !mode.Echo {
+			code |= 1 << 3
+		}
+		return code
+	}())
+}
+
+// Test that OpenStdTerminal() handles stdin and stdout separately.
+// We spawn a subprocess, and have that report the state of its' terminal using its' exit code.
+func TestOpenStdTerminal(t *testing.T) {
+	if !PTYSupport {
+		t.Skip("OS not supported")
+	}
+
+	if os.Getenv("BE_STDTERM") == "2" {
+		CheckStdTerminal()
+		return
+	}
+
+	pty, tty, err := OpenTerminal()
+	if err != nil {
+		t.Fatal("OpenTerminal() returned error")
+	}
+	defer pty.Close()
+	defer tty.Close()
+	ttyFile := tty.ReadWriteCloser().(*os.File)
+
+	tests := []struct {
+		name     string
+		setup    func(cmd *exec.Cmd)
+		wantCode int
+	}{
+		{"no terminal", func(cmd *exec.Cmd) {}, 0},
+		{"stdout only", func(cmd *exec.Cmd) { cmd.Stdout = ttyFile }, 1<<1 | 1<<2},
+		{"stdin only", func(cmd *exec.Cmd) { cmd.Stdin = ttyFile }, 1 << 0},
+		{"stdin and stdout", func(cmd *exec.Cmd) { cmd.Stdin, cmd.Stdout = ttyFile, ttyFile }, 1<<0 | 1<<1 | 1<<2 | 1<<3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=TestOpenStdTerminal")
+			cmd.Env = append(os.Environ(), "BE_STDTERM=2")
+			tt.setup(cmd)
+
+			code := 0
+			if err := cmd.Run(); err != nil {
+				e, ok := err.(*exec.ExitError)
+				if !ok {
+					t.Fatalf("cmd.Run() returned %s", err)
+				}
+				code = e.ProcessState.ExitCode()
+			}
+			if code != tt.wantCode {
+				t.Errorf("OpenStdTerminal(): got status %b, want %b", code, tt.wantCode)
+			}
+		})
+	}
+}
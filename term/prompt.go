@@ -0,0 +1,169 @@
+package term
+
+import (
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+// ErrInterrupted is returned when the user interrupts a prompt, typically by pressing Ctrl-C.
+var ErrInterrupted = errors.New("Terminal: Interrupted")
+
+// ReadPassword writes prompt to t and then reads a line of input without echoing it.
+// See ReadLine for supported editing keys.
+func ReadPassword(t Terminal, prompt string) ([]byte, error) {
+	return readLine(t, prompt, false)
+}
+
+// ReadLine writes prompt to t and then reads a line of input.
+//
+// While reading, t is put into raw mode and the following editing keys are supported:
+// Backspace deletes the last character, Ctrl-U deletes the entire line and Ctrl-W deletes the last word.
+// Escape sequences, such as those generated by arrow keys, are ignored.
+// Ctrl-C returns ErrInterrupted, and Ctrl-D on an empty line returns io.EOF.
+//
+// The mode of t is always restored before returning, even when the function panics.
+// While reading, t is also restored by RestoreAll() and, when installed, the signal guard; see InstallSignalGuard().
+//
+// When t is not a terminal, returns ErrNotATerminal.
+func ReadLine(t Terminal, prompt string) (string, error) {
+	line, err := readLine(t, prompt, true)
+	return string(line), err
+}
+
+func readLine(t Terminal, prompt string, echo bool) (line []byte, err error) {
+	mode, err := t.GetMode()
+	if err != nil {
+		return nil, err
+	}
+
+	// restore the original mode when returning, or along with all other terminals
+	unregister := saveMode(t, *mode)
+	defer func() {
+		unregister()
+		if rerr := t.SetMode(*mode); err == nil {
+			err = rerr
+		}
+	}()
+
+	raw := *mode
+	raw.Echo, raw.Canonical, raw.Signals = false, false, false
+	if err := t.SetMode(raw); err != nil {
+		return nil, err
+	}
+
+	rw := t.ReadWriteCloser()
+	if _, err := io.WriteString(rw, prompt); err != nil {
+		return nil, err
+	}
+
+	// read a single byte at a time, to not consume input after the end of the line
+	editor := lineEditor{w: rw, echo: echo}
+	buffer := make([]byte, 1)
+	for {
+		n, err := rw.Read(buffer)
+		for _, b := range buffer[:n] {
+			done, err := editor.process(b)
+			if done || err != nil {
+				return editor.line, err
+			}
+		}
+
+		if err != nil {
+			if err == io.EOF && len(editor.line) > 0 {
+				err = nil
+			}
+			return editor.line, err
+		}
+	}
+}
+
+// lineEditor implements basic line editing.
+type lineEditor struct {
+	w    io.Writer
+	echo bool
+
+	line   []byte
+	escape int // 0 = no escape, 1 = after ESC, 2 = inside escape sequence
+}
+
+// process processes a single byte of input.
+// done indicates if a line has been read completely.
+func (le *lineEditor) process(b byte) (done bool, err error) {
+	switch le.escape {
+	case 1:
+		le.escape = 0
+		if b == '[' || b == 'O' {
+			le.escape = 2
+		}
+		return false, nil
+	case 2:
+		if b >= 0x40 && b <= 0x7e {
+			le.escape = 0
+		}
+		return false, nil
+	}
+
+	switch b {
+	case '\r', '\n':
+		le.write([]byte("\r\n"), true)
+		return true, nil
+	case 0x03: // Ctrl-C
+		le.write([]byte("^C\r\n"), true)
+		return true, ErrInterrupted
+	case 0x04: // Ctrl-D
+		if len(le.line) == 0 {
+			return true, io.EOF
+		}
+	case 0x7f, 0x08: // Backspace
+		le.erase(le.lastRune())
+	case 0x15: // Ctrl-U
+		le.erase(len(le.line))
+	case 0x17: // Ctrl-W
+		le.erase(le.lastWord())
+	case 0x1b: // ESC
+		le.escape = 1
+	default:
+		if b < 0x20 {
+			return false, nil
+		}
+		le.line = append(le.line, b)
+		le.write([]byte{b}, false)
+	}
+	return false, nil
+}
+
+// lastRune returns the number of bytes of the last rune of the line.
+func (le *lineEditor) lastRune() int {
+	_, size := utf8.DecodeLastRune(le.line)
+	return size
+}
+
+// lastWord returns the number of bytes of the last word of the line, including trailing spaces.
+func (le *lineEditor) lastWord() int {
+	i := len(le.line)
+	for i > 0 && le.line[i-1] == ' ' {
+		i--
+	}
+	for i > 0 && le.line[i-1] != ' ' {
+		i--
+	}
+	return len(le.line) - i
+}
+
+// erase erases n bytes from the end of the line.
+func (le *lineEditor) erase(n int) {
+	erased := le.line[len(le.line)-n:]
+	le.line = le.line[:len(le.line)-n]
+
+	for count := utf8.RuneCount(erased); count > 0; count-- {
+		le.write([]byte("\b \b"), false)
+	}
+}
+
+// write writes p to the terminal if echo is enabled or force is true.
+func (le *lineEditor) write(p []byte, force bool) {
+	if le.echo || force {
+		le.w.Write(p)
+	}
+}
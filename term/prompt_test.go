@@ -0,0 +1,145 @@
+package term
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_lineEditor(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		echo     bool
+		wantLine string
+		wantErr  error
+		wantEcho string
+	}{
+		{"plain line", "hello\r", true, "hello", nil, "hello\r\n"},
+		{"no echo", "secret\n", false, "secret", nil, "\r\n"},
+		{"backspace", "helo\x7flo\r", true, "hello", nil, "helo\b \blo\r\n"},
+		{"backspace multibyte rune", "hä\x7fa\r", true, "ha", nil, "hä\b \ba\r\n"},
+		{"kill line", "hello\x15bye\r", true, "bye", nil, "hello\b \b\b \b\b \b\b \b\b \bbye\r\n"},
+		{"delete word", "hello big \x17world\r", true, "hello world", nil, "hello big \b \b\b \b\b \b\b \bworld\r\n"},
+		{"ignore escape sequences", "a\x1b[Ab\x1bOBc\r", true, "abc", nil, "abc\r\n"},
+		{"interrupt", "abc\x03", true, "abc", ErrInterrupted, "abc^C\r\n"},
+		{"eof on empty line", "\x04", true, "", io.EOF, ""},
+		{"ignore eof on non-empty line", "a\x04b\r", true, "ab", nil, "ab\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var echo bytes.Buffer
+			editor := lineEditor{w: &echo, echo: tt.echo}
+
+			var err error
+			for _, b := range []byte(tt.input) {
+				var done bool
+				if done, err = editor.process(b); done {
+					break
+				}
+			}
+
+			if string(editor.line) != tt.wantLine || err != tt.wantErr {
+				t.Errorf("lineEditor got (%q, %v), want (%q, %v)", editor.line, err, tt.wantLine, tt.wantErr)
+			}
+			if echo.String() != tt.wantEcho {
+				t.Errorf("lineEditor echoed %q, want %q", echo.String(), tt.wantEcho)
+			}
+		})
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	if !PTYSupport {
+		t.Skip("OS not supported")
+	}
+
+	pty, tty, err := OpenTerminal()
+	if err != nil {
+		t.Fatal("OpenTerminal() returned error")
+	}
+	defer pty.Close()
+	defer tty.Close()
+
+	before, err := tty.GetMode()
+	if err != nil {
+		t.Fatalf("GetMode() returned %s", err)
+	}
+
+	// only send the password once the prompt has been written, and the terminal is in raw mode
+	output := make(chan string)
+	go func() {
+		var got []byte
+		buffer := make([]byte, 1)
+		for !strings.HasSuffix(string(got), "Password: ") {
+			if _, err := pty.ReadWriteCloser().Read(buffer); err != nil {
+				break
+			}
+			got = append(got, buffer[0])
+		}
+		io.WriteString(pty.ReadWriteCloser(), "secret\r")
+
+		rest, _ := ioutil.ReadAll(pty.ReadWriteCloser())
+		output <- string(got) + string(rest)
+	}()
+
+	password, err := ReadPassword(tty, "Password: ")
+	if string(password) != "secret" || err != nil {
+		t.Errorf("ReadPassword() = (%q, %v), want (\"secret\", nil)", password, err)
+	}
+
+	after, err := tty.GetMode()
+	if err != nil {
+		t.Fatalf("GetMode() returned %s", err)
+	}
+	if *after != *before {
+		t.Errorf("ReadPassword() did not restore mode: got %+v, want %+v", *after, *before)
+	}
+
+	tty.Close()
+	if got := <-output; !strings.HasPrefix(got, "Password: ") || strings.Contains(got, "secret") {
+		t.Errorf("ReadPassword() wrote %q", got)
+	}
+}
+
+// Test that RestoreAll() restores a terminal reading a prompt, and that no signals are intercepted without the guard.
+func TestReadLine_RestoreAll(t *testing.T) {
+	ft := NewFakeTerminal(WindowSize{})
+
+	type result struct {
+		line string
+		err  error
+	}
+	done := make(chan result)
+	go func() {
+		line, err := ReadLine(ft, "> ")
+		done <- result{line, err}
+	}()
+
+	// the prompt is written once the terminal is in raw mode
+	if _, err := io.ReadFull(ft.Output(), make([]byte, 2)); err != nil {
+		t.Fatalf("reading prompt returned %s", err)
+	}
+
+	registry.Lock()
+	guarding := registry.stopGuard != nil
+	registry.Unlock()
+	if guarding {
+		t.Error("guard is running without InstallSignalGuard()")
+	}
+
+	if err := RestoreAll(); err != nil {
+		t.Errorf("RestoreAll() returned %s", err)
+	}
+	if mode, _ := ft.GetMode(); !reflect.DeepEqual(*mode, DefaultFakeMode) {
+		t.Errorf("mode after RestoreAll() = %+v, want %+v", *mode, DefaultFakeMode)
+	}
+
+	io.WriteString(ft.Input(), "hello\r")
+	if got := <-done; got.line != "hello" || got.err != nil {
+		t.Errorf("ReadLine() = (%q, %v), want (\"hello\", nil)", got.line, got.err)
+	}
+}
@@ -0,0 +1,86 @@
+package term
+
+import "time"
+
+// SendLatest sends size on c without blocking, replacing any size that has not yet been received.
+// c must be buffered, and there must be no other concurrent senders.
+func SendLatest(c chan WindowSize, size WindowSize) {
+	for {
+		select {
+		case c <- size:
+			return
+		default:
+		}
+
+		// drop the pending size
+		select {
+		case <-c:
+		default:
+		}
+	}
+}
+
+// Debounce returns a channel that receives sizes from c, but suppresses bursts of resizes.
+//
+// The first size received from c is forwarded immediately.
+// Any further size is only forwarded once c has not received a new size for at least d.
+// Intermediate sizes are dropped, but the latest size is always forwarded eventually.
+// When the receiver is slow, only the latest size is kept.
+//
+// The returned channel is closed once c is closed.
+func Debounce(c <-chan WindowSize, d time.Duration) <-chan WindowSize {
+	return debounce(c, func() (<-chan time.Time, func() bool) {
+		timer := time.NewTimer(d)
+		return timer.C, timer.Stop
+	})
+}
+
+// debounce implements Debounce.
+// newTimer is called to start a new delay, and returns a channel receiving once the delay has passed along with a function to stop it.
+func debounce(c <-chan WindowSize, newTimer func() (<-chan time.Time, func() bool)) <-chan WindowSize {
+	out := make(chan WindowSize, 1)
+	go func() {
+		defer close(out)
+
+		var timer <-chan time.Time // nil while no delay is running
+		var stop func() bool
+		defer func() {
+			if stop != nil {
+				stop()
+			}
+		}()
+
+		var pending *WindowSize
+		first := true
+		for {
+			select {
+			case size, ok := <-c:
+				if !ok {
+					if pending != nil {
+						SendLatest(out, *pending)
+					}
+					return
+				}
+
+				if first {
+					first = false
+					SendLatest(out, size)
+					continue
+				}
+
+				pending = &size
+				if stop != nil {
+					stop()
+				}
+				timer, stop = newTimer()
+			case <-timer:
+				if pending != nil {
+					SendLatest(out, *pending)
+					pending = nil
+				}
+				timer, stop = nil, nil
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,79 @@
+package term
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDebounce(t *testing.T) {
+	size := func(i int) WindowSize { return WindowSize{Height: Size(i), Width: Size(i)} }
+
+	// timers are controlled by the test, started receives every new timer
+	started := make(chan chan time.Time, 10)
+	newTimer := func() (<-chan time.Time, func() bool) {
+		timer := make(chan time.Time, 1)
+		started <- timer
+		return timer, func() bool { return true }
+	}
+
+	c := make(chan WindowSize)
+	debounced := debounce(c, newTimer)
+
+	// the first size is forwarded immediately, without starting a timer
+	c <- size(1)
+	select {
+	case got := <-debounced:
+		if got != size(1) {
+			t.Errorf("Debounce() forwarded %v, want %v", got, size(1))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Debounce() did not forward initial size")
+	}
+
+	// a burst of sizes restarts the timer for every size, and forwards nothing
+	var timer chan time.Time
+	for i := 2; i <= 5; i++ {
+		c <- size(i)
+		timer = <-started
+	}
+	select {
+	case got := <-debounced:
+		t.Fatalf("Debounce() forwarded %v during burst", got)
+	default:
+	}
+
+	// only the latest size of the burst is forwarded once the timer fires
+	timer <- time.Now()
+	select {
+	case got := <-debounced:
+		if got != size(5) {
+			t.Errorf("Debounce() forwarded %v, want %v", got, size(5))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Debounce() did not forward size after burst")
+	}
+
+	// pending sizes are forwarded when closing
+	c <- size(6)
+	<-started
+	close(c)
+
+	var got []WindowSize
+	for size := range debounced {
+		got = append(got, size)
+	}
+	if want := []WindowSize{size(6)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Debounce() forwarded %v, want %v", got, want)
+	}
+}
+
+func TestSendLatest(t *testing.T) {
+	c := make(chan WindowSize, 1)
+	SendLatest(c, WindowSize{Height: 1})
+	SendLatest(c, WindowSize{Height: 2})
+
+	if got := <-c; got.Height != 2 {
+		t.Errorf("SendLatest() kept %v, want latest size", got)
+	}
+}
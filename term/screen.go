@@ -0,0 +1,913 @@
+package term
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Screen is a headless terminal emulator.
+//
+// It consumes output that a process writes to a terminal, and keeps track of what a user would see on the screen.
+// It understands the commonly used subset of VT100 and xterm control sequences, including cursor movement,
+// erasing, character attributes, scroll regions and the alternate screen.
+// Unknown sequences are ignored.
+// Every character is assumed to occupy a single cell.
+//
+// Screen implements io.Writer, and is safe to be used by multiple goroutines.
+type Screen struct {
+	m sync.Mutex
+
+	size               WindowSize
+	primary, alternate [][]Cell
+	active             *[][]Cell // either &primary or &alternate
+
+	row, col    int  // current cursor position, 0-based
+	wrapPending bool // the last character was written in the last column
+	pen         Attributes
+	last        rune // last printed rune, used for REP
+
+	saved    screenCursor // cursor saved by DECSC
+	altSaved screenCursor // cursor saved when entering the alternate screen via mode 1049
+
+	top, bottom int // scroll region, 0-based and inclusive
+
+	autoWrap      bool
+	insertMode    bool
+	originMode    bool
+	cursorVisible bool
+
+	title string
+
+	// parser state
+	state    screenState
+	pending  []byte // incomplete utf8 sequence
+	params   []int  // parameters of the current control sequence
+	hasParam bool   // has the current parameter received any digits
+	private  byte   // private marker of the current control sequence
+	inter    []byte // intermediate bytes of the current escape or control sequence
+	str      []byte // contents of the current string sequence
+}
+
+// Cell is a single character cell on the screen.
+type Cell struct {
+	Rune rune // character in this cell, ' ' when empty
+	Attributes
+}
+
+// Attributes represents the graphical attributes of a cell.
+type Attributes struct {
+	Foreground, Background Color
+
+	Bold, Faint, Italic, Underline, Blink, Inverse, Hidden, Strikethrough bool
+}
+
+// Color represents a color of a cell.
+// The zero value represents the default color.
+type Color uint32
+
+// DefaultColor represents the default foreground or background color.
+const DefaultColor Color = 0
+
+const (
+	colorIndexed Color = 1 << 24
+	colorRGB     Color = 2 << 24
+	colorMask    Color = 0xffffff
+)
+
+// IndexedColor returns the color with the given index in the 256 color palette.
+// Indexes 0 through 15 represent the standard and bright colors.
+func IndexedColor(index uint8) Color {
+	return colorIndexed | Color(index)
+}
+
+// RGBColor returns a 24-bit color.
+func RGBColor(r, g, b uint8) Color {
+	return colorRGB | Color(r)<<16 | Color(g)<<8 | Color(b)
+}
+
+// Index returns the palette index of this color, if it is an indexed color.
+func (c Color) Index() (index uint8, ok bool) {
+	if c&^colorMask != colorIndexed {
+		return 0, false
+	}
+	return uint8(c), true
+}
+
+// RGB returns the components of this color, if it is a 24-bit color.
+func (c Color) RGB() (r, g, b uint8, ok bool) {
+	if c&^colorMask != colorRGB {
+		return 0, 0, 0, false
+	}
+	return uint8(c >> 16), uint8(c >> 8), uint8(c), true
+}
+
+// ScreenSnapshot is a snapshot of the state of a Screen.
+type ScreenSnapshot struct {
+	Size  WindowSize
+	Cells [][]Cell // Cells[row][column]
+
+	CursorRow, CursorCol int // 0-based position of the cursor
+	CursorVisible        bool
+
+	Alternate bool   // is the alternate screen active?
+	Title     string // window title, as set using OSC 0 or OSC 2
+}
+
+// Lines returns the text on each row of this snapshot, with trailing whitespace removed.
+func (snap ScreenSnapshot) Lines() []string {
+	lines := make([]string, len(snap.Cells))
+	for i, row := range snap.Cells {
+		var builder strings.Builder
+		for _, cell := range row {
+			builder.WriteRune(cell.Rune)
+		}
+		lines[i] = strings.TrimRight(builder.String(), " ")
+	}
+	return lines
+}
+
+// Text returns the text on screen, with one line per row.
+// Trailing whitespace and trailing empty lines are removed.
+func (snap ScreenSnapshot) Text() string {
+	return strings.TrimRight(strings.Join(snap.Lines(), "\n"), "\n")
+}
+
+type screenCursor struct {
+	row, col    int
+	pen         Attributes
+	originMode  bool
+	wrapPending bool
+}
+
+type screenState int
+
+const (
+	screenGround screenState = iota
+	screenEscape
+	screenCSI
+	screenOSC
+	screenString // DCS, SOS, PM or APC; contents are ignored
+	screenStringEscape
+)
+
+// NewScreen returns a new, empty screen of the given size.
+func NewScreen(size WindowSize) *Screen {
+	s := &Screen{}
+	s.reset(size)
+	return s
+}
+
+// reset resets the screen into the initial state.
+func (s *Screen) reset(size WindowSize) {
+	if size.Height == 0 {
+		size.Height = 1
+	}
+	if size.Width == 0 {
+		size.Width = 1
+	}
+
+	s.size = size
+	s.primary = newScreenBuffer(size, Attributes{})
+	s.alternate = newScreenBuffer(size, Attributes{})
+	s.active = &s.primary
+
+	s.row, s.col = 0, 0
+	s.wrapPending = false
+	s.pen = Attributes{}
+	s.last = ' '
+	s.saved, s.altSaved = screenCursor{}, screenCursor{}
+	s.top, s.bottom = 0, int(size.Height)-1
+
+	s.autoWrap = true
+	s.insertMode = false
+	s.originMode = false
+	s.cursorVisible = true
+	s.title = ""
+
+	s.state = screenGround
+}
+
+func newScreenBuffer(size WindowSize, pen Attributes) [][]Cell {
+	buffer := make([][]Cell, size.Height)
+	for i := range buffer {
+		buffer[i] = newScreenLine(int(size.Width), pen)
+	}
+	return buffer
+}
+
+func newScreenLine(width int, pen Attributes) []Cell {
+	line := make([]Cell, width)
+	blank := blankCell(pen)
+	for i := range line {
+		line[i] = blank
+	}
+	return line
+}
+
+// blankCell returns the cell used for erasing when the current attributes are pen.
+func blankCell(pen Attributes) Cell {
+	return Cell{Rune: ' ', Attributes: Attributes{Background: pen.Background}}
+}
+
+// Size returns the current size of the screen.
+func (s *Screen) Size() WindowSize {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.size
+}
+
+// Cursor returns the current 0-based position of the cursor.
+func (s *Screen) Cursor() (row, col int) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.row, s.col
+}
+
+// Snapshot returns a snapshot of the current state of the screen.
+func (s *Screen) Snapshot() ScreenSnapshot {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	cells := make([][]Cell, len(*s.active))
+	for i, line := range *s.active {
+		cells[i] = append([]Cell(nil), line...)
+	}
+
+	return ScreenSnapshot{
+		Size:          s.size,
+		Cells:         cells,
+		CursorRow:     s.row,
+		CursorCol:     s.col,
+		CursorVisible: s.cursorVisible,
+		Alternate:     s.active == &s.alternate,
+		Title:         s.title,
+	}
+}
+
+// Text returns the text currently on the screen.
+// See ScreenSnapshot.Text.
+func (s *Screen) Text() string {
+	return s.Snapshot().Text()
+}
+
+// Resize resizes the screen to the given size.
+//
+// Content is kept aligned to the top-left corner of the screen.
+// When the height shrinks below the cursor, lines are removed from the top instead.
+// The scroll region is reset.
+func (s *Screen) Resize(size WindowSize) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if size.Height == 0 {
+		size.Height = 1
+	}
+	if size.Width == 0 {
+		size.Width = 1
+	}
+
+	// number of lines to remove from the top
+	shift := 0
+	if s.row >= int(size.Height) {
+		shift = s.row - int(size.Height) + 1
+	}
+
+	resize := func(buffer [][]Cell, shift int) [][]Cell {
+		resized := newScreenBuffer(size, Attributes{})
+		for i := range resized {
+			if i+shift < len(buffer) {
+				copy(resized[i], buffer[i+shift])
+			}
+		}
+		return resized
+	}
+
+	if s.active == &s.primary {
+		s.primary = resize(s.primary, shift)
+		s.alternate = resize(s.alternate, 0)
+	} else {
+		s.primary = resize(s.primary, 0)
+		s.alternate = resize(s.alternate, shift)
+	}
+
+	s.size = size
+	s.top, s.bottom = 0, int(size.Height)-1
+	s.row -= shift
+	s.wrapPending = false
+	s.clampCursor()
+}
+
+// Write processes output written to the terminal.
+// It never returns an error.
+func (s *Screen) Write(p []byte) (int, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	data := p
+	if len(s.pending) > 0 {
+		data = append(s.pending, p...)
+		s.pending = nil
+	}
+
+	for i := 0; i < len(data); {
+		b := data[i]
+
+		// decode utf8 when printing characters
+		if b >= utf8.RuneSelf && s.state == screenGround {
+			if !utf8.FullRune(data[i:]) {
+				s.pending = append([]byte(nil), data[i:]...)
+				break
+			}
+			r, size := utf8.DecodeRune(data[i:])
+			s.print(r)
+			i += size
+			continue
+		}
+
+		s.process(b)
+		i++
+	}
+
+	return len(p), nil
+}
+
+// process processes a single byte of input.
+func (s *Screen) process(b byte) {
+	switch s.state {
+	case screenOSC:
+		switch b {
+		case 0x07: // BEL
+			s.finishOSC()
+		case 0x1b: // ESC, presumably followed by '\'
+			s.finishOSC()
+			s.startEscape()
+		default:
+			s.str = append(s.str, b)
+		}
+		return
+	case screenString:
+		if b == 0x1b {
+			s.state = screenStringEscape
+		}
+		return
+	case screenStringEscape:
+		if b == '\\' {
+			s.state = screenGround
+		} else {
+			s.state = screenString
+		}
+		return
+	}
+
+	// C0 controls are executed in any other state
+	if b < 0x20 || b == 0x7f {
+		s.control(b)
+		return
+	}
+
+	switch s.state {
+	case screenGround:
+		s.print(rune(b))
+	case screenEscape:
+		s.escape(b)
+	case screenCSI:
+		s.csiByte(b)
+	}
+}
+
+// control executes a C0 control character.
+func (s *Screen) control(b byte) {
+	switch b {
+	case 0x1b: // ESC
+		s.startEscape()
+	case 0x18, 0x1a: // CAN, SUB
+		s.state = screenGround
+	case 0x08: // BS
+		if s.col > 0 {
+			s.col--
+		}
+		s.wrapPending = false
+	case 0x09: // HT
+		s.col = (s.col/8 + 1) * 8
+		if s.col >= int(s.size.Width) {
+			s.col = int(s.size.Width) - 1
+		}
+		s.wrapPending = false
+	case 0x0a, 0x0b, 0x0c: // LF, VT, FF
+		s.index()
+	case 0x0d: // CR
+		s.col = 0
+		s.wrapPending = false
+	}
+}
+
+func (s *Screen) startEscape() {
+	s.state = screenEscape
+	s.inter = s.inter[:0]
+}
+
+// escape handles a byte following ESC.
+func (s *Screen) escape(b byte) {
+	// intermediate bytes
+	if b >= 0x20 && b <= 0x2f {
+		s.inter = append(s.inter, b)
+		return
+	}
+
+	s.state = screenGround
+
+	if len(s.inter) > 0 {
+		// character set designation is ignored
+		if s.inter[0] == '#' && b == '8' {
+			s.alignmentTest()
+		}
+		return
+	}
+
+	switch b {
+	case '[':
+		s.state = screenCSI
+		s.params = s.params[:0]
+		s.hasParam = false
+		s.private = 0
+	case ']':
+		s.state = screenOSC
+		s.str = s.str[:0]
+	case 'P', 'X', '^', '_':
+		s.state = screenString
+	case '7':
+		s.saved = s.saveCursor()
+	case '8':
+		s.restoreCursor(s.saved)
+	case 'D':
+		s.index()
+	case 'E':
+		s.col = 0
+		s.index()
+	case 'M':
+		s.reverseIndex()
+	case 'c':
+		s.reset(s.size)
+	}
+}
+
+// csiByte handles a byte within a control sequence.
+func (s *Screen) csiByte(b byte) {
+	switch {
+	case b >= '0' && b <= '9':
+		if !s.hasParam {
+			s.params = append(s.params, 0)
+			s.hasParam = true
+		}
+		last := &s.params[len(s.params)-1]
+		if *last < 1<<16 {
+			*last = *last*10 + int(b-'0')
+		}
+	case b == ';' || b == ':':
+		if !s.hasParam {
+			s.params = append(s.params, -1)
+		}
+		s.hasParam = false
+	case b >= '<' && b <= '?':
+		s.private = b
+	case b >= 0x20 && b <= 0x2f:
+		s.inter = append(s.inter, b)
+	case b >= 0x40 && b <= 0x7e:
+		if !s.hasParam && len(s.params) > 0 {
+			s.params = append(s.params, -1)
+		}
+		s.state = screenGround
+		s.csi(b)
+	default:
+		s.state = screenGround
+	}
+}
+
+// param returns the ith parameter of the current control sequence, or def if it is missing or zero.
+func (s *Screen) param(i, def int) int {
+	if i >= len(s.params) || s.params[i] <= 0 {
+		return def
+	}
+	return s.params[i]
+}
+
+// csi executes a control sequence with the given final byte.
+func (s *Screen) csi(final byte) {
+	if len(s.inter) > 0 {
+		return // sequences with intermediates are not supported
+	}
+
+	if s.private != 0 {
+		if s.private == '?' && (final == 'h' || final == 'l') {
+			for i := range s.params {
+				s.setPrivateMode(s.params[i], final == 'h')
+			}
+		}
+		return
+	}
+
+	n := s.param(0, 1)
+	height, width := int(s.size.Height), int(s.size.Width)
+
+	switch final {
+	case '@': // ICH
+		s.insertCells(n)
+	case 'A': // CUU
+		s.moveCursor(s.row-n, s.col, true)
+	case 'B', 'e': // CUD, VPR
+		s.moveCursor(s.row+n, s.col, true)
+	case 'C', 'a': // CUF, HPR
+		s.moveCursor(s.row, s.col+n, true)
+	case 'D': // CUB
+		s.moveCursor(s.row, s.col-n, true)
+	case 'E': // CNL
+		s.moveCursor(s.row+n, 0, true)
+	case 'F': // CPL
+		s.moveCursor(s.row-n, 0, true)
+	case 'G', '`': // CHA, HPA
+		s.moveCursor(s.row, n-1, false)
+	case 'd': // VPA
+		s.moveCursor(s.originRow(n-1), s.col, false)
+	case 'H', 'f': // CUP, HVP
+		s.moveCursor(s.originRow(n-1), s.param(1, 1)-1, false)
+	case 'J': // ED
+		switch s.param(0, 0) {
+		case 0:
+			s.eraseCells(s.row, s.col, width)
+			s.eraseLines(s.row+1, height)
+		case 1:
+			s.eraseLines(0, s.row)
+			s.eraseCells(s.row, 0, s.col+1)
+		case 2, 3:
+			s.eraseLines(0, height)
+		}
+	case 'K': // EL
+		switch s.param(0, 0) {
+		case 0:
+			s.eraseCells(s.row, s.col, width)
+		case 1:
+			s.eraseCells(s.row, 0, s.col+1)
+		case 2:
+			s.eraseCells(s.row, 0, width)
+		}
+	case 'L': // IL
+		if s.row >= s.top && s.row <= s.bottom {
+			s.scrollDown(s.row, s.bottom, n)
+			s.col = 0
+		}
+	case 'M': // DL
+		if s.row >= s.top && s.row <= s.bottom {
+			s.scrollUp(s.row, s.bottom, n)
+			s.col = 0
+		}
+	case 'P': // DCH
+		s.deleteCells(n)
+	case 'X': // ECH
+		s.eraseCells(s.row, s.col, s.col+n)
+	case 'S': // SU
+		s.scrollUp(s.top, s.bottom, n)
+	case 'T': // SD
+		s.scrollDown(s.top, s.bottom, n)
+	case 'b': // REP
+		for i := 0; i < n && i < width*height; i++ {
+			s.print(s.last)
+		}
+	case 'm': // SGR
+		s.sgr()
+	case 'r': // DECSTBM
+		top, bottom := s.param(0, 1)-1, s.param(1, height)-1
+		if bottom >= height {
+			bottom = height - 1
+		}
+		if top < bottom {
+			s.top, s.bottom = top, bottom
+			s.moveCursor(s.originRow(0), 0, false)
+		}
+	case 's': // SCOSC
+		s.saved = s.saveCursor()
+	case 'u': // SCORC
+		s.restoreCursor(s.saved)
+	case 'h', 'l': // SM, RM
+		for _, mode := range s.params {
+			if mode == 4 {
+				s.insertMode = final == 'h'
+			}
+		}
+	}
+}
+
+// setPrivateMode sets or resets a DEC private mode.
+func (s *Screen) setPrivateMode(mode int, set bool) {
+	switch mode {
+	case 6: // DECOM
+		s.originMode = set
+		s.moveCursor(s.originRow(0), 0, false)
+	case 7: // DECAWM
+		s.autoWrap = set
+	case 25: // DECTCEM
+		s.cursorVisible = set
+	case 47, 1047:
+		s.switchScreen(set, mode == 1047 && !set)
+	case 1049:
+		if set {
+			s.altSaved = s.saveCursor()
+			s.switchScreen(true, false)
+			s.eraseLines(0, int(s.size.Height))
+		} else {
+			s.switchScreen(false, false)
+			s.restoreCursor(s.altSaved)
+		}
+	}
+}
+
+// switchScreen switches to the alternate or primary screen.
+// When clear is true, the alternate screen is cleared when leaving it.
+func (s *Screen) switchScreen(alternate bool, clear bool) {
+	if alternate {
+		s.active = &s.alternate
+		return
+	}
+	if clear && s.active == &s.alternate {
+		s.eraseLines(0, int(s.size.Height))
+	}
+	s.active = &s.primary
+}
+
+// sgr applies the current set graphics rendition sequence.
+func (s *Screen) sgr() {
+	if len(s.params) == 0 {
+		s.pen = Attributes{}
+		return
+	}
+
+	for i := 0; i < len(s.params); i++ {
+		switch p := s.params[i]; {
+		case p <= 0:
+			s.pen = Attributes{}
+		case p == 1:
+			s.pen.Bold = true
+		case p == 2:
+			s.pen.Faint = true
+		case p == 3:
+			s.pen.Italic = true
+		case p == 4:
+			s.pen.Underline = true
+		case p == 5 || p == 6:
+			s.pen.Blink = true
+		case p == 7:
+			s.pen.Inverse = true
+		case p == 8:
+			s.pen.Hidden = true
+		case p == 9:
+			s.pen.Strikethrough = true
+		case p == 21 || p == 22:
+			s.pen.Bold, s.pen.Faint = false, false
+		case p == 23:
+			s.pen.Italic = false
+		case p == 24:
+			s.pen.Underline = false
+		case p == 25:
+			s.pen.Blink = false
+		case p == 27:
+			s.pen.Inverse = false
+		case p == 28:
+			s.pen.Hidden = false
+		case p == 29:
+			s.pen.Strikethrough = false
+		case p >= 30 && p <= 37:
+			s.pen.Foreground = IndexedColor(uint8(p - 30))
+		case p == 38:
+			s.pen.Foreground, i = s.extendedColor(i)
+		case p == 39:
+			s.pen.Foreground = DefaultColor
+		case p >= 40 && p <= 47:
+			s.pen.Background = IndexedColor(uint8(p - 40))
+		case p == 48:
+			s.pen.Background, i = s.extendedColor(i)
+		case p == 49:
+			s.pen.Background = DefaultColor
+		case p >= 90 && p <= 97:
+			s.pen.Foreground = IndexedColor(uint8(p - 90 + 8))
+		case p >= 100 && p <= 107:
+			s.pen.Background = IndexedColor(uint8(p - 100 + 8))
+		}
+	}
+}
+
+// extendedColor parses an extended color starting at the parameter with index i (which is 38 or 48).
+// Returns the color and the index of the last parameter consumed.
+func (s *Screen) extendedColor(i int) (Color, int) {
+	component := func(j int) uint8 {
+		if j >= len(s.params) || s.params[j] < 0 {
+			return 0
+		}
+		return uint8(s.params[j])
+	}
+
+	switch s.param(i+1, 0) {
+	case 5:
+		return IndexedColor(component(i + 2)), i + 2
+	case 2:
+		return RGBColor(component(i+2), component(i+3), component(i+4)), i + 4
+	}
+	return DefaultColor, len(s.params)
+}
+
+// finishOSC executes the current operating system command.
+func (s *Screen) finishOSC() {
+	s.state = screenGround
+
+	cmd := string(s.str)
+	sep := strings.IndexByte(cmd, ';')
+	if sep < 0 {
+		return
+	}
+	if code, err := strconv.Atoi(cmd[:sep]); err == nil && (code == 0 || code == 2) {
+		s.title = cmd[sep+1:]
+	}
+}
+
+// print prints a single character at the current cursor position.
+func (s *Screen) print(r rune) {
+	width := int(s.size.Width)
+
+	if s.wrapPending && s.autoWrap {
+		s.col = 0
+		s.index()
+	}
+	s.wrapPending = false
+
+	if s.insertMode {
+		s.insertCells(1)
+	}
+
+	(*s.active)[s.row][s.col] = Cell{Rune: r, Attributes: s.pen}
+	s.last = r
+
+	if s.col == width-1 {
+		s.wrapPending = true
+	} else {
+		s.col++
+	}
+}
+
+// index moves the cursor down one line, scrolling if at the bottom of the scroll region.
+func (s *Screen) index() {
+	s.wrapPending = false
+	switch {
+	case s.row == s.bottom:
+		s.scrollUp(s.top, s.bottom, 1)
+	case s.row < int(s.size.Height)-1:
+		s.row++
+	}
+}
+
+// reverseIndex moves the cursor up one line, scrolling if at the top of the scroll region.
+func (s *Screen) reverseIndex() {
+	s.wrapPending = false
+	switch {
+	case s.row == s.top:
+		s.scrollDown(s.top, s.bottom, 1)
+	case s.row > 0:
+		s.row--
+	}
+}
+
+// scrollUp scrolls lines top through bottom (inclusive) up by n lines.
+func (s *Screen) scrollUp(top, bottom, n int) {
+	buffer := *s.active
+	if n > bottom-top+1 {
+		n = bottom - top + 1
+	}
+	copy(buffer[top:bottom+1], buffer[top+n:bottom+1])
+	for i := bottom - n + 1; i <= bottom; i++ {
+		buffer[i] = newScreenLine(int(s.size.Width), s.pen)
+	}
+}
+
+// scrollDown scrolls lines top through bottom (inclusive) down by n lines.
+func (s *Screen) scrollDown(top, bottom, n int) {
+	buffer := *s.active
+	if n > bottom-top+1 {
+		n = bottom - top + 1
+	}
+	copy(buffer[top+n:bottom+1], buffer[top:bottom+1-n])
+	for i := top; i < top+n; i++ {
+		buffer[i] = newScreenLine(int(s.size.Width), s.pen)
+	}
+}
+
+// eraseLines erases lines from (inclusive) through to (exclusive).
+func (s *Screen) eraseLines(from, to int) {
+	for row := from; row < to; row++ {
+		s.eraseCells(row, 0, int(s.size.Width))
+	}
+}
+
+// eraseCells erases the cells in row from (inclusive) through to (exclusive).
+func (s *Screen) eraseCells(row, from, to int) {
+	if to > int(s.size.Width) {
+		to = int(s.size.Width)
+	}
+	line := (*s.active)[row]
+	blank := blankCell(s.pen)
+	for col := from; col < to; col++ {
+		line[col] = blank
+	}
+	s.wrapPending = false
+}
+
+// insertCells inserts n blank cells at the cursor, shifting the rest of the line right.
+func (s *Screen) insertCells(n int) {
+	line := (*s.active)[s.row]
+	if n > len(line)-s.col {
+		n = len(line) - s.col
+	}
+	copy(line[s.col+n:], line[s.col:])
+	s.eraseCells(s.row, s.col, s.col+n)
+}
+
+// deleteCells deletes n cells at the cursor, shifting the rest of the line left.
+func (s *Screen) deleteCells(n int) {
+	line := (*s.active)[s.row]
+	if n > len(line)-s.col {
+		n = len(line) - s.col
+	}
+	copy(line[s.col:], line[s.col+n:])
+	s.eraseCells(s.row, len(line)-n, len(line))
+}
+
+// alignmentTest fills the screen with 'E'.
+func (s *Screen) alignmentTest() {
+	for _, line := range *s.active {
+		for col := range line {
+			line[col] = Cell{Rune: 'E'}
+		}
+	}
+	s.row, s.col = 0, 0
+}
+
+// originRow returns the absolute row corresponding to the given row, taking origin mode into account.
+func (s *Screen) originRow(row int) int {
+	if s.originMode {
+		return row + s.top
+	}
+	return row
+}
+
+// moveCursor moves the cursor to the given position.
+// When relative is true and the cursor starts within the scroll region, it is kept within the scroll region.
+func (s *Screen) moveCursor(row, col int, relative bool) {
+	top, bottom := 0, int(s.size.Height)-1
+	if s.originMode || (relative && s.row >= s.top && s.row <= s.bottom) {
+		top, bottom = s.top, s.bottom
+	}
+	if row < top {
+		row = top
+	}
+	if row > bottom {
+		row = bottom
+	}
+
+	s.row, s.col = row, col
+	s.wrapPending = false
+	s.clampCursor()
+}
+
+// clampCursor ensures that the cursor is within the screen.
+func (s *Screen) clampCursor() {
+	if s.row < 0 {
+		s.row = 0
+	}
+	if s.row >= int(s.size.Height) {
+		s.row = int(s.size.Height) - 1
+	}
+	if s.col < 0 {
+		s.col = 0
+	}
+	if s.col >= int(s.size.Width) {
+		s.col = int(s.size.Width) - 1
+	}
+}
+
+func (s *Screen) saveCursor() screenCursor {
+	return screenCursor{
+		row:         s.row,
+		col:         s.col,
+		pen:         s.pen,
+		originMode:  s.originMode,
+		wrapPending: s.wrapPending,
+	}
+}
+
+func (s *Screen) restoreCursor(c screenCursor) {
+	s.row, s.col = c.row, c.col
+	s.pen = c.pen
+	s.originMode = c.originMode
+	s.wrapPending = c.wrapPending
+	s.clampCursor()
+}
@@ -0,0 +1,226 @@
+package term
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScreen(t *testing.T) {
+	size := WindowSize{Height: 4, Width: 10}
+
+	tests := []struct {
+		name                string
+		input               []string
+		wantText            string
+		wantRow, wantCol    int
+		wantAlternate       bool
+		wantTitle           string
+		wantCursorInvisible bool
+		wantAttrs           *Attributes // attributes of the cell at (0, 0)
+	}{
+		{
+			name:     "plain text",
+			input:    []string{"hello\r\nworld"},
+			wantText: "hello\nworld",
+			wantRow:  1, wantCol: 5,
+		},
+		{
+			name:     "utf8 split across writes",
+			input:    []string{"h\xc3", "\xa4llo"},
+			wantText: "hällo",
+			wantRow:  0, wantCol: 5,
+		},
+		{
+			name:     "line feed does not return",
+			input:    []string{"ab\ncd"},
+			wantText: "ab\n  cd",
+			wantRow:  1, wantCol: 4,
+		},
+		{
+			name:     "wrapping",
+			input:    []string{"0123456789abc"},
+			wantText: "0123456789\nabc",
+			wantRow:  1, wantCol: 3,
+		},
+		{
+			name:     "no wrapping in last column until next character",
+			input:    []string{"0123456789\r\n"},
+			wantText: "0123456789",
+			wantRow:  1, wantCol: 0,
+		},
+		{
+			name:     "scrolling",
+			input:    []string{"1\r\n2\r\n3\r\n4\r\n5"},
+			wantText: "2\n3\n4\n5",
+			wantRow:  3, wantCol: 1,
+		},
+		{
+			name:     "cursor positioning",
+			input:    []string{"\x1b[2;3Hx\x1b[Hy\x1b[4;10Hz"},
+			wantText: "y\n  x\n\n         z",
+			wantRow:  3, wantCol: 9,
+		},
+		{
+			name:     "relative cursor movement",
+			input:    []string{"\x1b[2B\x1b[3Cx\x1b[A\x1b[2Dy"},
+			wantText: "\n  y\n   x",
+			wantRow:  1, wantCol: 3,
+		},
+		{
+			name:     "erase display and line",
+			input:    []string{"aaaa\r\nbbbb\r\ncccc\x1b[2;3H\x1b[K\x1b[1J"},
+			wantText: "\n\ncccc",
+			wantRow:  1, wantCol: 2,
+		},
+		{
+			name:     "erase entire display",
+			input:    []string{"aaaa\r\nbbbb\x1b[2J"},
+			wantText: "",
+			wantRow:  1, wantCol: 4,
+		},
+		{
+			name:     "insert and delete characters",
+			input:    []string{"abcdef\x1b[1;2H\x1b[2@XY\x1b[1;6H\x1b[P"},
+			wantText: "aXYbcef",
+			wantRow:  0, wantCol: 5,
+		},
+		{
+			name:     "insert and delete lines",
+			input:    []string{"1\r\n2\r\n3\r\n4\x1b[2H\x1b[L\x1b[4H\x1b[M"},
+			wantText: "1\n\n2",
+			wantRow:  3, wantCol: 0,
+		},
+		{
+			name:     "scroll region",
+			input:    []string{"1\r\n2\r\n3\r\n4\x1b[2;3r\x1b[3H\nx"},
+			wantText: "1\n3\nx\n4",
+			wantRow:  2, wantCol: 1,
+		},
+		{
+			name:     "reverse index at top",
+			input:    []string{"1\r\n2\x1b[H\x1bMx"},
+			wantText: "x\n1\n2",
+			wantRow:  0, wantCol: 1,
+		},
+		{
+			name:     "save and restore cursor",
+			input:    []string{"ab\x1b7\x1b[3;3Hc\x1b8d"},
+			wantText: "abd\n\n  c",
+			wantRow:  0, wantCol: 3,
+		},
+		{
+			name:     "alternate screen",
+			input:    []string{"primary\x1b[?1049h\x1b[Halternate"},
+			wantText: "alternate",
+			wantRow:  0, wantCol: 9,
+			wantAlternate: true,
+		},
+		{
+			name:     "leave alternate screen",
+			input:    []string{"primary\x1b[?1049h\x1b[Halternate\x1b[?1049l"},
+			wantText: "primary",
+			wantRow:  0, wantCol: 7,
+		},
+		{
+			name:     "title and ignored sequences",
+			input:    []string{"\x1b]0;my title\x07\x1bP1$r\x1b\\\x1b(Bok\x1b[>c"},
+			wantText: "ok",
+			wantRow:  0, wantCol: 2,
+			wantTitle: "my title",
+		},
+		{
+			name:                "hide cursor",
+			input:               []string{"\x1b[?25l"},
+			wantCursorInvisible: true,
+		},
+		{
+			name:     "tabs and backspace",
+			input:    []string{"a\tb\x08c"},
+			wantText: "a       c",
+			wantRow:  0, wantCol: 9,
+		},
+		{
+			name:     "attributes",
+			input:    []string{"\x1b[1;4;31;48;2;1;2;3mx\x1b[0my"},
+			wantText: "xy",
+			wantRow:  0, wantCol: 2,
+			wantAttrs: &Attributes{Bold: true, Underline: true, Foreground: IndexedColor(1), Background: RGBColor(1, 2, 3)},
+		},
+		{
+			name:     "256 colors and reset",
+			input:    []string{"\x1b[38;5;200;7m\x1b[27mx"},
+			wantText: "x",
+			wantRow:  0, wantCol: 1,
+			wantAttrs: &Attributes{Foreground: IndexedColor(200)},
+		},
+		{
+			name:     "full reset",
+			input:    []string{"hello\x1b[?1049h\x1bc"},
+			wantText: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			screen := NewScreen(size)
+			for _, input := range tt.input {
+				if n, err := screen.Write([]byte(input)); n != len(input) || err != nil {
+					t.Fatalf("Screen.Write() = (%d, %v), want (%d, nil)", n, err, len(input))
+				}
+			}
+
+			snap := screen.Snapshot()
+			if got := snap.Text(); got != tt.wantText {
+				t.Errorf("Screen.Text() = %q, want %q", got, tt.wantText)
+			}
+			if snap.CursorRow != tt.wantRow || snap.CursorCol != tt.wantCol {
+				t.Errorf("Screen cursor = (%d, %d), want (%d, %d)", snap.CursorRow, snap.CursorCol, tt.wantRow, tt.wantCol)
+			}
+			if snap.Alternate != tt.wantAlternate {
+				t.Errorf("Screen.Alternate = %v, want %v", snap.Alternate, tt.wantAlternate)
+			}
+			if snap.Title != tt.wantTitle {
+				t.Errorf("Screen.Title = %q, want %q", snap.Title, tt.wantTitle)
+			}
+			if snap.CursorVisible == tt.wantCursorInvisible {
+				t.Errorf("Screen.CursorVisible = %v, want %v", snap.CursorVisible, !tt.wantCursorInvisible)
+			}
+			if tt.wantAttrs != nil && !reflect.DeepEqual(snap.Cells[0][0].Attributes, *tt.wantAttrs) {
+				t.Errorf("Screen attributes = %v, want %v", snap.Cells[0][0].Attributes, *tt.wantAttrs)
+			}
+		})
+	}
+}
+
+func TestScreen_Resize(t *testing.T) {
+	screen := NewScreen(WindowSize{Height: 4, Width: 10})
+	screen.Write([]byte("1\r\n2\r\n3\r\n4"))
+
+	screen.Resize(WindowSize{Height: 2, Width: 5})
+	if got, want := screen.Text(), "3\n4"; got != want {
+		t.Errorf("Screen.Text() = %q, want %q", got, want)
+	}
+	if row, col := screen.Cursor(); row != 1 || col != 1 {
+		t.Errorf("Screen.Cursor() = (%d, %d), want (1, 1)", row, col)
+	}
+
+	screen.Resize(WindowSize{Height: 3, Width: 5})
+	screen.Write([]byte("\r\n5"))
+	if got, want := screen.Text(), "3\n4\n5"; got != want {
+		t.Errorf("Screen.Text() = %q, want %q", got, want)
+	}
+}
+
+func TestColor(t *testing.T) {
+	if _, ok := DefaultColor.Index(); ok {
+		t.Error("DefaultColor.Index() returned ok")
+	}
+	if index, ok := IndexedColor(42).Index(); !ok || index != 42 {
+		t.Errorf("IndexedColor(42).Index() = (%d, %v)", index, ok)
+	}
+	if r, g, b, ok := RGBColor(1, 2, 3).RGB(); !ok || r != 1 || g != 2 || b != 3 {
+		t.Errorf("RGBColor(1, 2, 3).RGB() = (%d, %d, %d, %v)", r, g, b, ok)
+	}
+	if _, _, _, ok := IndexedColor(1).RGB(); ok {
+		t.Error("IndexedColor(1).RGB() returned ok")
+	}
+}
@@ -0,0 +1,111 @@
+package term
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// StdTerminal represents the standard streams of the current process.
+// Each of the streams may or may not be a terminal.
+type StdTerminal struct {
+	Stdin, Stdout, Stderr Terminal
+
+	// TERM is the value of the TERM environment variable.
+	// It is only set when IsTerminal() returns true.
+	TERM string
+
+	// Resize receives the size of the terminal, once initially and then whenever it changes.
+	// It is nil unless IsTerminal() returns true.
+	Resize <-chan WindowSize
+
+	resizeCleanup func()
+	restoreOnce   sync.Once
+	restoreErr    error
+}
+
+// OpenStdTerminal returns a new StdTerminal representing os.Stdin, os.Stdout and os.Stderr.
+//
+// When os.Stdout is a terminal, a process should be run on a pty; see IsTerminal().
+// In this case os.Stdout is put into raw output mode, and os.Stdin is put into raw input mode if it is a terminal.
+// Otherwise the modes of the streams are left unchanged.
+//
+// The caller must call Restore() once the StdTerminal is no longer needed.
+func OpenStdTerminal() (*StdTerminal, error) {
+	std := &StdTerminal{
+		Stdin:  NewTerminal(os.Stdin),
+		Stdout: NewTerminal(os.Stdout),
+		Stderr: NewTerminal(os.Stderr),
+	}
+	if !std.IsTerminal() {
+		return std, nil
+	}
+
+	if err := std.Stdin.SetRawInput(); err != nil {
+		return nil, err
+	}
+
+	if err := std.Stdout.SetRawOutput(); err != nil {
+		std.Stdin.RestoreInput() // restore input which we may have broken
+		return nil, err
+	}
+
+	resize, resizeCleanup, err := monitorSize(std.Stdout)
+	if err != nil {
+		// restore input and ouput to prevent breakage
+		std.Stdin.RestoreInput()
+		std.Stdout.RestoreOutput()
+		return nil, err
+	}
+
+	std.TERM = os.Getenv("TERM")
+	std.Resize = resize
+	std.resizeCleanup = resizeCleanup
+
+	return std, nil
+}
+
+// IsTerminal checks if a process using this StdTerminal should be run on a pty.
+// This is the case when Stdout is a terminal, regardless of Stdin.
+func (std *StdTerminal) IsTerminal() bool {
+	return std.Stdout.IsTerminal()
+}
+
+// ReadWriteCloser returns an io.ReadWriteCloser that reads from Stdin and writes to Stdout.
+// It can be passed to Command.StartPty().
+//
+// Closing the returned ReadWriteCloser does not close the underlying streams.
+func (std *StdTerminal) ReadWriteCloser() io.ReadWriteCloser {
+	return stdReadWriteCloser{
+		Reader: std.Stdin.ReadWriteCloser(),
+		Writer: std.Stdout.ReadWriteCloser(),
+	}
+}
+
+// Restore restores the modes of Stdin and Stdout and stops sending resize events.
+// Restore may safely be called multiple times.
+func (std *StdTerminal) Restore() error {
+	std.restoreOnce.Do(func() {
+		inErr := std.Stdin.RestoreInput()
+		outErr := std.Stdout.RestoreOutput()
+		if std.resizeCleanup != nil {
+			std.resizeCleanup()
+		}
+
+		std.restoreErr = inErr
+		if std.restoreErr == nil {
+			std.restoreErr = outErr
+		}
+	})
+	return std.restoreErr
+}
+
+// stdReadWriteCloser combines the standard input and output streams.
+// It implements io.ReadWriteCloser and DualCloser, but never closes the underlying streams.
+type stdReadWriteCloser struct {
+	io.Reader
+	io.Writer
+}
+
+func (stdReadWriteCloser) Close() error      { return nil }
+func (stdReadWriteCloser) CloseWrite() error { return nil }
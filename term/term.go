@@ -44,6 +44,14 @@ type Terminal interface {
 	//
 	// When t does not represent a terminal, returns ErrNotATerminal.
 	ResizeTo(size WindowSize) error
+
+	// GetMode returns the current mode of this terminal.
+	// When t is not a terminal, returns ErrNotATerminal.
+	GetMode() (*Mode, error)
+
+	// SetMode sets the mode of this terminal.
+	// When t is not a terminal, returns ErrNotATerminal.
+	SetMode(mode Mode) error
 }
 
 // ErrNotATerminal is returned when the underlying terminal is not a terminal
@@ -57,9 +65,21 @@ func OpenTerminal() (tty, pty Terminal, err error) {
 
 // WindowSize represents the size of a terminal window.
 type WindowSize struct {
-	Height, Width lowlevel.Size
+	Height, Width Size // in characters
+
+	// XPixel and YPixel are the width and height in pixels.
+	// They are zero when unknown.
+	XPixel, YPixel Size
 }
 
+func (size WindowSize) winsize() *lowlevel.Winsize {
+	return &lowlevel.Winsize{Height: size.Height, Width: size.Width, XPixel: size.XPixel, YPixel: size.YPixel}
+}
+
+// Size represents a single dimension of a terminal window.
+// It is guaranteed to be some integer type.
+type Size = lowlevel.Size
+
 // NewTerminal returns a new terminal instance corresponding to file.
 // rwcloser may be nil.
 func NewTerminal(rwcloser io.ReadWriteCloser) Terminal {
@@ -81,8 +101,8 @@ type fileTerminal struct {
 	fd         lowlevel.FileDescriptor
 	isTerminal bool
 
-	// os-specific state
-	inState, outState *lowlevel.TerminalState
+	// registry entries while in raw mode, see guard.go
+	rawInput, rawOutput *rawFd
 }
 
 func (t *fileTerminal) ReadWriteCloser() io.ReadWriteCloser {
@@ -101,37 +121,37 @@ func (t *fileTerminal) IsTerminal() bool {
 }
 
 func (t *fileTerminal) SetRawInput() (err error) {
-	if !t.isTerminal || t.inState != nil {
+	if !t.isTerminal || registered(t.fd, t.rawInput) {
 		return nil
 	}
-	t.inState, err = lowlevel.SetRawTerminal(t.fd)
+	t.rawInput, err = acquireRaw(t.fd, false)
 	return
 }
 
 func (t *fileTerminal) RestoreInput() error {
-	if t.inState == nil {
+	if t.rawInput == nil {
 		return nil
 	}
 
-	defer func() { t.inState = nil }() // wipe state
-	return lowlevel.ResetTerminal(t.fd, t.inState)
+	defer func() { t.rawInput = nil }() // wipe state
+	return releaseRaw(t.fd, t.rawInput, false)
 }
 
 func (t *fileTerminal) SetRawOutput() (err error) {
-	if !t.isTerminal || t.outState != nil {
+	if !t.isTerminal || registered(t.fd, t.rawOutput) {
 		return nil
 	}
-	t.outState, err = lowlevel.SetRawTerminalOutput(t.fd)
+	t.rawOutput, err = acquireRaw(t.fd, true)
 	return
 }
 
 func (t *fileTerminal) RestoreOutput() error {
-	if t.outState == nil {
+	if t.rawOutput == nil {
 		return nil
 	}
 
-	defer func() { t.outState = nil }() // wipe state
-	return lowlevel.ResetTerminal(t.fd, t.outState)
+	defer func() { t.rawOutput = nil }() // wipe state
+	return releaseRaw(t.fd, t.rawOutput, true)
 }
 
 func (t *fileTerminal) GetSize() (*WindowSize, error) {
@@ -139,14 +159,16 @@ func (t *fileTerminal) GetSize() (*WindowSize, error) {
 		return nil, ErrNotATerminal
 	}
 
-	height, width, err := lowlevel.GetWinsize(t.fd)
+	size, err := lowlevel.GetWinsize(t.fd)
 	if err != nil {
 		return nil, err
 	}
 
 	return &WindowSize{
-		Height: height,
-		Width:  width,
+		Height: size.Height,
+		Width:  size.Width,
+		XPixel: size.XPixel,
+		YPixel: size.YPixel,
 	}, nil
 }
 
@@ -155,7 +177,23 @@ func (t *fileTerminal) ResizeTo(size WindowSize) error {
 		return ErrNotATerminal
 	}
 
-	return lowlevel.SetWinsize(t.fd, size.Height, size.Width)
+	return lowlevel.SetWinsize(t.fd, size.winsize())
+}
+
+func (t *fileTerminal) GetMode() (*Mode, error) {
+	if !t.IsTerminal() {
+		return nil, ErrNotATerminal
+	}
+
+	return lowlevel.GetMode(t.fd)
+}
+
+func (t *fileTerminal) SetMode(mode Mode) error {
+	if !t.IsTerminal() {
+		return ErrNotATerminal
+	}
+
+	return lowlevel.SetMode(t.fd, &mode)
 }
 
 // nilTerminal implements Terminal returns a negative result for every command
@@ -170,3 +208,5 @@ func (nilTerminal) SetRawOutput() error                 { return nil }
 func (nilTerminal) RestoreOutput() error                { return nil }
 func (nilTerminal) GetSize() (*WindowSize, error)       { return nil, ErrNotATerminal }
 func (nilTerminal) ResizeTo(size WindowSize) error      { return ErrNotATerminal }
+func (nilTerminal) GetMode() (*Mode, error)             { return nil, ErrNotATerminal }
+func (nilTerminal) SetMode(mode Mode) error             { return ErrNotATerminal }
@@ -6,6 +6,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 )
 
 // Roughly test the terminal class and related methods.
@@ -119,6 +120,12 @@ func TestTerminal(t *testing.T) {
 			t.Error("ResizeTo() is not nil")
 		}
 
+		wantSize := WindowSize{Height: 24, Width: 80, XPixel: 640, YPixel: 480}
+		term.ResizeTo(wantSize)
+		if gotSize, _ := term.GetSize(); gotSize == nil || *gotSize != wantSize {
+			t.Errorf("GetSize() after ResizeTo() = %v, want %v", gotSize, wantSize)
+		}
+
 		gotClose := term.Close()
 		if gotClose != nil {
 			t.Error("Close() did not return nil")
@@ -126,3 +133,33 @@ func TestTerminal(t *testing.T) {
 
 	})
 }
+
+func TestOpenTerminal_close(t *testing.T) {
+	if !PTYSupport {
+		t.Skip("OS not supported")
+	}
+
+	tty, pty, err := OpenTerminal()
+	if err != nil {
+		t.Fatal("OpenTerminal() returned error")
+	}
+	defer tty.Close()
+
+	// closing the process end must interrupt a pending read
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pty.ReadWriteCloser().Read(make([]byte, 1))
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	if err := pty.Close(); err != nil {
+		t.Error("Close() did not return nil")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("Close() did not interrupt pending Read()")
+	}
+}
@@ -0,0 +1,191 @@
+package procutil
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Tracer creates spans to trace the lifecycle of commands.
+//
+// Tracer, Span and related types mirror the shape of the OpenTelemetry tracing API.
+// This allows an OpenTelemetry tracer to be used by means of a thin adapter, without depending on it.
+type Tracer interface {
+	// Start starts a new span as a child of any span in ctx, and returns a context containing the new span.
+	Start(ctx context.Context, spanName string, attributes ...Attribute) (context.Context, Span)
+}
+
+// Span is a single span of a trace, created by a Tracer.
+type Span interface {
+	// SpanContext returns the identifiers of this span
+	SpanContext() SpanContext
+
+	// AddEvent adds an event with the given name and attributes to this span
+	AddEvent(name string, attributes ...Attribute)
+	// SetAttributes sets attributes of this span
+	SetAttributes(attributes ...Attribute)
+	// RecordError records err as an event of this span
+	RecordError(err error)
+	// SetStatus sets the status of this span
+	SetStatus(code StatusCode, description string)
+
+	// End ends this span
+	End()
+}
+
+// Attribute is a key-value pair describing a span or event
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// StatusCode is the status of a span
+type StatusCode int
+
+const (
+	// StatusUnset is the default status of a span
+	StatusUnset StatusCode = iota
+	// StatusError indicates that the traced operation failed
+	StatusError
+	// StatusOK indicates that the traced operation succeeded
+	StatusOK
+)
+
+// SpanContext identifies a span
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// IsValid checks if sc has a non-zero TraceID and SpanID
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// TraceParent formats sc as a W3C trace context 'traceparent' value.
+// When sc is not valid, returns "".
+func (sc SpanContext) TraceParent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	var flags byte
+	if sc.Sampled {
+		flags = 1
+	}
+	return fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), flags)
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpan returns a copy of ctx that contains span.
+// The span can be retrieved using SpanFromContext().
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext returns the span stored in ctx by ContextWithSpan(), or nil.
+func SpanFromContext(ctx context.Context) Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanContextKey{}).(Span)
+	return span
+}
+
+// TraceParentEnv is the environment variable used to propagate trace context to child processes
+const TraceParentEnv = "TRACEPARENT"
+
+// traceParentEnv returns the environment variable propagating the span in ctx to a child process.
+// When ctx contains no valid span, returns "".
+func traceParentEnv(ctx context.Context) string {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return ""
+	}
+	tp := span.SpanContext().TraceParent()
+	if tp == "" {
+		return ""
+	}
+	return TraceParentEnv + "=" + tp
+}
+
+// commandSpanName is the name of spans created for commands
+const commandSpanName = "procutil.Command"
+
+// Attribute keys used for spans of commands
+const (
+	AttributeCommandLine = "process.command_line"
+	AttributeKind        = "procutil.kind"
+	AttributePid         = "process.pid"
+	AttributeExitCode    = "process.exit_code"
+)
+
+// spanObserver records the events of a command into a span.
+type spanObserver struct {
+	span    Span
+	endOnce sync.Once
+}
+
+// Observe records event into the span
+func (so *spanObserver) Observe(event Event) {
+	switch event.Type {
+	case EventInit:
+		so.span.SetAttributes(Attribute{AttributeCommandLine, event.Process}, Attribute{AttributeKind, event.Kind})
+		so.fail(event.Err)
+	case EventStart:
+		so.span.AddEvent("start", Attribute{AttributePid, event.Pid})
+		so.fail(event.Err)
+	case EventFirstOutput:
+		so.span.AddEvent("first_output")
+	case EventStop:
+		so.span.AddEvent("stop")
+		if event.Err != nil {
+			so.span.RecordError(event.Err)
+		}
+	case EventDone:
+		so.span.AddEvent("exit", Attribute{AttributeExitCode, event.ExitCode})
+		so.span.SetAttributes(Attribute{AttributeExitCode, event.ExitCode})
+		switch {
+		case event.Err != nil:
+			so.span.RecordError(event.Err)
+			so.span.SetStatus(StatusError, event.Err.Error())
+		case event.ExitCode != 0:
+			so.span.SetStatus(StatusError, fmt.Sprintf("exit code %d", event.ExitCode))
+		default:
+			so.span.SetStatus(StatusOK, "")
+		}
+	case EventCleanup:
+		so.span.AddEvent("cleanup")
+		if event.Err != nil {
+			so.span.RecordError(event.Err)
+		}
+		so.end()
+	}
+}
+
+// usageErrors are caused by calling methods of a Command in the wrong state.
+// They do not change the state of the command, and are ignored by fail().
+var usageErrors = []error{ErrCommandAlreadyInitialized, ErrCommandNotInitialized, ErrCommandAlreadyStarted, ErrCommandIsATerminal, ErrCommandNotATerminal}
+
+// fail ends the span when initializing or starting the process failed, as the command will not reach cleanup.
+func (so *spanObserver) fail(err error) {
+	if err == nil {
+		return
+	}
+	for _, usage := range usageErrors {
+		if errors.Is(err, usage) {
+			return
+		}
+	}
+
+	so.span.RecordError(err)
+	so.span.SetStatus(StatusError, err.Error())
+	so.end()
+}
+
+func (so *spanObserver) end() {
+	so.endOnce.Do(so.span.End)
+}
@@ -0,0 +1,284 @@
+package procutil
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tkw1536/procutil/term"
+)
+
+// testSpanContext is the span context of all testSpans
+var testSpanContext = SpanContext{
+	TraceID: [16]byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+	SpanID:  [8]byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	Sampled: true,
+}
+
+// testTraceParent is the TraceParent() of testSpanContext
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// testTracer is a Tracer that records the spans it starts
+type testTracer struct {
+	m     sync.Mutex
+	spans []*testSpan
+}
+
+func (tt *testTracer) Start(ctx context.Context, spanName string, attributes ...Attribute) (context.Context, Span) {
+	tt.m.Lock()
+	defer tt.m.Unlock()
+
+	span := &testSpan{Name: spanName, Parent: SpanFromContext(ctx), ended: make(chan struct{})}
+	span.SetAttributes(attributes...)
+	tt.spans = append(tt.spans, span)
+	return ctx, span
+}
+
+// testSpan is a span that records all calls to it
+type testSpan struct {
+	Name   string
+	Parent Span
+
+	m          sync.Mutex
+	events     []string
+	attributes map[string]interface{}
+	errors     []error
+	status     StatusCode
+	ends       int
+	ended      chan struct{}
+}
+
+func (ts *testSpan) SpanContext() SpanContext {
+	return testSpanContext
+}
+
+func (ts *testSpan) AddEvent(name string, attributes ...Attribute) {
+	ts.m.Lock()
+	defer ts.m.Unlock()
+
+	ts.events = append(ts.events, name)
+}
+
+func (ts *testSpan) SetAttributes(attributes ...Attribute) {
+	ts.m.Lock()
+	defer ts.m.Unlock()
+
+	if ts.attributes == nil {
+		ts.attributes = make(map[string]interface{})
+	}
+	for _, attribute := range attributes {
+		ts.attributes[attribute.Key] = attribute.Value
+	}
+}
+
+func (ts *testSpan) RecordError(err error) {
+	ts.m.Lock()
+	defer ts.m.Unlock()
+
+	ts.errors = append(ts.errors, err)
+}
+
+func (ts *testSpan) SetStatus(code StatusCode, description string) {
+	ts.m.Lock()
+	defer ts.m.Unlock()
+
+	ts.status = code
+}
+
+func (ts *testSpan) End() {
+	ts.m.Lock()
+	defer ts.m.Unlock()
+
+	ts.ends++
+	if ts.ends == 1 {
+		close(ts.ended)
+	}
+}
+
+// waitEnd waits up to a second for the span to end, and reports if it did
+func (ts *testSpan) waitEnd() bool {
+	select {
+	case <-ts.ended:
+		return true
+	case <-time.After(time.Second):
+		return false
+	}
+}
+
+func TestSpanContext_TraceParent(t *testing.T) {
+	tests := []struct {
+		name string
+		sc   SpanContext
+		want string
+	}{
+		{"invalid span context", SpanContext{}, ""},
+		{"missing span id", SpanContext{TraceID: testSpanContext.TraceID}, ""},
+		{"sampled span context", testSpanContext, testTraceParent},
+		{"unsampled span context", SpanContext{TraceID: testSpanContext.TraceID, SpanID: testSpanContext.SpanID}, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sc.TraceParent(); got != tt.want {
+				t.Errorf("SpanContext.TraceParent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommand_Tracer(t *testing.T) {
+	tests := []struct {
+		name       string
+		exitCode   int
+		wantStatus StatusCode
+	}{
+		{"successful command", 0, StatusOK},
+		{"failing command", 3, StatusError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tracer testTracer
+			command := &Command{Process: &testProcess{Out: "hello", ExitCode: tt.exitCode}, Tracer: &tracer}
+
+			command.Init(nil, false)
+			command.Start(ioutil.Discard, ioutil.Discard, strings.NewReader(""))
+			command.Wait()
+
+			if len(tracer.spans) != 1 {
+				t.Fatalf("tracer started %d spans, want 1", len(tracer.spans))
+			}
+			span := tracer.spans[0]
+			if !span.waitEnd() {
+				t.Fatal("span did not end")
+			}
+
+			span.m.Lock()
+			defer span.m.Unlock()
+
+			if span.Name != commandSpanName {
+				t.Errorf("span has name %q, want %q", span.Name, commandSpanName)
+			}
+			wantEvents := []string{"start", "first_output", "exit", "cleanup"}
+			if !reflect.DeepEqual(span.events, wantEvents) {
+				t.Errorf("span has events %v, want %v", span.events, wantEvents)
+			}
+			if got := span.attributes[AttributeCommandLine]; got != "TestProcess" {
+				t.Errorf("span has command line %v, want \"TestProcess\"", got)
+			}
+			if got := span.attributes[AttributeExitCode]; got != tt.exitCode {
+				t.Errorf("span has exit code %v, want %d", got, tt.exitCode)
+			}
+			if span.status != tt.wantStatus {
+				t.Errorf("span has status %v, want %v", span.status, tt.wantStatus)
+			}
+			if span.ends != 1 {
+				t.Errorf("span ended %d times, want 1", span.ends)
+			}
+		})
+	}
+}
+
+// failingProcess is a process that fails to initialize
+type failingProcess struct {
+	testProcess
+}
+
+var errInitFailed = errors.New("init failed")
+
+func (fp *failingProcess) Init(ctx context.Context, isPty bool) error {
+	return errInitFailed
+}
+
+func TestCommand_Tracer_initFailure(t *testing.T) {
+	var tracer testTracer
+	command := &Command{Process: &failingProcess{}, Tracer: &tracer}
+	command.Init(nil, false)
+
+	if command.hasObservers() {
+		t.Error("span observer is still registered after Init() failed")
+	}
+
+	span := tracer.spans[0]
+	if !span.waitEnd() {
+		t.Fatal("span did not end")
+	}
+
+	span.m.Lock()
+	defer span.m.Unlock()
+
+	if span.status != StatusError {
+		t.Errorf("span has status %v, want StatusError", span.status)
+	}
+	if len(span.errors) != 1 || !errors.Is(span.errors[0], errInitFailed) {
+		t.Errorf("span recorded errors %v, want errInitFailed", span.errors)
+	}
+	if span.ends != 1 {
+		t.Errorf("span ended %d times, want 1", span.ends)
+	}
+}
+
+func TestExecProcess_traceParent(t *testing.T) {
+	if !term.PTYSupport {
+		t.Skip("OS not supported")
+	}
+
+	command := &Command{
+		Process: &ExecProcess{
+			Command: "/bin/sh",
+			Args:    []string{"-c", "echo $" + TraceParentEnv},
+		},
+	}
+
+	ctx := ContextWithSpan(context.Background(), &testSpan{ended: make(chan struct{})})
+	if err := command.Init(ctx, true); err != nil {
+		t.Fatalf("Command.Init() returned %s", err)
+	}
+
+	e, err := StartExpect(command, "dumb", nil)
+	if err != nil {
+		t.Fatalf("StartExpect() returned %s", err)
+	}
+	defer e.Wait()
+
+	if _, err := e.Expect(5*time.Second, ExpectLiteral(testTraceParent)); err != nil {
+		t.Errorf("Expect() returned %s", err)
+	}
+}
+
+// Test that a process on a pty without an environment only receives TERM and the trace context.
+func TestExecProcess_traceParent_ptyEnv(t *testing.T) {
+	if !term.PTYSupport {
+		t.Skip("OS not supported")
+	}
+
+	command := &Command{
+		Process: &ExecProcess{
+			Command: "env",
+		},
+	}
+
+	ctx := ContextWithSpan(context.Background(), &testSpan{ended: make(chan struct{})})
+	if err := command.Init(ctx, true); err != nil {
+		t.Fatalf("Command.Init() returned %s", err)
+	}
+
+	e, err := StartExpect(command, "dumb", nil)
+	if err != nil {
+		t.Fatalf("StartExpect() returned %s", err)
+	}
+	defer e.Wait()
+
+	if _, err := e.Expect(5*time.Second, ExpectEOF); err != nil {
+		t.Fatalf("Expect() returned %s", err)
+	}
+
+	got := strings.Fields(e.Transcript())
+	want := []string{TraceParentEnv + "=" + testTraceParent, "TERM=dumb"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("process environment is %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,203 @@
+package procutil
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// This file implements transcripts of the output streams of a process.
+//
+// A transcript is stored as JSON lines.
+// The first line is a header object, holding the format version and the time the transcript was started:
+//
+//	{"version":1,"timestamp":"2024-01-02T15:04:05.123456789Z"}
+//
+// Every following line is a chunk of data written to one of the streams, in the order the chunks were written:
+//
+//	{"time":0.25,"stream":"stdout","data":"hello world\n"}
+//	{"time":0.5,"stream":"stderr","base64":"/w=="}
+//
+// The time field holds the number of seconds since the timestamp of the header.
+// The stream field holds the name of the stream, "stdout" or "stderr" for output recorded from a Command.
+// The data field holds the data of the chunk when it is valid utf8.
+// Otherwise it is omitted, and the base64 field holds the standard base64 encoding of the data instead.
+
+// TranscriptHeader is the header of a transcript
+type TranscriptHeader struct {
+	Version   int       `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// TranscriptChunk is a single chunk of data within a transcript
+type TranscriptChunk struct {
+	Time   float64 // seconds since the start of the transcript
+	Stream string
+	Data   []byte
+}
+
+// transcriptChunkJSON is the encoding of a TranscriptChunk
+type transcriptChunkJSON struct {
+	Time   float64 `json:"time"`
+	Stream string  `json:"stream"`
+	Data   *string `json:"data,omitempty"`
+	Base64 []byte  `json:"base64,omitempty"`
+}
+
+// MarshalJSON encodes this chunk as a JSON object
+func (tc TranscriptChunk) MarshalJSON() ([]byte, error) {
+	encoded := transcriptChunkJSON{Time: tc.Time, Stream: tc.Stream}
+	if utf8.Valid(tc.Data) {
+		data := string(tc.Data)
+		encoded.Data = &data
+	} else {
+		encoded.Base64 = tc.Data
+	}
+	return json.Marshal(encoded)
+}
+
+var errTranscriptInvalidChunk = errors.New("Transcript: Invalid chunk")
+
+// UnmarshalJSON decodes this chunk from a JSON object
+func (tc *TranscriptChunk) UnmarshalJSON(data []byte) error {
+	var decoded transcriptChunkJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	if decoded.Stream == "" || (decoded.Data != nil && decoded.Base64 != nil) {
+		return errTranscriptInvalidChunk
+	}
+
+	tc.Time = decoded.Time
+	tc.Stream = decoded.Stream
+	if decoded.Data != nil {
+		tc.Data = []byte(*decoded.Data)
+	} else {
+		tc.Data = decoded.Base64
+	}
+	return nil
+}
+
+// Transcript represents a transcript read into memory
+type Transcript struct {
+	Header TranscriptHeader
+	Chunks []TranscriptChunk
+}
+
+var errTranscriptUnsupportedVersion = errors.New("Transcript: Unsupported version")
+
+// ReadTranscript reads a transcript from reader
+func ReadTranscript(reader io.Reader) (*Transcript, error) {
+	var transcript Transcript
+
+	check := func() error {
+		if transcript.Header.Version != 1 {
+			return errTranscriptUnsupportedVersion
+		}
+		return nil
+	}
+	next := func() interface{} {
+		transcript.Chunks = append(transcript.Chunks, TranscriptChunk{})
+		return &transcript.Chunks[len(transcript.Chunks)-1]
+	}
+
+	if err := readJSONLines(reader, "Transcript", "chunk", &transcript.Header, check, next); err != nil {
+		return nil, err
+	}
+	return &transcript, nil
+}
+
+// Replay writes the data of all chunks of the "stdout" and "stderr" streams to stdout and stderr respectively.
+// Chunks are written in the order they were recorded, without delay.
+// To replay both streams combined, pass the same writer twice.
+//
+// When either writer is nil, the corresponding chunks are skipped.
+// Chunks of other streams are always skipped.
+func (t *Transcript) Replay(stdout, stderr io.Writer) error {
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+
+	for _, chunk := range t.Chunks {
+		var dest io.Writer
+		switch chunk.Stream {
+		case "stdout":
+			dest = stdout
+		case "stderr":
+			dest = stderr
+		default:
+			continue
+		}
+		if _, err := dest.Write(chunk.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TranscriptWriter writes a transcript to an underlying writer.
+// It is safe to be called by multiple goroutines.
+//
+// Chunks are timestamped and written in the order they are received, preserving the interleaving of streams as observed.
+// When used with Command.Start(), standard output and standard error are read concurrently.
+// The order is then that in which the command received the output from the process.
+type TranscriptWriter struct {
+	lines *jsonLinesWriter
+}
+
+// NewTranscriptWriter writes a transcript header to w and returns a new TranscriptWriter writing to w.
+// Chunk times are measured relative to the time this function was called.
+func NewTranscriptWriter(w io.Writer) (*TranscriptWriter, error) {
+	header := TranscriptHeader{Version: 1, Timestamp: time.Now()}
+
+	lines, err := newJSONLinesWriter(w, header, header.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	return &TranscriptWriter{lines: lines}, nil
+}
+
+// WriteChunk writes a chunk of data written to stream, timestamped with the current time.
+func (tw *TranscriptWriter) WriteChunk(stream string, data []byte) error {
+	return tw.lines.Write(func(elapsed float64) interface{} {
+		return TranscriptChunk{Time: elapsed, Stream: stream, Data: data}
+	})
+}
+
+// Stream returns a writer that writes every non-empty write to it as a chunk of stream
+func (tw *TranscriptWriter) Stream(stream string) io.Writer {
+	return transcriptStream{tw: tw, stream: stream}
+}
+
+// Stdout returns a writer for the "stdout" stream
+func (tw *TranscriptWriter) Stdout() io.Writer {
+	return tw.Stream("stdout")
+}
+
+// Stderr returns a writer for the "stderr" stream
+func (tw *TranscriptWriter) Stderr() io.Writer {
+	return tw.Stream("stderr")
+}
+
+// transcriptStream is a single stream of a TranscriptWriter
+type transcriptStream struct {
+	tw     *TranscriptWriter
+	stream string
+}
+
+func (ts transcriptStream) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := ts.tw.WriteChunk(ts.stream, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,145 @@
+package procutil
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTranscriptWriter(t *testing.T) {
+	var buffer bytes.Buffer
+	tw, err := NewTranscriptWriter(&buffer)
+	if err != nil {
+		t.Fatalf("NewTranscriptWriter() returned %s", err)
+	}
+
+	tw.Stdout().Write([]byte("out 1\n"))
+	tw.Stderr().Write([]byte("err 1\n"))
+	tw.Stdout().Write([]byte{})
+	tw.Stdout().Write([]byte("out 2\n"))
+	tw.Stream("other").Write([]byte("other\n"))
+	tw.Stderr().Write([]byte{0xff, 0xfe})
+
+	transcript, err := ReadTranscript(&buffer)
+	if err != nil {
+		t.Fatalf("ReadTranscript() returned %s", err)
+	}
+	if transcript.Header.Version != 1 || transcript.Header.Timestamp.IsZero() {
+		t.Errorf("transcript has header %+v", transcript.Header)
+	}
+
+	var got []TranscriptChunk
+	last := 0.0
+	for _, chunk := range transcript.Chunks {
+		if chunk.Time < last {
+			t.Errorf("chunk %q has time %f before previous chunk", chunk.Data, chunk.Time)
+		}
+		last = chunk.Time
+		got = append(got, TranscriptChunk{Stream: chunk.Stream, Data: chunk.Data})
+	}
+	want := []TranscriptChunk{
+		{Stream: "stdout", Data: []byte("out 1\n")},
+		{Stream: "stderr", Data: []byte("err 1\n")},
+		{Stream: "stdout", Data: []byte("out 2\n")},
+		{Stream: "other", Data: []byte("other\n")},
+		{Stream: "stderr", Data: []byte{0xff, 0xfe}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("transcript has chunks %+v, want %+v", got, want)
+	}
+
+	var stdout, stderr, combined bytes.Buffer
+	if err := transcript.Replay(&stdout, &stderr); err != nil {
+		t.Errorf("Replay() returned %s", err)
+	}
+	if want := "out 1\nout 2\n"; stdout.String() != want {
+		t.Errorf("Replay() wrote stdout %q, want %q", stdout.String(), want)
+	}
+	if want := "err 1\n\xff\xfe"; stderr.String() != want {
+		t.Errorf("Replay() wrote stderr %q, want %q", stderr.String(), want)
+	}
+
+	if err := transcript.Replay(&combined, &combined); err != nil {
+		t.Errorf("Replay() returned %s", err)
+	}
+	if want := "out 1\nerr 1\nout 2\n\xff\xfe"; combined.String() != want {
+		t.Errorf("Replay() wrote combined %q, want %q", combined.String(), want)
+	}
+}
+
+func TestTranscriptChunk_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		chunk TranscriptChunk
+		want  string
+	}{
+		{"text", TranscriptChunk{Time: 0.5, Stream: "stdout", Data: []byte("hello\n")}, `{"time":0.5,"stream":"stdout","data":"hello\n"}`},
+		{"binary", TranscriptChunk{Time: 1, Stream: "stderr", Data: []byte{0xff}}, `{"time":1,"stream":"stderr","base64":"/w=="}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.chunk.MarshalJSON()
+			if err != nil {
+				t.Fatalf("MarshalJSON() returned %s", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("MarshalJSON() = %s, want %s", got, tt.want)
+			}
+
+			var decoded TranscriptChunk
+			if err := decoded.UnmarshalJSON(got); err != nil {
+				t.Fatalf("UnmarshalJSON() returned %s", err)
+			}
+			if !reflect.DeepEqual(decoded, tt.chunk) {
+				t.Errorf("UnmarshalJSON() = %+v, want %+v", decoded, tt.chunk)
+			}
+		})
+	}
+}
+
+func TestReadTranscript_invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"invalid header", "hello\n"},
+		{"unsupported version", `{"version":2,"timestamp":"2024-01-02T15:04:05Z"}` + "\n"},
+		{"invalid chunk", `{"version":1,"timestamp":"2024-01-02T15:04:05Z"}` + "\n" + `[0, "o", "hello"]` + "\n"},
+		{"chunk without stream", `{"version":1,"timestamp":"2024-01-02T15:04:05Z"}` + "\n" + `{"time":0,"data":"hello"}` + "\n"},
+		{"chunk with data and base64", `{"version":1,"timestamp":"2024-01-02T15:04:05Z"}` + "\n" + `{"time":0,"stream":"stdout","data":"a","base64":"YQ=="}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadTranscript(strings.NewReader(tt.input)); err == nil {
+				t.Error("ReadTranscript() returned nil error")
+			}
+		})
+	}
+}
+
+func TestTranscriptWriter_Command(t *testing.T) {
+	var buffer bytes.Buffer
+	tw, err := NewTranscriptWriter(&buffer)
+	if err != nil {
+		t.Fatalf("NewTranscriptWriter() returned %s", err)
+	}
+
+	command := &Command{Process: &testProcess{Out: "out", Err: "err"}}
+	if err := command.Run(context.Background(), tw.Stdout(), tw.Stderr(), nil); err != nil {
+		t.Fatalf("Run() returned %s", err)
+	}
+
+	transcript, err := ReadTranscript(&buffer)
+	if err != nil {
+		t.Fatalf("ReadTranscript() returned %s", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	transcript.Replay(&stdout, &stderr)
+	if stdout.String() != "out" || stderr.String() != "err" {
+		t.Errorf("Replay() wrote (%q, %q), want (\"out\", \"err\")", stdout.String(), stderr.String())
+	}
+}